@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config reúne as formas de autenticação aceitas. Chaves de API cadastradas
+// estão sempre habilitadas; os tokens JWT só são aceitos para os algoritmos
+// com chave configurada.
+type Config struct {
+	// BootstrapAPIKey é uma chave de administrador fora do cadastro, usada
+	// para criar as primeiras chaves de API.
+	BootstrapAPIKey string
+	JWTSecret       []byte
+	JWTPublicKey    *rsa.PublicKey
+	JWTIssuer       string
+	JWTAudience     string
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+func Configure(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// ConfigFromEnv lê a configuração das variáveis AUTH_BOOTSTRAP_API_KEY,
+// JWT_HS256_SECRET, JWT_RS256_PUBLIC_KEY_FILE, JWT_ISSUER e JWT_AUDIENCE.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		BootstrapAPIKey: os.Getenv("AUTH_BOOTSTRAP_API_KEY"),
+		JWTSecret:       []byte(os.Getenv("JWT_HS256_SECRET")),
+		JWTIssuer:       os.Getenv("JWT_ISSUER"),
+		JWTAudience:     os.Getenv("JWT_AUDIENCE"),
+	}
+
+	if path := os.Getenv("JWT_RS256_PUBLIC_KEY_FILE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading JWT_RS256_PUBLIC_KEY_FILE: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return Config{}, fmt.Errorf("parsing JWT_RS256_PUBLIC_KEY_FILE: %w", err)
+		}
+		c.JWTPublicKey = key
+	}
+
+	return c, nil
+}
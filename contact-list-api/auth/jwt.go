@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var errJWTDisabled = errors.New("jwt authentication is not configured")
+
+var errJWTWrongTenant = errors.New("jwt was issued for another tenant")
+
+type tokenClaims struct {
+	Role   string `json:"role"`
+	Tenant string `json:"tenant"`
+	jwt.RegisteredClaims
+}
+
+// verifyJWT valida assinatura, expiração (obrigatória), emissor e audiência
+// do token e devolve o principal com o papel da claim "role". A claim
+// "tenant" precisa coincidir com o tenant da requisição; sem ela, o token só
+// vale no tenant padrão.
+func verifyJWT(tenantID, token string) (Principal, error) {
+	c := currentConfig()
+
+	var methods []string
+	if len(c.JWTSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if c.JWTPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return Principal{}, errJWTDisabled
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if c.JWTIssuer != "" {
+		options = append(options, jwt.WithIssuer(c.JWTIssuer))
+	}
+	if c.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(c.JWTAudience))
+	}
+
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		// WithValidMethods já recusou algoritmos sem chave configurada.
+		if t.Method.Alg() == jwt.SigningMethodRS256.Alg() {
+			return c.JWTPublicKey, nil
+		}
+		return c.JWTSecret, nil
+	}, options...)
+	if err != nil {
+		return Principal{}, err
+	}
+	if claims.Tenant != tenantID {
+		return Principal{}, errJWTWrongTenant
+	}
+
+	return Principal{
+		Subject:  claims.Subject,
+		Role:     claims.Role,
+		Method:   MethodJWT,
+		TenantID: tenantID,
+	}, nil
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+const (
+	MethodAPIKey    = "api_key"
+	MethodJWT       = "jwt"
+	MethodBootstrap = "bootstrap"
+	MethodSession   = "session"
+	MethodPassword  = "password"
+)
+
+const principalKey = "auth.principal"
+
+// Principal identifica quem fez a requisição. UserID é o dono da agenda
+// acessada; chaves de API e tokens JWT usam a agenda compartilhada.
+type Principal struct {
+	Subject  string `json:"subject"`
+	Role     string `json:"role"`
+	Method   string `json:"method"`
+	TenantID string `json:"tenant_id,omitempty"`
+	UserID   int    `json:"user_id,omitempty"`
+}
+
+// CurrentPrincipal devolve o principal autenticado pela requisição.
+func CurrentPrincipal(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// Authenticate exige credenciais válidas: uma chave de API em X-API-Key ou
+// como Bearer, um token de sessão ou JWT Bearer, ou Basic com usuário e senha
+// de uma conta ou com uma chave de API como senha (para clientes CardDAV).
+// Credenciais ausentes ou inválidas resultam em 401. Chaves, contas e sessões
+// são procuradas apenas no tenant resolvido por tenancy.Resolve, que deve vir
+// antes.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred := extractCredential(c.Request)
+		if cred.value == "" {
+			unauthorized(c, "authentication required", "")
+			return
+		}
+
+		principal, err := authenticate(c.Request.Context(), tenancy.TenantID(c), cred)
+		if err != nil {
+			unauthorized(c, "invalid credentials", "invalid_token")
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// RequireRole responde 403 quando o principal autenticado não tem o papel
+// exigido. Deve ser usado depois de Authenticate.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			unauthorized(c, "authentication required", "")
+			return
+		}
+		if !models.RoleAllows(principal.Role, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role " + strconv.Quote(role) + " required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePlatformAdmin restringe a rota à chave de bootstrap, a única
+// credencial que não pertence a um tenant.
+func RequirePlatformAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			unauthorized(c, "authentication required", "")
+			return
+		}
+		if principal.Method != MethodBootstrap {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "platform administrator required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+const (
+	credentialAPIKey = "api_key"
+	credentialBearer = "bearer"
+	credentialBasic  = "basic"
+)
+
+type credential struct {
+	value    string
+	kind     string
+	username string
+}
+
+func extractCredential(r *http.Request) credential {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return credential{value: key, kind: credentialAPIKey}
+	}
+
+	header := r.Header.Get("Authorization")
+	scheme, value, _ := strings.Cut(header, " ")
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return credential{value: strings.TrimSpace(value), kind: credentialBearer}
+	case "basic":
+		if username, password, ok := r.BasicAuth(); ok {
+			return credential{value: password, kind: credentialBasic, username: username}
+		}
+	}
+
+	return credential{}
+}
+
+func authenticate(ctx context.Context, tenantID string, cred credential) (Principal, error) {
+	c := currentConfig()
+	if c.BootstrapAPIKey != "" && subtle.ConstantTimeCompare([]byte(cred.value), []byte(c.BootstrapAPIKey)) == 1 {
+		return Principal{Subject: "bootstrap", Role: models.RoleAdmin, Method: MethodBootstrap, TenantID: tenantID}, nil
+	}
+
+	isAPIKey := strings.HasPrefix(cred.value, "clk_")
+	switch {
+	case cred.kind == credentialBearer && services.IsSessionToken(cred.value):
+		user, err := services.VerifySession(ctx, tenantID, cred.value)
+		if err != nil {
+			return Principal{}, err
+		}
+		return userPrincipal(tenantID, user, MethodSession), nil
+	case cred.kind == credentialBearer && !isAPIKey:
+		return verifyJWT(tenantID, cred.value)
+	case cred.kind == credentialBasic && !isAPIKey:
+		user, err := services.AuthenticateUser(ctx, tenantID, cred.username, cred.value)
+		if err != nil {
+			return Principal{}, err
+		}
+		return userPrincipal(tenantID, user, MethodPassword), nil
+	}
+
+	key, err := services.VerifyAPIKey(ctx, tenantID, cred.value)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{
+		Subject:  "api-key:" + strconv.Itoa(key.ID),
+		Role:     key.Role,
+		Method:   MethodAPIKey,
+		TenantID: tenantID,
+	}, nil
+}
+
+// userPrincipal dá ao usuário o papel de editor da própria agenda.
+func userPrincipal(tenantID string, user models.User, method string) Principal {
+	return Principal{
+		Subject:  user.Username,
+		Role:     models.RoleEditor,
+		Method:   method,
+		TenantID: tenantID,
+		UserID:   user.ID,
+	}
+}
+
+func unauthorized(c *gin.Context, message, bearerError string) {
+	challenge := `Bearer realm="contact-list-api"`
+	if bearerError != "" {
+		challenge += `, error="` + bearerError + `"`
+	}
+	c.Writer.Header().Add("WWW-Authenticate", challenge)
+	c.Writer.Header().Add("WWW-Authenticate", `Basic realm="contact-list-api"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// OwnerID devolve o dono da agenda acessada pela requisição.
+func OwnerID(c *gin.Context) int {
+	principal, _ := CurrentPrincipal(c)
+	return principal.UserID
+}
+
+// Role devolve o papel do principal autenticado, ou "" sem autenticação.
+func Role(c *gin.Context) string {
+	principal, _ := CurrentPrincipal(c)
+	return principal.Role
+}
+
+// ClientIP identifica o cliente pelo IP, para o limite aplicado antes da
+// autenticação, que conta também as credenciais recusadas. O IP só vem de
+// X-Forwarded-For quando a conexão parte de um proxy confiável (veja
+// gin.Engine.SetTrustedProxies).
+func ClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ClientID identifica o cliente para o limite de requisições: o tenant e o
+// sujeito autenticado ou, antes da autenticação, o IP.
+func ClientID(c *gin.Context) string {
+	if principal, ok := CurrentPrincipal(c); ok {
+		return "principal:" + principal.TenantID + "/" + principal.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
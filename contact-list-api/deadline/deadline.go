@@ -0,0 +1,110 @@
+package deadline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidTimeout = errors.New("invalid request timeout, expected a duration such as 10s or 2m")
+
+// Config reúne os prazos das requisições. Routes usa a chave "MÉTODO /rota",
+// como o limite de requisições, e prevalece sobre Default. Prazo zero
+// desliga o limite, o que os streams de eventos precisam para ficar abertos.
+// WriteTimeout é o do servidor HTTP: uma rota com prazo maior que ele tem o
+// limite de escrita da conexão estendido, senão a resposta seria cortada
+// antes do prazo.
+type Config struct {
+	Default      time.Duration
+	Routes       map[string]time.Duration
+	WriteTimeout time.Duration
+}
+
+// DefaultConfig é usada quando as variáveis de ambiente não são definidas.
+// A exportação e a recifragem passam pelo arquivo inteiro e têm prazos
+// maiores; os streams de eventos não têm prazo.
+func DefaultConfig() Config {
+	return Config{
+		Default: 10 * time.Second,
+		Routes: map[string]time.Duration{
+			"GET /contacts/export":             time.Minute,
+			"POST /privacy/export":             time.Minute,
+			"POST /admin/encryption/reencrypt": 5 * time.Minute,
+			"GET /contacts/events":             0,
+			"GET /contacts/events/ws":          0,
+		},
+	}
+}
+
+// ParseTimeout lê um prazo no formato de time.ParseDuration; "0" desliga o
+// prazo.
+func ParseTimeout(s string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTimeout, s)
+	}
+	return timeout, nil
+}
+
+// ConfigFromEnv parte de DefaultConfig e aplica REQUEST_TIMEOUT ("10s") e
+// REQUEST_TIMEOUT_ROUTES ("GET /contacts/export=2m;GET /contacts/search=3s").
+func ConfigFromEnv() (Config, error) {
+	c := DefaultConfig()
+
+	if value := os.Getenv("REQUEST_TIMEOUT"); value != "" {
+		timeout, err := ParseTimeout(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("REQUEST_TIMEOUT: %w", err)
+		}
+		c.Default = timeout
+	}
+
+	if value := os.Getenv("REQUEST_TIMEOUT_ROUTES"); value != "" {
+		for _, entry := range strings.Split(value, ";") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			route, timeoutValue, ok := strings.Cut(entry, "=")
+			if !ok {
+				return Config{}, fmt.Errorf("REQUEST_TIMEOUT_ROUTES: %w: %q", ErrInvalidTimeout, entry)
+			}
+			timeout, err := ParseTimeout(timeoutValue)
+			if err != nil {
+				return Config{}, fmt.Errorf("REQUEST_TIMEOUT_ROUTES: %w", err)
+			}
+			c.Routes[strings.Join(strings.Fields(route), " ")] = timeout
+		}
+	}
+
+	return c, nil
+}
+
+// For devolve o prazo da rota, no formato "MÉTODO /rota".
+func (c Config) For(route string) time.Duration {
+	if timeout, ok := c.Routes[route]; ok {
+		return timeout
+	}
+	return c.Default
+}
+
+var (
+	configMu sync.RWMutex
+	config   *Config
+)
+
+// Configure define os prazos usados por Requests. Sem configuração, o que
+// acontece até main configurar uma, as requisições não têm prazo.
+func Configure(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = &c
+}
+
+func currentConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,41 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Requests dá ao contexto da requisição o prazo da rota. Os serviços e o
+// armazenamento recebem esse contexto e desistem quando ele vence ou quando o
+// cliente desconecta; os handlers respondem 504 no primeiro caso. Quando o
+// prazo passa do WriteTimeout do servidor, o limite de escrita da conexão
+// acompanha o prazo.
+func Requests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := currentConfig()
+		if config == nil {
+			c.Next()
+			return
+		}
+
+		timeout := config.For(c.Request.Method + " " + c.FullPath())
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		if config.WriteTimeout > 0 && timeout > config.WriteTimeout {
+			// Depois do prazo, a resposta, inclusive o 504, ainda tem o
+			// WriteTimeout do servidor para ser escrita.
+			http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + config.WriteTimeout))
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
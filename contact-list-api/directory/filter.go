@@ -0,0 +1,172 @@
+package directory
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+)
+
+var (
+	ErrInvalidFilter     = errors.New("invalid ldap filter")
+	ErrUnsupportedFilter = errors.New("unsupported ldap filter")
+)
+
+// ParseFilter converte a representação textual de um filtro LDAP (RFC 4515)
+// na consulta usada pelo serviço de diretório.
+func ParseFilter(filter string) (services.DirectoryFilter, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return services.DirectoryFilter{Op: services.FilterPresent, Attribute: "objectClass"}, nil
+	}
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+
+	parsed, rest, err := parseFilter(filter)
+	if err != nil {
+		return services.DirectoryFilter{}, err
+	}
+	if rest != "" {
+		return services.DirectoryFilter{}, ErrInvalidFilter
+	}
+	return parsed, nil
+}
+
+func parseFilter(s string) (services.DirectoryFilter, string, error) {
+	if len(s) < 2 || s[0] != '(' {
+		return services.DirectoryFilter{}, "", ErrInvalidFilter
+	}
+	s = s[1:]
+
+	switch s[0] {
+	case '&', '|':
+		op := services.FilterAnd
+		if s[0] == '|' {
+			op = services.FilterOr
+		}
+		children, rest, err := parseFilterList(s[1:])
+		if err != nil {
+			return services.DirectoryFilter{}, "", err
+		}
+		return services.DirectoryFilter{Op: op, Children: children}, rest, nil
+	case '!':
+		child, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return services.DirectoryFilter{}, "", err
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return services.DirectoryFilter{}, "", ErrInvalidFilter
+		}
+		return services.DirectoryFilter{Op: services.FilterNot, Children: []services.DirectoryFilter{child}}, rest[1:], nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return services.DirectoryFilter{}, "", ErrInvalidFilter
+	}
+	item, err := parseFilterItem(s[:end])
+	return item, s[end+1:], err
+}
+
+func parseFilterList(s string) ([]services.DirectoryFilter, string, error) {
+	var children []services.DirectoryFilter
+	for {
+		if strings.HasPrefix(s, ")") {
+			return children, s[1:], nil
+		}
+		child, rest, err := parseFilter(s)
+		if err != nil {
+			return nil, "", err
+		}
+		children = append(children, child)
+		s = rest
+	}
+}
+
+func parseFilterItem(item string) (services.DirectoryFilter, error) {
+	index := strings.IndexByte(item, '=')
+	if index <= 0 {
+		return services.DirectoryFilter{}, ErrInvalidFilter
+	}
+
+	attribute, value := item[:index], item[index+1:]
+	op := services.FilterEqual
+	switch attribute[len(attribute)-1] {
+	case '~':
+		op = services.FilterApprox
+	case '>':
+		op = services.FilterGreater
+	case '<':
+		op = services.FilterLess
+	case ':':
+		return services.DirectoryFilter{}, ErrUnsupportedFilter
+	}
+	if op != services.FilterEqual {
+		attribute = attribute[:len(attribute)-1]
+	}
+	if attribute == "" || strings.ContainsAny(attribute, "():*\\") {
+		return services.DirectoryFilter{}, ErrInvalidFilter
+	}
+
+	if op != services.FilterEqual || !strings.Contains(value, "*") {
+		unescaped, err := unescapeFilterValue(value)
+		if err != nil {
+			return services.DirectoryFilter{}, err
+		}
+		return services.DirectoryFilter{Op: op, Attribute: attribute, Value: unescaped}, nil
+	}
+
+	if value == "*" {
+		return services.DirectoryFilter{Op: services.FilterPresent, Attribute: attribute}, nil
+	}
+
+	// O '*' literal chega escapado como \2a, então dividir antes de remover
+	// os escapes separa apenas os curingas.
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		unescaped, err := unescapeFilterValue(part)
+		if err != nil {
+			return services.DirectoryFilter{}, err
+		}
+		parts[i] = unescaped
+	}
+
+	filter := services.DirectoryFilter{
+		Op:        services.FilterSubstring,
+		Attribute: attribute,
+		Initial:   parts[0],
+		Final:     parts[len(parts)-1],
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		if part != "" {
+			filter.Any = append(filter.Any, part)
+		}
+	}
+	return filter, nil
+}
+
+func unescapeFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", ErrInvalidFilter
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", ErrInvalidFilter
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
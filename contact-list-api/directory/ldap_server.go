@@ -0,0 +1,367 @@
+package directory
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/jimlambrt/gldap"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const DefaultBaseDN = "ou=contacts,dc=contact-list,dc=local"
+
+// ErrBindRequired é devolvido por NewServer quando não há BindDN e o bind
+// anônimo não foi liberado, o que deixaria o diretório sem nenhum acesso.
+var ErrBindRequired = errors.New("ldap bind DN is required unless anonymous bind is allowed")
+
+// Config define o listener LDAP. Toda busca exige um bind com BindDN e
+// BindPassword; o bind anônimo só é aceito com AllowAnonymous, e então
+// qualquer cliente que alcance o listener lê o diretório. O diretório
+// publica apenas a agenda compartilhada de TenantID (o tenant padrão quando
+// vazio), nunca as agendas de usuários.
+type Config struct {
+	BaseDN         string
+	BindDN         string
+	BindPassword   string
+	AllowAnonymous bool
+	TenantID       string
+}
+
+type ldapDirectory struct {
+	config Config
+	baseDN string
+
+	mu sync.Mutex
+	// bound guarda, por conexão, o DN do último bind com credenciais.
+	bound map[int]string
+}
+
+// NewServer cria o servidor LDAP somente leitura que expõe os contatos como
+// entradas inetOrgPerson sob o BaseDN. O chamador inicia o listener com Run.
+func NewServer(config Config) (*gldap.Server, error) {
+	if config.BaseDN == "" {
+		config.BaseDN = DefaultBaseDN
+	}
+	if config.BindDN == "" && !config.AllowAnonymous {
+		return nil, ErrBindRequired
+	}
+
+	d := &ldapDirectory{
+		config: config,
+		baseDN: normalizeDN(config.BaseDN),
+		bound:  make(map[int]string),
+	}
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		return nil, err
+	}
+	if err := mux.Bind(d.bind); err != nil {
+		return nil, err
+	}
+	if err := mux.Unbind(d.unbind); err != nil {
+		return nil, err
+	}
+	if err := mux.Search(d.search); err != nil {
+		return nil, err
+	}
+	if err := mux.DefaultRoute(d.readOnly); err != nil {
+		return nil, err
+	}
+
+	// Uma conexão fechada sem unbind deixaria o bind no mapa para sempre, e
+	// o ID poderia voltar numa conexão nova.
+	server, err := gldap.NewServer(gldap.WithOnClose(d.closed))
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Router(mux); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+func (d *ldapDirectory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer w.Write(resp)
+
+	msg, err := r.GetSimpleBindMessage()
+	if err != nil {
+		resp.SetResultCode(gldap.ResultProtocolError)
+		return
+	}
+
+	// Um novo bind troca a identidade da conexão, mesmo que falhe.
+	d.closed(r.ConnectionID())
+	anonymous := msg.UserName == "" && msg.Password == ""
+	switch {
+	case anonymous && d.config.AllowAnonymous:
+		resp.SetResultCode(gldap.ResultSuccess)
+	case d.config.BindDN != "" &&
+		normalizeDN(msg.UserName) == normalizeDN(d.config.BindDN) &&
+		subtle.ConstantTimeCompare([]byte(msg.Password), []byte(d.config.BindPassword)) == 1:
+		d.mu.Lock()
+		d.bound[r.ConnectionID()] = normalizeDN(d.config.BindDN)
+		d.mu.Unlock()
+		resp.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+func (d *ldapDirectory) unbind(w *gldap.ResponseWriter, r *gldap.Request) {
+	d.closed(r.ConnectionID())
+}
+
+// closed esquece o bind da conexão.
+func (d *ldapDirectory) closed(connectionID int) {
+	d.mu.Lock()
+	delete(d.bound, connectionID)
+	d.mu.Unlock()
+}
+
+func (d *ldapDirectory) readOnly(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewResponse(
+		gldap.WithResponseCode(gldap.ResultUnwillingToPerform),
+		gldap.WithDiagnosticMessage("directory is read-only"),
+	)
+	w.Write(resp)
+}
+
+// boundDN devolve o DN do bind da conexão, vazio sem bind com credenciais.
+func (d *ldapDirectory) boundDN(r *gldap.Request) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.bound[r.ConnectionID()]
+}
+
+func (d *ldapDirectory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer w.Write(resp)
+
+	// O LDAP não traz traceparent, então cada busca começa um trace próprio.
+	ctx, span := tracing.Start(context.Background(), "ldap.Search")
+	defer span.End()
+
+	boundDN := d.boundDN(r)
+	if boundDN == "" && !d.config.AllowAnonymous {
+		resp.SetResultCode(gldap.ResultInsufficientAccessRights)
+		return
+	}
+
+	msg, err := r.GetSearchMessage()
+	if err != nil {
+		resp.SetResultCode(gldap.ResultProtocolError)
+		return
+	}
+
+	filter, err := ParseFilter(msg.Filter)
+	if err != nil {
+		resp.SetResultCode(gldap.ResultProtocolError)
+		if errors.Is(err, ErrUnsupportedFilter) {
+			resp.SetResultCode(gldap.ResultUnwillingToPerform)
+		}
+		resp.SetDiagnosticMessage(err.Error())
+		return
+	}
+
+	baseDN := normalizeDN(msg.BaseDN)
+	var entries []ldapEntry
+	// Só as buscas que chegam aos contatos vão para a auditoria; a raiz e a
+	// OU não trazem dados de ninguém.
+	var sentContacts []int
+	searched := false
+	defer func() {
+		if searched {
+			d.audit(ctx, boundDN, sentContacts)
+		}
+	}()
+	switch {
+	case baseDN == "" && msg.Scope == gldap.BaseObject:
+		entries = append(entries, d.rootDSE())
+	case baseDN == d.baseDN:
+		if msg.Scope != gldap.SingleLevel {
+			entries = append(entries, d.baseEntry())
+		}
+		if msg.Scope != gldap.BaseObject {
+			searched = true
+			contacts, err := services.SearchDirectory(ctx, d.config.TenantID, models.SharedOwnerID, filter)
+			if err != nil {
+				resp.SetResultCode(gldap.ResultOperationsError)
+				resp.SetDiagnosticMessage(err.Error())
+				return
+			}
+			for _, contact := range contacts {
+				entries = append(entries, d.contactEntry(contact))
+			}
+		}
+	case strings.HasSuffix(baseDN, ","+d.baseDN) && msg.Scope != gldap.SingleLevel:
+		searched = true
+		entry, ok, err := d.entryByDN(ctx, baseDN)
+		if err != nil {
+			resp.SetResultCode(gldap.ResultOperationsError)
+			resp.SetDiagnosticMessage(err.Error())
+			return
+		}
+		if !ok {
+			resp.SetResultCode(gldap.ResultNoSuchObject)
+			return
+		}
+		entries = append(entries, entry)
+	case strings.HasSuffix(baseDN, ","+d.baseDN):
+		// Entradas de contato não têm filhos.
+	default:
+		resp.SetResultCode(gldap.ResultNoSuchObject)
+		resp.SetMatchedDN("")
+		return
+	}
+
+	sent := int64(0)
+	for _, entry := range entries {
+		// Os contatos já chegam filtrados pelo serviço; o filtro ainda é
+		// aplicado aqui para a raiz, a OU e buscas por DN.
+		if !filter.Matches(entry.attributes) {
+			continue
+		}
+		if msg.SizeLimit > 0 && sent >= msg.SizeLimit {
+			resp.SetResultCode(gldap.ResultSizeLimitExceeded)
+			return
+		}
+		w.Write(r.NewSearchResponseEntry(entry.dn, gldap.WithAttributes(selectAttributes(entry.attributes, msg.Attributes, msg.TypesOnly))))
+		sent++
+		if entry.contactID != 0 {
+			sentContacts = append(sentContacts, entry.contactID)
+		}
+	}
+}
+
+// audit registra a busca com os contatos enviados, em nome do DN do bind
+// da conexão ("ldap:anonymous" sem ele). Como nas rotas HTTP, a falha ao
+// gravar não falha a busca, cujas entradas já foram enviadas; fica no log do
+// servidor.
+func (d *ldapDirectory) audit(ctx context.Context, boundDN string, contactIDs []int) {
+	actor := "ldap:anonymous"
+	if boundDN != "" {
+		actor = "ldap:" + boundDN
+	}
+	if contactIDs == nil {
+		contactIDs = []int{}
+	}
+	_, err := services.RecordAccess(ctx, d.config.TenantID, models.AuditEntry{
+		Actor:      actor,
+		Action:     models.AuditSearch,
+		ContactIDs: contactIDs,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "audit entry not recorded", "action", models.AuditSearch, "route", "ldap", "error", err)
+	}
+}
+
+type ldapEntry struct {
+	dn         string
+	attributes map[string][]string
+	// contactID é zero nas entradas que não são contatos, como a raiz e a OU.
+	contactID int
+}
+
+func (d *ldapDirectory) rootDSE() ldapEntry {
+	return ldapEntry{
+		dn: "",
+		attributes: map[string][]string{
+			"objectclass":          {"top"},
+			"namingcontexts":       {d.config.BaseDN},
+			"supportedldapversion": {"3"},
+		},
+	}
+}
+
+func (d *ldapDirectory) baseEntry() ldapEntry {
+	ou := strings.TrimPrefix(strings.SplitN(d.baseDN, ",", 2)[0], "ou=")
+	return ldapEntry{
+		dn: d.config.BaseDN,
+		attributes: map[string][]string{
+			"objectclass": {"top", "organizationalUnit"},
+			"ou":          {ou},
+		},
+	}
+}
+
+func (d *ldapDirectory) contactEntry(contact models.Contact) ldapEntry {
+	return ldapEntry{
+		dn:         "uid=" + services.VCardUID(contact.ID) + "," + d.config.BaseDN,
+		attributes: services.DirectoryAttributes(contact),
+		contactID:  contact.ID,
+	}
+}
+
+func (d *ldapDirectory) entryByDN(ctx context.Context, dn string) (ldapEntry, bool, error) {
+	rdn := strings.TrimSuffix(dn, ","+d.baseDN)
+	if !strings.HasPrefix(rdn, "uid=") || strings.Contains(rdn, ",") {
+		return ldapEntry{}, false, nil
+	}
+
+	contacts, err := services.SearchDirectory(ctx, d.config.TenantID, models.SharedOwnerID, services.DirectoryFilter{
+		Op:        services.FilterEqual,
+		Attribute: "uid",
+		Value:     strings.TrimPrefix(rdn, "uid="),
+	})
+	if err != nil || len(contacts) == 0 {
+		return ldapEntry{}, false, err
+	}
+	return d.contactEntry(contacts[0]), true, nil
+}
+
+// selectAttributes aplica a lista de atributos pedida na busca: vazia ou "*"
+// devolve todos, "1.1" nenhum. Os nomes são comparados sem diferenciar
+// maiúsculas, mas a resposta usa a grafia canônica do inetOrgPerson.
+func selectAttributes(attributes map[string][]string, requested []string, typesOnly bool) map[string][]string {
+	all := len(requested) == 0
+	wanted := make(map[string]bool, len(requested))
+	for _, name := range requested {
+		if name == "*" {
+			all = true
+		}
+		wanted[strings.ToLower(name)] = true
+	}
+
+	selected := make(map[string][]string)
+	for name, values := range attributes {
+		if !all && !wanted[name] {
+			continue
+		}
+		if typesOnly {
+			values = []string{}
+		}
+		selected[canonicalAttributeName(name)] = values
+	}
+	return selected
+}
+
+var canonicalAttributeNames = map[string]string{
+	"objectclass":          "objectClass",
+	"displayname":          "displayName",
+	"givenname":            "givenName",
+	"telephonenumber":      "telephoneNumber",
+	"namingcontexts":       "namingContexts",
+	"supportedldapversion": "supportedLDAPVersion",
+}
+
+func canonicalAttributeName(name string) string {
+	if canonical, ok := canonicalAttributeNames[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,229 @@
+// Package fieldcrypt cifra campos individuais com AES-256-GCM e calcula
+// índices cegos (HMAC-SHA256) que permitem buscas exatas sem decifrar os
+// dados.
+package fieldcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize é o tamanho exigido para as chaves de cifra e de índice.
+const KeySize = 32
+
+// encryptedPrefix marca os valores cifrados; valores sem ele são lidos como
+// texto puro, o que permite migrar arquivos antigos aos poucos.
+const encryptedPrefix = "enc:v1:"
+
+var (
+	ErrNoKeys         = errors.New("fieldcrypt: at least one key is required")
+	ErrInvalidKey     = errors.New("fieldcrypt: keys must have 32 bytes")
+	ErrInvalidKeyID   = errors.New("fieldcrypt: key ids must be unique and must not contain ':'")
+	ErrInvalidIndex   = errors.New("fieldcrypt: the blind index key must have 32 bytes")
+	ErrUnknownKey     = errors.New("fieldcrypt: value encrypted with an unknown key")
+	ErrMalformedValue = errors.New("fieldcrypt: malformed encrypted value")
+)
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring guarda as chaves de cifra e a chave dos índices cegos. A primeira
+// chave cifra os novos valores; as demais só decifram dados ainda não
+// recifrados. A chave de índice não participa da rotação, para que os
+// índices continuem comparáveis.
+type Keyring struct {
+	active   Key
+	ciphers  map[string]cipher.AEAD
+	indexKey []byte
+}
+
+func NewKeyring(keys []Key, indexKey []byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if len(indexKey) != KeySize {
+		return nil, ErrInvalidIndex
+	}
+
+	ciphers := make(map[string]cipher.AEAD, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, ErrInvalidKeyID
+		}
+		if _, ok := ciphers[key.ID]; ok {
+			return nil, ErrInvalidKeyID
+		}
+		if len(key.Secret) != KeySize {
+			return nil, ErrInvalidKey
+		}
+
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ciphers[key.ID] = aead
+	}
+
+	return &Keyring{active: keys[0], ciphers: ciphers, indexKey: indexKey}, nil
+}
+
+// ActiveKeyID devolve o ID da chave usada para cifrar.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active.ID
+}
+
+// Encrypt cifra o valor com a chave ativa no formato
+// "enc:v1:<id da chave>:<nonce e texto cifrado em base64>". Os dados
+// associados (aad) não vão no valor, mas precisam ser os mesmos para
+// decifrá-lo; assim o valor não pode ser copiado para outro registro ou
+// campo. Valores vazios continuam vazios.
+func (k *Keyring) Encrypt(plaintext string, aad []byte) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	aead := k.ciphers[k.active.ID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), aad)
+	return encryptedPrefix + k.active.ID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt devolve o texto puro, conferindo os mesmos dados associados
+// passados a Encrypt. Valores sem o prefixo de cifra são devolvidos como
+// estão.
+func (k *Keyring) Decrypt(value string, aad []byte) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	keyID, encoded, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", ErrMalformedValue
+	}
+	aead, ok := k.ciphers[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedValue
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+	if err != nil {
+		return "", fmt.Errorf("fieldcrypt: decrypting with key %q: %w", keyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation indica se o valor está em texto puro ou cifrado com uma
+// chave que não é a ativa.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	return !strings.HasPrefix(value, encryptedPrefix+k.active.ID+":")
+}
+
+// BlindIndex calcula o índice determinístico de um valor já normalizado. O
+// contexto separa índices de campos diferentes, para que o mesmo texto em
+// e-mail e telefone não gere o mesmo índice.
+func (k *Keyring) BlindIndex(context, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(context))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// ParseKeyFile lê um arquivo de chaves com uma chave por linha, no formato
+// "<id>:<chave de 32 bytes em base64>". A primeira linha é a chave ativa;
+// linhas vazias e iniciadas por # são ignoradas.
+func ParseKeyFile(data []byte) ([]Key, error) {
+	var keys []Key
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("fieldcrypt: line %d: expected <id>:<base64 key>", line)
+		}
+		secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: line %d: %w", line, err)
+		}
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: secret})
+	}
+
+	return keys, scanner.Err()
+}
+
+// KeyringFromEnv monta o chaveiro a partir de FIELD_ENCRYPTION_KEY_FILE (ou
+// FIELD_ENCRYPTION_KEY, uma única chave em base64 com ID "default") e de
+// FIELD_INDEX_KEY. Sem chave configurada, devolve nil e a cifra fica
+// desligada.
+func KeyringFromEnv() (*Keyring, error) {
+	var keys []Key
+
+	if path := os.Getenv("FIELD_ENCRYPTION_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading FIELD_ENCRYPTION_KEY_FILE: %w", err)
+		}
+		keys, err = ParseKeyFile(data)
+		if err != nil {
+			return nil, err
+		}
+	} else if encoded := os.Getenv("FIELD_ENCRYPTION_KEY"); encoded != "" {
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding FIELD_ENCRYPTION_KEY: %w", err)
+		}
+		keys = []Key{{ID: "default", Secret: secret}}
+	} else {
+		return nil, nil
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(os.Getenv("FIELD_INDEX_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("decoding FIELD_INDEX_KEY: %w", err)
+	}
+
+	return NewKeyring(keys, indexKey)
+}
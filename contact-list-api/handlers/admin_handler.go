@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+)
+
+// ReencryptContacts recifra os contatos com a chave ativa
+// @Summary Recifra os contatos
+// @Description Depois de incluir uma nova chave no topo de FIELD_ENCRYPTION_KEY_FILE e reiniciar o servidor, recifra e-mail e telefone de todos os tenants com ela. Ao terminar, as chaves antigas podem ser removidas do arquivo. Apenas a chave de bootstrap pode usar.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} services.ReencryptionResult
+// @Failure 401,403 {object} handlers.HTTPError
+// @Failure 409 {object} handlers.HTTPError "Cifra de campos não configurada"
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /admin/encryption/reencrypt [post]
+func ReencryptContacts(c *gin.Context) {
+	ctx := c.Request.Context()
+	results, err := services.ReencryptAllContacts(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrEncryptionDisabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// CheckStorageIntegrity confere os arquivos de contatos
+// @Summary Confere os arquivos de contatos
+// @Description Lê, sem alterar nada, o contacts.json, o changes.json e o journal do tenant padrão e de todos os tenants cadastrados, e aponta a linha e a coluna do que não se lê. Apenas a chave de bootstrap pode usar.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} storage.IntegrityReport
+// @Failure 401,403 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /admin/storage/integrity [get]
+func CheckStorageIntegrity(c *gin.Context) {
+	reports, err := services.CheckAllContacts(c.Request.Context())
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+type RecoveryRequest struct {
+	Mode storage.RecoveryMode `json:"mode" binding:"required" example:"backup"`
+}
+
+// RecoverStorage recupera os arquivos de contatos corrompidos
+// @Summary Recupera os arquivos de contatos
+// @Description Recupera os tenants com arquivos corrompidos, que vão para quarantine/. Com "backup", contacts.json volta ao backup mais novo que se lê, com o journal reaplicado; com "salvage", aproveita cada contato que ainda se lê no próprio arquivo. Um tenant já carregado é regravado a partir da memória. Apenas a chave de bootstrap pode usar.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param mode body handlers.RecoveryRequest true "Modo de recuperação"
+// @Success 200 {array} storage.RecoveryResult
+// @Failure 400,401,403 {object} handlers.HTTPError
+// @Failure 409 {object} handlers.HTTPError "Nenhum backup legível"
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /admin/storage/recover [post]
+func RecoverStorage(c *gin.Context) {
+	var req RecoveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Mode != storage.RecoveryBackup && req.Mode != storage.RecoverySalvage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recovery mode, expected backup or salvage"})
+		return
+	}
+
+	results, err := services.RecoverAllContacts(c.Request.Context(), req.Mode)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoGoodBackup) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level" binding:"required" example:"debug"`
+}
+
+// GetLogLevel mostra o nível atual dos logs
+// @Summary Nível dos logs
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} handlers.LogLevelRequest
+// @Failure 401,403 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /admin/log-level [get]
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, LogLevelRequest{Level: logging.Level()})
+}
+
+// SetLogLevel troca o nível dos logs sem reiniciar o servidor
+// @Summary Troca o nível dos logs
+// @Description Aceita debug, info, warn ou error. Vale até a próxima troca ou até o servidor reiniciar, quando volta a LOG_LEVEL. Apenas a chave de bootstrap pode usar.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param level body handlers.LogLevelRequest true "Novo nível"
+// @Success 200 {object} handlers.LogLevelRequest
+// @Failure 400,401,403 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /admin/log-level [put]
+func SetLogLevel(c *gin.Context) {
+	var req LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := logging.SetLevel(req.Level); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "log level changed", "level", logging.Level())
+	c.JSON(http.StatusOK, LogLevelRequest{Level: logging.Level()})
+}
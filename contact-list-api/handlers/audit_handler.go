@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+// GetAuditLog consulta o log de auditoria de acesso
+// @Summary Consulta o log de auditoria
+// @Description Lista, das mais recentes para as mais antigas, as entradas de quem leu, listou, buscou, exportou, criou, alterou ou removeu contatos do tenant.
+// @Tags Audit
+// @Produce json
+// @Param actor query string false "Quem fez o acesso, como api-key:1 ou o nome do usuário"
+// @Param action query string false "read, list, search, export, create, update ou delete"
+// @Param contact_id query int false "Apenas entradas que envolvem o contato"
+// @Param from query string false "Início do período (RFC 3339)"
+// @Param to query string false "Fim do período (RFC 3339)"
+// @Param limit query int false "Máximo de entradas (padrão 100, até 1000)"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit/ [get]
+func GetAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	filter := services.AuditFilter{Actor: c.Query("actor"), Action: c.Query("action")}
+
+	var err error
+	if value := c.Query("contact_id"); value != "" {
+		if filter.ContactID, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "contact_id deve ser número"})
+			return
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit deve ser número"})
+			return
+		}
+	}
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from deve estar no formato RFC 3339"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to deve estar no formato RFC 3339"})
+			return
+		}
+	}
+
+	entries, err := services.QueryAuditLog(ctx, tenancy.TenantID(c), filter)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAuditAction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// VerifyAuditLog verifica a integridade do log de auditoria
+// @Summary Verifica a cadeia de hashes do log de auditoria
+// @Description Refaz o HMAC de cada entrada, confere o encadeamento e compara o fim do log com o topo da cadeia gravado à parte. Uma entrada alterada, removida, inserida fora de ordem ou cortada do fim aparece em broken_at.
+// @Tags Audit
+// @Produce json
+// @Success 200 {object} services.AuditVerification
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit/verify [get]
+func VerifyAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	result, err := services.VerifyAuditLog(ctx, tenancy.TenantID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// recordAudit registra o acesso da requisição aos contatos, em nome do
+// principal autenticado.
+func recordAudit(c *gin.Context, action string, contactIDs []int) {
+	principal, _ := auth.CurrentPrincipal(c)
+	recordAuditAs(c, tenancy.TenantID(c), principal.Subject, action, contactIDs)
+}
+
+// recordAuditAs registra o acesso em nome de actor, para as rotas sem
+// principal, como os links públicos. A falha ao gravar não falha a
+// requisição: o registro só é feito depois que os contatos foram lidos e,
+// nos streams, enviados, então recusar a resposta não desfaria o acesso. A
+// falha fica no log do servidor, como "audit entry not recorded". Pelo mesmo
+// motivo, o registro não é cancelado quando o prazo da requisição vence ou o
+// cliente desconecta.
+func recordAuditAs(c *gin.Context, tenantID, actor, action string, contactIDs []int) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	_, err := services.RecordAccess(ctx, tenantID, models.AuditEntry{
+		Actor:      actor,
+		Action:     action,
+		ContactIDs: contactIDs,
+		IP:         c.ClientIP(),
+		RequestID:  logging.GetRequestID(c),
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "audit entry not recorded", "action", action, "route", c.FullPath(), "error", err)
+	}
+}
+
+func contactIDs(contacts []models.Contact) []int {
+	ids := make([]int, 0, len(contacts))
+	for _, contact := range contacts {
+		ids = append(ids, contact.ID)
+	}
+	return ids
+}
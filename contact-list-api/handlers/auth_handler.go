@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required" example:"dashboard"`
+	Role string `json:"role" binding:"required" example:"reader"`
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key" example:"clk_1a2b3c4d_..."`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+type CredentialsRequest struct {
+	Username string `json:"username" binding:"required" example:"joao"`
+	Password string `json:"password" binding:"required" example:"uma-senha-longa"`
+}
+
+type LoginResponse struct {
+	Token     string    `json:"token" example:"cls_..."`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RegisterUser cria uma conta de usuário
+// @Summary Cria uma conta
+// @Description Cada conta tem uma agenda própria, visível apenas para ela. A senha é guardada como hash bcrypt.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body handlers.CredentialsRequest true "Usuário e senha"
+// @Success 201 {object} models.User
+// @Failure 400,409 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Router /auth/register [post]
+func RegisterUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.RegisterUser(ctx, tenancy.TenantID(c), req.Username, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUsername), errors.Is(err, services.ErrWeakPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// Login autentica um usuário e emite um token de sessão
+// @Summary Login
+// @Description Envie o token devolvido como "Authorization: Bearer <token>". Ele só é exibido nesta resposta.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body handlers.CredentialsRequest true "Usuário e senha"
+// @Success 200 {object} handlers.LoginResponse
+// @Failure 400,401 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Router /auth/login [post]
+func Login(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, session, err := services.Login(ctx, tenancy.TenantID(c), req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{Token: token, ExpiresAt: session.ExpiresAt})
+}
+
+// Logout encerra a sessão atual
+// @Summary Logout
+// @Tags Auth
+// @Success 204 "No Content"
+// @Failure 400,401 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	ctx := c.Request.Context()
+	principal, _ := auth.CurrentPrincipal(c)
+	if principal.Method != auth.MethodSession {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "logout requires a session token"})
+		return
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer"))
+	if err := services.Logout(ctx, tenancy.TenantID(c), token); err != nil {
+		if errors.Is(err, services.ErrInvalidSession) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetCurrentPrincipal retorna quem está autenticado
+// @Summary Identidade autenticada
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} auth.Principal
+// @Failure 401 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/me [get]
+func GetCurrentPrincipal(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+	c.JSON(http.StatusOK, principal)
+}
+
+// CreateAPIKey cria uma chave de API
+// @Summary Cria uma chave de API
+// @Description A chave em texto puro é devolvida apenas nesta resposta; o servidor guarda somente o hash.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body handlers.CreateAPIKeyRequest true "Nome e papel (intern, reader, editor ou admin)"
+// @Success 201 {object} handlers.CreateAPIKeyResponse
+// @Failure 400,401,403 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plaintext, key, err := services.CreateAPIKey(ctx, tenancy.TenantID(c), req.Name, req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{Key: plaintext, APIKey: key})
+}
+
+// GetAPIKeys lista as chaves de API
+// @Summary Lista chaves de API
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401,403 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/api-keys [get]
+func GetAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	keys, err := services.GetAllAPIKeys(ctx, tenancy.TenantID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey revoga uma chave de API
+// @Summary Revoga uma chave de API
+// @Tags Auth
+// @Param id path int true "ID da chave"
+// @Success 204 "No Content"
+// @Failure 400,401,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := services.RevokeAPIKey(ctx, tenancy.TenantID(c), id); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+// Caminhos do servidor CardDAV. A lista de contatos é exposta como um único
+// address book somente leitura.
+const (
+	CardDAVRoot        = "/carddav/"
+	cardDAVPrincipal   = CardDAVRoot + "principal/"
+	cardDAVAddressBook = CardDAVRoot + "contacts/"
+	cardDAVSyncPrefix  = "urn:contact-list-api:sync:"
+	cardDAVAllow       = "OPTIONS, GET, HEAD, PROPFIND, REPORT"
+)
+
+// Propriedades devolvidas em um PROPFIND sem corpo ou com <allprop/>, na
+// ordem em que aparecem na resposta.
+var cardDAVAllProps = []davName{
+	propResourceType,
+	propDisplayName,
+	propCurrentUserPrincipal,
+	propPrincipalURL,
+	propAddressBookHomeSet,
+	propAddressBookDescription,
+	propSupportedReportSet,
+	propSupportedAddressData,
+	propSyncToken,
+	propGetCTag,
+	propGetETag,
+	propGetContentType,
+}
+
+// CardDAVWellKnown redireciona a descoberta automática (RFC 6764) para a raiz
+// do servidor CardDAV.
+func CardDAVWellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, CardDAVRoot)
+}
+
+// CardDAV atende todos os métodos WebDAV sob /carddav (RFC 6352).
+func CardDAV(c *gin.Context) {
+	path := "/carddav" + c.Param("path")
+
+	switch c.Request.Method {
+	case http.MethodOptions:
+		c.Header("DAV", "1, 3, addressbook")
+		c.Header("Allow", cardDAVAllow)
+		c.Status(http.StatusOK)
+	case "PROPFIND":
+		cardDAVPropfind(c, path)
+		auditCards(c, models.AuditList)
+	case "REPORT":
+		cardDAVReport(c, path)
+		auditCards(c, models.AuditList)
+	case http.MethodGet, http.MethodHead:
+		cardDAVGet(c, path)
+		auditCards(c, models.AuditRead)
+	default:
+		c.Header("Allow", cardDAVAllow)
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func cardDAVPropfind(c *gin.Context, path string) {
+	ctx := c.Request.Context()
+	req, err := parseDAVRequest(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	depth := c.GetHeader("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+
+	var resources []davResource
+	switch {
+	case path == CardDAVRoot || path+"/" == CardDAVRoot:
+		resources = append(resources, homeResource())
+		if depth != "0" {
+			book, err := addressBookResource(c.Request.Context(), tenancy.TenantID(c))
+			if err != nil {
+				writeInternalError(c, err)
+				return
+			}
+			resources = append(resources, book)
+		}
+	case path == cardDAVPrincipal || path+"/" == cardDAVPrincipal:
+		resources = append(resources, principalResource())
+	case path == cardDAVAddressBook || path+"/" == cardDAVAddressBook:
+		book, err := addressBookResource(c.Request.Context(), tenancy.TenantID(c))
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		resources = append(resources, book)
+		if depth != "0" {
+			contacts, err := services.GetAllContacts(ctx, tenancy.TenantID(c), auth.OwnerID(c))
+			if err != nil {
+				writeInternalError(c, err)
+				return
+			}
+			for _, contact := range contacts {
+				resources = append(resources, cardResource(c, contact))
+			}
+		}
+	default:
+		contact, ok, err := cardFromPath(c.Request.Context(), tenancy.TenantID(c), auth.OwnerID(c), path)
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		resources = append(resources, cardResource(c, contact))
+	}
+
+	ms := newMultistatusWriter()
+	for _, resource := range resources {
+		ms.writeResource(resource, requestedProps(req, resource))
+	}
+	writeMultistatus(c, ms)
+}
+
+func requestedProps(req davRequest, resource davResource) []davName {
+	if !req.AllProp && len(req.Props) > 0 {
+		return req.Props
+	}
+	var props []davName
+	for _, name := range cardDAVAllProps {
+		if _, ok := resource.Props[name]; ok {
+			props = append(props, name)
+		}
+	}
+	return props
+}
+
+func cardDAVReport(c *gin.Context, path string) {
+	if path != cardDAVAddressBook && path+"/" != cardDAVAddressBook {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	req, err := parseDAVRequest(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch req.Root {
+	case davName{nsCardDAV, "addressbook-multiget"}:
+		cardDAVMultiget(c, req)
+	case davName{nsCardDAV, "addressbook-query"}:
+		cardDAVQuery(c, req)
+	case davName{nsDAV, "sync-collection"}:
+		cardDAVSyncCollection(c, req)
+	default:
+		writeDAVError(c, http.StatusForbidden, "<d:supported-report/>")
+	}
+}
+
+func cardDAVMultiget(c *gin.Context, req davRequest) {
+	ms := newMultistatusWriter()
+	for _, href := range req.Hrefs {
+		contact, ok, err := cardFromPath(c.Request.Context(), tenancy.TenantID(c), auth.OwnerID(c), href)
+		if err != nil {
+			writeInternalError(c, err)
+			return
+		}
+		if !ok {
+			ms.writeStatus(href, "HTTP/1.1 404 Not Found")
+			continue
+		}
+		resource := cardResource(c, contact)
+		resource.Href = href
+		ms.writeResource(resource, requestedProps(req, resource))
+	}
+	writeMultistatus(c, ms)
+}
+
+func cardDAVQuery(c *gin.Context, req davRequest) {
+	ctx := c.Request.Context()
+	contacts, err := services.GetAllContacts(ctx, tenancy.TenantID(c), auth.OwnerID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	ms := newMultistatusWriter()
+	for _, contact := range contacts {
+		// O filtro vê o mesmo que a resposta, para que não sirva para descobrir
+		// dados mascarados.
+		contact = maskedContact(c, contact)
+		if req.Filter != nil && !req.Filter.matches(contact) {
+			continue
+		}
+		resource := cardResource(c, contact)
+		ms.writeResource(resource, requestedProps(req, resource))
+	}
+	writeMultistatus(c, ms)
+}
+
+func cardDAVSyncCollection(c *gin.Context, req davRequest) {
+	ctx := c.Request.Context()
+	token := ""
+	if req.SyncToken != "" {
+		if !strings.HasPrefix(req.SyncToken, cardDAVSyncPrefix) {
+			writeDAVError(c, http.StatusForbidden, "<d:valid-sync-token/>")
+			return
+		}
+		token = strings.TrimPrefix(req.SyncToken, cardDAVSyncPrefix)
+	}
+
+	result, err := services.SyncContacts(ctx, tenancy.TenantID(c), auth.OwnerID(c), token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSyncToken) || errors.Is(err, services.ErrSyncTokenExpired) {
+			writeDAVError(c, http.StatusForbidden, "<d:valid-sync-token/>")
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	ms := newMultistatusWriter()
+	for _, contact := range result.Changed {
+		resource := cardResource(c, contact)
+		ms.writeResource(resource, requestedProps(req, resource))
+	}
+	for _, tombstone := range result.Deleted {
+		ms.writeStatus(cardHref(tombstone.ID), "HTTP/1.1 404 Not Found")
+	}
+	ms.writeSyncToken(cardDAVSyncPrefix + result.NextToken)
+	writeMultistatus(c, ms)
+}
+
+func cardDAVGet(c *gin.Context, path string) {
+	contact, ok, err := cardFromPath(c.Request.Context(), tenancy.TenantID(c), auth.OwnerID(c), path)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+	if !ok {
+		c.Header("Allow", cardDAVAllow)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	markAuditedCard(c, contact.ID)
+	body := services.ContactToVCard(maskedContact(c, contact))
+	etag := vCardETag(body)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "text/vcard; charset=utf-8", []byte(body))
+}
+
+// cardFromPath resolve um href do tipo /carddav/contacts/<id>.vcf. O segundo
+// retorno é false quando o caminho não corresponde a um contato existente.
+func cardFromPath(ctx context.Context, tenantID string, ownerID int, path string) (models.Contact, bool, error) {
+	if u, err := url.Parse(path); err == nil {
+		path = u.Path
+	}
+	if !strings.HasPrefix(path, cardDAVAddressBook) || !strings.HasSuffix(path, ".vcf") {
+		return models.Contact{}, false, nil
+	}
+
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, cardDAVAddressBook), ".vcf"))
+	if err != nil {
+		return models.Contact{}, false, nil
+	}
+
+	contact, err := services.GetContactByID(ctx, tenantID, ownerID, id)
+	if errors.Is(err, services.ErrContactNotFound) {
+		return models.Contact{}, false, nil
+	}
+	if err != nil {
+		return models.Contact{}, false, err
+	}
+	return contact, true, nil
+}
+
+func cardHref(id int) string {
+	return cardDAVAddressBook + strconv.Itoa(id) + ".vcf"
+}
+
+func vCardETag(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func currentUserPrincipalXML() string {
+	return "<d:href>" + cardDAVPrincipal + "</d:href>"
+}
+
+func homeResource() davResource {
+	return davResource{
+		Href: CardDAVRoot,
+		Props: map[davName]string{
+			propResourceType:         "<d:collection/>",
+			propDisplayName:          "Contact List API",
+			propCurrentUserPrincipal: currentUserPrincipalXML(),
+			propAddressBookHomeSet:   "<d:href>" + CardDAVRoot + "</d:href>",
+		},
+	}
+}
+
+func principalResource() davResource {
+	return davResource{
+		Href: cardDAVPrincipal,
+		Props: map[davName]string{
+			propResourceType:         "<d:principal/>",
+			propDisplayName:          "Contact List API",
+			propCurrentUserPrincipal: currentUserPrincipalXML(),
+			propPrincipalURL:         currentUserPrincipalXML(),
+			propAddressBookHomeSet:   "<d:href>" + CardDAVRoot + "</d:href>",
+		},
+	}
+}
+
+func addressBookResource(ctx context.Context, tenantID string) (davResource, error) {
+	token, err := services.CurrentSyncToken(ctx, tenantID)
+	if err != nil {
+		return davResource{}, err
+	}
+	syncToken := escapeXML(cardDAVSyncPrefix + token)
+
+	return davResource{
+		Href: cardDAVAddressBook,
+		Props: map[davName]string{
+			propResourceType:           "<d:collection/><card:addressbook/>",
+			propDisplayName:            "Contatos",
+			propAddressBookDescription: "Lista de contatos",
+			propCurrentUserPrincipal:   currentUserPrincipalXML(),
+			propSupportedReportSet: "<d:supported-report><d:report><card:addressbook-multiget/></d:report></d:supported-report>" +
+				"<d:supported-report><d:report><card:addressbook-query/></d:report></d:supported-report>" +
+				"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>",
+			propSupportedAddressData: `<card:address-data-type content-type="text/vcard" version="3.0"/>`,
+			propSyncToken:            syncToken,
+			propGetCTag:              syncToken,
+		},
+	}, nil
+}
+
+// cardResource devolve o vCard do contato como o papel autenticado deve vê-lo
+// e anota o contato para a auditoria da requisição.
+func cardResource(c *gin.Context, contact models.Contact) davResource {
+	markAuditedCard(c, contact.ID)
+	body := services.ContactToVCard(maskedContact(c, contact))
+	return davResource{
+		Href: cardHref(contact.ID),
+		Props: map[davName]string{
+			propResourceType:         "",
+			propDisplayName:          escapeXML(contact.Name),
+			propCurrentUserPrincipal: currentUserPrincipalXML(),
+			propGetETag:              escapeXML(vCardETag(body)),
+			propGetContentType:       "text/vcard; charset=utf-8",
+			propAddressData:          escapeXML(body),
+		},
+	}
+}
+
+func writeMultistatus(c *gin.Context, ms *multistatusWriter) {
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", ms.bytes())
+}
+
+func writeDAVError(c *gin.Context, status int, condition string) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<d:error xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">` + condition + `</d:error>`
+	c.Data(status, "application/xml; charset=utf-8", []byte(body))
+}
+
+// matches avalia o filtro de um addressbook-query contra o contato,
+// considerando as propriedades FN, N, EMAIL, TEL e UID do vCard gerado.
+func (f *cardFilter) matches(contact models.Contact) bool {
+	if len(f.PropFilters) == 0 {
+		return true
+	}
+
+	values := map[string]string{
+		"FN":    contact.Name,
+		"N":     contact.Name,
+		"EMAIL": strings.TrimSpace(contact.Email),
+		"TEL":   strings.TrimSpace(contact.Phone),
+		"UID":   services.VCardUID(contact.ID),
+	}
+
+	for _, pf := range f.PropFilters {
+		ok := pf.matches(values[pf.Name])
+		if ok && !f.AllOf {
+			return true
+		}
+		if !ok && f.AllOf {
+			return false
+		}
+	}
+	return f.AllOf
+}
+
+func (pf cardPropFilter) matches(value string) bool {
+	if pf.IsNotDefined {
+		return value == ""
+	}
+	if value == "" {
+		return false
+	}
+	if len(pf.TextMatches) == 0 {
+		return true
+	}
+
+	for _, tm := range pf.TextMatches {
+		ok := tm.matches(value)
+		if ok && !pf.AllOf {
+			return true
+		}
+		if !ok && pf.AllOf {
+			return false
+		}
+	}
+	return pf.AllOf
+}
+
+func (tm cardTextMatch) matches(value string) bool {
+	value, needle := strings.ToLower(value), strings.ToLower(tm.Value)
+
+	var ok bool
+	switch tm.MatchType {
+	case "equals":
+		ok = value == needle
+	case "starts-with":
+		ok = strings.HasPrefix(value, needle)
+	case "ends-with":
+		ok = strings.HasSuffix(value, needle)
+	default:
+		ok = strings.Contains(value, needle)
+	}
+
+	return ok != tm.Negate
+}
+
+const auditedCardsKey = "carddav.audited"
+
+// markAuditedCard anota um contato exposto pela requisição. Um REPORT ou
+// PROPFIND devolve vários vCards; auditCards os registra numa única entrada.
+func markAuditedCard(c *gin.Context, id int) {
+	ids, _ := c.Get(auditedCardsKey)
+	list, _ := ids.([]int)
+	c.Set(auditedCardsKey, append(list, id))
+}
+
+func auditCards(c *gin.Context, action string) {
+	value, ok := c.Get(auditedCardsKey)
+	if !ok {
+		return
+	}
+	recordAudit(c, action, value.([]int))
+}
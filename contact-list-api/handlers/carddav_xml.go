@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	nsDAV     = "DAV:"
+	nsCardDAV = "urn:ietf:params:xml:ns:carddav"
+	nsCS      = "http://calendarserver.org/ns/"
+)
+
+var davPrefixes = map[string]string{
+	nsDAV:     "d",
+	nsCardDAV: "card",
+	nsCS:      "cs",
+}
+
+type davName struct {
+	Space string
+	Local string
+}
+
+var (
+	propResourceType           = davName{nsDAV, "resourcetype"}
+	propDisplayName            = davName{nsDAV, "displayname"}
+	propGetETag                = davName{nsDAV, "getetag"}
+	propGetContentType         = davName{nsDAV, "getcontenttype"}
+	propCurrentUserPrincipal   = davName{nsDAV, "current-user-principal"}
+	propPrincipalURL           = davName{nsDAV, "principal-URL"}
+	propSyncToken              = davName{nsDAV, "sync-token"}
+	propSupportedReportSet     = davName{nsDAV, "supported-report-set"}
+	propGetCTag                = davName{nsCS, "getctag"}
+	propAddressBookHomeSet     = davName{nsCardDAV, "addressbook-home-set"}
+	propAddressData            = davName{nsCardDAV, "address-data"}
+	propSupportedAddressData   = davName{nsCardDAV, "supported-address-data"}
+	propAddressBookDescription = davName{nsCardDAV, "addressbook-description"}
+)
+
+// davResource é um recurso WebDAV com os valores das propriedades já
+// serializados em XML.
+type davResource struct {
+	Href  string
+	Props map[davName]string
+}
+
+// davRequest reúne o que interessa dos corpos de PROPFIND e REPORT.
+type davRequest struct {
+	Root      davName
+	AllProp   bool
+	Props     []davName
+	Hrefs     []string
+	SyncToken string
+	Filter    *cardFilter
+}
+
+type cardFilter struct {
+	AllOf       bool
+	PropFilters []cardPropFilter
+}
+
+type cardPropFilter struct {
+	Name         string
+	AllOf        bool
+	IsNotDefined bool
+	TextMatches  []cardTextMatch
+}
+
+type cardTextMatch struct {
+	Value     string
+	MatchType string
+	Negate    bool
+}
+
+var errMalformedDAVBody = errors.New("malformed xml body")
+
+func parseDAVRequest(body io.Reader) (davRequest, error) {
+	var req davRequest
+	decoder := xml.NewDecoder(body)
+
+	var stack []davName
+	var text strings.Builder
+	var propFilter *cardPropFilter
+	var textMatch *cardTextMatch
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return req, errMalformedDAVBody
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := davName{t.Name.Space, t.Name.Local}
+			parent := davName{}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			stack = append(stack, name)
+			text.Reset()
+
+			if len(stack) == 1 {
+				req.Root = name
+				continue
+			}
+
+			switch {
+			case parent == davName{nsDAV, "prop"} && len(stack) == 3:
+				req.Props = append(req.Props, name)
+			case name == davName{nsDAV, "allprop"}:
+				req.AllProp = true
+			case name == davName{nsCardDAV, "filter"}:
+				req.Filter = &cardFilter{AllOf: attr(t, "test") == "allof"}
+			case name == davName{nsCardDAV, "prop-filter"} && req.Filter != nil:
+				req.Filter.PropFilters = append(req.Filter.PropFilters, cardPropFilter{
+					Name:  strings.ToUpper(attr(t, "name")),
+					AllOf: attr(t, "test") == "allof",
+				})
+				propFilter = &req.Filter.PropFilters[len(req.Filter.PropFilters)-1]
+			case name == davName{nsCardDAV, "is-not-defined"} && propFilter != nil:
+				propFilter.IsNotDefined = true
+			case name == davName{nsCardDAV, "text-match"} && propFilter != nil:
+				matchType := attr(t, "match-type")
+				if matchType == "" {
+					matchType = "contains"
+				}
+				propFilter.TextMatches = append(propFilter.TextMatches, cardTextMatch{
+					MatchType: matchType,
+					Negate:    attr(t, "negate-condition") == "yes",
+				})
+				textMatch = &propFilter.TextMatches[len(propFilter.TextMatches)-1]
+			}
+
+		case xml.CharData:
+			text.Write(t)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return req, errMalformedDAVBody
+			}
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			switch {
+			case name == davName{nsDAV, "href"} && len(stack) == 1:
+				req.Hrefs = append(req.Hrefs, strings.TrimSpace(text.String()))
+			case name == davName{nsDAV, "sync-token"} && len(stack) == 1:
+				req.SyncToken = strings.TrimSpace(text.String())
+			case name == davName{nsCardDAV, "text-match"} && textMatch != nil:
+				textMatch.Value = strings.TrimSpace(text.String())
+				textMatch = nil
+			case name == davName{nsCardDAV, "prop-filter"}:
+				propFilter = nil
+			}
+		}
+	}
+
+	return req, nil
+}
+
+func attr(element xml.StartElement, local string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func escapeXML(value string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// multistatusWriter monta a resposta 207 Multi-Status.
+type multistatusWriter struct {
+	b bytes.Buffer
+}
+
+func newMultistatusWriter() *multistatusWriter {
+	w := &multistatusWriter{}
+	w.b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	w.b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:cs="http://calendarserver.org/ns/">`)
+	return w
+}
+
+// writeResource escreve um <d:response> com as propriedades pedidas,
+// separando as encontradas (200) das inexistentes (404).
+func (w *multistatusWriter) writeResource(resource davResource, requested []davName) {
+	var found, missing []davName
+	for _, name := range requested {
+		if _, ok := resource.Props[name]; ok {
+			found = append(found, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	w.b.WriteString("<d:response><d:href>" + escapeXML(resource.Href) + "</d:href>")
+	if len(found) > 0 {
+		w.b.WriteString("<d:propstat><d:prop>")
+		for _, name := range found {
+			w.writeProp(name, resource.Props[name])
+		}
+		w.b.WriteString("</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+	}
+	if len(missing) > 0 {
+		w.b.WriteString("<d:propstat><d:prop>")
+		for _, name := range missing {
+			w.writeProp(name, "")
+		}
+		w.b.WriteString("</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+	}
+	w.b.WriteString("</d:response>")
+}
+
+func (w *multistatusWriter) writeStatus(href string, status string) {
+	w.b.WriteString("<d:response><d:href>" + escapeXML(href) + "</d:href><d:status>" + status + "</d:status></d:response>")
+}
+
+func (w *multistatusWriter) writeProp(name davName, value string) {
+	tag, xmlns := name.Local, ` xmlns="`+escapeXML(name.Space)+`"`
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag, xmlns = prefix+":"+name.Local, ""
+	}
+	if value == "" {
+		w.b.WriteString("<" + tag + xmlns + "/>")
+		return
+	}
+	w.b.WriteString("<" + tag + xmlns + ">" + value + "</" + tag + ">")
+}
+
+func (w *multistatusWriter) writeSyncToken(token string) {
+	w.b.WriteString("<d:sync-token>" + escapeXML(token) + "</d:sync-token>")
+}
+
+func (w *multistatusWriter) bytes() []byte {
+	w.b.WriteString("</d:multistatus>")
+	return w.b.Bytes()
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/masking"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+type RecordConsentRequest struct {
+	Channel string `json:"channel" binding:"required" example:"email"`
+	Purpose string `json:"purpose" binding:"required" example:"marketing"`
+	Status  string `json:"status" binding:"required" example:"granted"`
+	Source  string `json:"source" binding:"required" example:"formulario-site"`
+}
+
+// RecordConsent registra uma concessão ou revogação de consentimento
+// @Summary Registra consentimento
+// @Description Acrescenta ao histórico do contato a concessão (granted) ou revogação (revoked) do consentimento para um canal (email, phone ou sms) e uma finalidade, com a origem informada. Os registros anteriores são mantidos como prova.
+// @Tags Consents
+// @Accept json
+// @Produce json
+// @Param id path int true "ID do contato"
+// @Param consent body handlers.RecordConsentRequest true "Consentimento"
+// @Success 201 {object} models.ConsentRecord
+// @Failure 400,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/{id}/consents [post]
+func RecordConsent(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req RecordConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, _ := auth.CurrentPrincipal(c)
+	record, err := services.RecordConsent(ctx, tenancy.TenantID(c), auth.OwnerID(c), id, principal.Subject, models.ConsentRecord{
+		Channel: req.Channel,
+		Purpose: req.Purpose,
+		Status:  req.Status,
+		Source:  req.Source,
+	})
+	if err != nil {
+		writeConsentError(c, err)
+		return
+	}
+
+	recordAudit(c, models.AuditUpdate, []int{id})
+	c.JSON(http.StatusCreated, record)
+}
+
+// GetContactConsents lista os consentimentos de um contato
+// @Summary Consentimentos de um contato
+// @Tags Consents
+// @Produce json
+// @Param id path int true "ID do contato"
+// @Success 200 {object} services.ContactConsents
+// @Failure 400,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/{id}/consents [get]
+func GetContactConsents(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	consents, err := services.GetContactConsents(ctx, tenancy.TenantID(c), auth.OwnerID(c), id)
+	if err != nil {
+		writeConsentError(c, err)
+		return
+	}
+
+	recordAudit(c, models.AuditRead, []int{id})
+	c.JSON(http.StatusOK, consents)
+}
+
+// GetContactsWithoutConsent lista os contatos sem consentimento válido
+// @Summary Contatos sem consentimento
+// @Description Lista os contatos que têm o dado do canal (e-mail ou telefone) mas não têm consentimento válido para a finalidade.
+// @Tags Consents
+// @Produce json
+// @Param channel query string true "email, phone ou sms"
+// @Param purpose query string false "Finalidade (padrão marketing)"
+// @Success 200 {array} models.Contact
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/consents/missing [get]
+func GetContactsWithoutConsent(c *gin.Context) {
+	ctx := c.Request.Context()
+	contacts, err := services.GetContactsWithoutConsent(ctx, tenancy.TenantID(c), auth.OwnerID(c), c.Query("channel"), c.DefaultQuery("purpose", models.PurposeMarketing))
+	if err != nil {
+		writeConsentError(c, err)
+		return
+	}
+
+	recordAudit(c, models.AuditList, contactIDs(contacts))
+	c.JSON(http.StatusOK, contacts)
+}
+
+// ExportContacts exporta os contatos da agenda
+// @Summary Exporta contatos
+// @Description Exporta a agenda em JSON, CSV ou vCard. Com purpose (por exemplo marketing), cada e-mail e telefone só é exportado com consentimento válido para o canal e a finalidade, e contatos sem nenhum canal consentido são omitidos.
+// @Tags Contacts
+// @Produce json
+// @Produce text/csv
+// @Produce text/vcard
+// @Param format query string false "json (padrão), csv ou vcard"
+// @Param purpose query string false "Finalidade da exportação, exige consentimento"
+// @Param channel query string false "Restringe a exportação a um canal: email, phone ou sms"
+// @Success 200 {array} models.Contact
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/export [get]
+func ExportContacts(c *gin.Context) {
+	ctx := c.Request.Context()
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "vcard" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format deve ser json, csv ou vcard"})
+		return
+	}
+
+	contacts, err := services.ExportContacts(ctx, tenancy.TenantID(c), auth.OwnerID(c), c.Query("purpose"), c.Query("channel"))
+	if err != nil {
+		writeConsentError(c, err)
+		return
+	}
+
+	recordAudit(c, models.AuditExport, contactIDs(contacts))
+	contacts = masking.Current().Contacts(auth.Role(c), contacts)
+	switch format {
+	case "csv":
+		var b strings.Builder
+		w := csv.NewWriter(&b)
+		w.Write([]string{"id", "name", "email", "phone"})
+		for _, contact := range contacts {
+			w.Write([]string{strconv.Itoa(contact.ID), contact.Name, contact.Email, contact.Phone})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", `attachment; filename="contatos.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(b.String()))
+	case "vcard":
+		var b strings.Builder
+		for _, contact := range contacts {
+			b.WriteString(services.ContactToVCard(contact))
+		}
+		c.Header("Content-Disposition", `attachment; filename="contatos.vcf"`)
+		c.Data(http.StatusOK, "text/vcard; charset=utf-8", []byte(b.String()))
+	default:
+		c.JSON(http.StatusOK, contacts)
+	}
+}
+
+func writeConsentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidChannel), errors.Is(err, services.ErrInvalidPurpose),
+		errors.Is(err, services.ErrInvalidConsentStatus), errors.Is(err, services.ErrConsentSourceMissing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrContactNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		writeInternalError(c, err)
+	}
+}
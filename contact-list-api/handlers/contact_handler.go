@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/masking"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,13 +25,17 @@ type HTTPError struct {
 // @Produce json
 // @Success 200 {array} models.Contact
 // @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/ [get]
 func GetContacts(c *gin.Context) {
-	contacts, err := services.GetAllContacts()
+	ctx := c.Request.Context()
+	contacts, err := services.GetAllContacts(ctx, tenancy.TenantID(c), auth.OwnerID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeInternalError(c, err)
 		return
 	}
+	recordAudit(c, models.AuditList, contactIDs(contacts))
 	c.JSON(http.StatusOK, contacts)
 }
 
@@ -38,21 +47,31 @@ func GetContacts(c *gin.Context) {
 // @Param contact body models.Contact true "Contato"
 // @Success 201 {object} models.Contact
 // @Failure 400 {object} handlers.HTTPError
+// @Failure 403 {object} handlers.HTTPError "Cota de contatos do tenant esgotada"
 // @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/ [post]
 func CreateContact(c *gin.Context) {
+	ctx := c.Request.Context()
 	var contact models.Contact
 	if err := c.ShouldBindJSON(&contact); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := services.AddContact(contact); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	created, err := services.AddContact(ctx, tenancy.TenantID(c), auth.OwnerID(c), contact)
+	if err != nil {
+		if errors.Is(err, services.ErrContactQuotaExceeded) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, contact)
+	recordAudit(c, models.AuditCreate, []int{created.ID})
+	c.JSON(http.StatusCreated, created)
 }
 
 // GetContactByID godoc
@@ -62,8 +81,12 @@ func CreateContact(c *gin.Context) {
 // @Param id path int true "ID do contato"
 // @Success 200 {object} models.Contact
 // @Failure 400,404 {object} handlers.HTTPError
+// @Failure 500,504 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/{id} [get]
 func GetContactByID(c *gin.Context) {
+	ctx := c.Request.Context()
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
@@ -71,13 +94,18 @@ func GetContactByID(c *gin.Context) {
 		return
 	}
 
-	contact, err := services.GetContactByID(id)
+	contact, err := services.GetContactByID(ctx, tenancy.TenantID(c), auth.OwnerID(c), id)
 
 	if err != nil {
-		c.JSON(404, gin.H{"error": "Contato não encontrado"})
+		if errors.Is(err, services.ErrContactNotFound) {
+			c.JSON(404, gin.H{"error": "Contato não encontrado"})
+			return
+		}
+		writeInternalError(c, err)
 		return
 	}
 
+	recordAudit(c, models.AuditRead, []int{contact.ID})
 	c.JSON(http.StatusOK, contact)
 }
 
@@ -91,9 +119,14 @@ func GetContactByID(c *gin.Context) {
 // @Param contact body models.Contact true "Dados atualizados do contato"
 // @Success 201 {object} models.Contact
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Contato compartilhado apenas para leitura"
 // @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/{id} [put]
 func UpdateContactById(c *gin.Context) {
+	ctx := c.Request.Context()
 
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -106,13 +139,21 @@ func UpdateContactById(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
-	updatedContact, err := services.UpdateContactById(id, contact)
+	updatedContact, err := services.UpdateContactById(ctx, tenancy.TenantID(c), auth.OwnerID(c), id, contact)
 
 	if err != nil {
-		c.JSON(404, gin.H{"error": "Contact not found"})
+		switch {
+		case errors.Is(err, services.ErrContactNotFound):
+			c.JSON(404, gin.H{"error": "Contact not found"})
+		case errors.Is(err, services.ErrPermissionDenied):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
 		return
 	}
 
+	recordAudit(c, models.AuditUpdate, []int{id})
 	c.JSON(http.StatusCreated, updatedContact)
 }
 
@@ -123,19 +164,33 @@ func UpdateContactById(c *gin.Context) {
 // @Param id path int true "ID do contato"
 // @Success 204 "No Content"
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Apenas o dono pode remover o contato"
 // @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/{id} [delete]
 func DeleteContact(c *gin.Context) {
+	ctx := c.Request.Context()
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
 		return
 	}
 
-	if err := services.DeleteContactById(id); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	if err := services.DeleteContactById(ctx, tenancy.TenantID(c), auth.OwnerID(c), id); err != nil {
+		switch {
+		case errors.Is(err, services.ErrContactNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPermissionDenied):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
 	}
 
+	recordAudit(c, models.AuditDelete, []int{id})
 	c.Status(http.StatusNoContent)
 }
 
@@ -146,40 +201,62 @@ func DeleteContact(c *gin.Context) {
 // @Produce json
 // @Success 200 {object} interface{} // pode substituir por um tipo exato se souber
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/summary [get]
 func GetContactsSummary(c *gin.Context) {
-	summary, err := services.GetContactsSummary()
+	ctx := c.Request.Context()
+	summary, err := services.GetContactsSummary(ctx, tenancy.TenantID(c), auth.OwnerID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeInternalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, summary)
 }
 
-// SearchContactsByName busca contatos por nome
+// SearchContactsByName busca contatos por nome, e-mail ou telefone
 // @Summary Busca contatos
-// @Description Busca contatos com base em parte do nome
+// @Description Busca contatos pelo início do nome ou pelo e-mail ou telefone exatos. Informe apenas um dos parâmetros.
 // @Tags Contacts
 // @Produce json
-// @Param name query string true "Nome para busca parcial"
+// @Param name query string false "Nome para busca parcial"
+// @Param email query string false "E-mail exato, sem diferenciar maiúsculas"
+// @Param phone query string false "Telefone; apenas os dígitos são comparados"
 // @Success 200 {array} models.Contact
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/search [get]
 func SearchContactsByName(c *gin.Context) {
-	query := c.Query("name")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'name' is required"})
+	ctx := c.Request.Context()
+	tenantID, ownerID := tenancy.TenantID(c), auth.OwnerID(c)
+
+	var contacts []models.Contact
+	var field string
+	var err error
+	switch {
+	case c.Query("name") != "":
+		field = metrics.FieldName
+		contacts, err = services.SearchContactsByName(ctx, tenantID, ownerID, c.Query("name"))
+	case c.Query("email") != "":
+		field = metrics.FieldEmail
+		contacts, err = services.FindContactsByEmail(ctx, tenantID, ownerID, c.Query("email"))
+	case c.Query("phone") != "":
+		field = metrics.FieldPhone
+		contacts, err = services.FindContactsByPhone(ctx, tenantID, ownerID, c.Query("phone"))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'name', 'email' or 'phone' is required"})
 		return
 	}
-
-	contacts, err := services.SearchContactsByName(query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeInternalError(c, err)
 		return
 	}
 
+	metrics.ObserveSearch(field, len(contacts))
+	recordAudit(c, models.AuditSearch, contactIDs(contacts))
 	c.JSON(http.StatusOK, contacts)
 }
 
@@ -190,13 +267,59 @@ func SearchContactsByName(c *gin.Context) {
 // @Produce json
 // @Success 200 {array} string
 // @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /contacts/email-providers [get]
 func GetEmailProviders(c *gin.Context) {
-	providers, err := services.GetEmailProviders()
+	ctx := c.Request.Context()
+	providers, err := services.GetEmailProviders(ctx, tenancy.TenantID(c), auth.OwnerID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeInternalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, providers)
 }
+
+// SyncContacts retorna as alterações desde o último token de sincronização
+// @Summary Sincronização incremental
+// @Description Retorna os contatos criados ou alterados e as exclusões desde o token informado, junto com o token para a próxima chamada. Sem token, retorna a lista completa. Um 410 indica que o token expirou e o cliente deve sincronizar tudo de novo.
+// @Tags Contacts
+// @Produce json
+// @Param token query string false "Token retornado pela sincronização anterior"
+// @Success 200 {object} services.SyncResult
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 410 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/sync [get]
+func SyncContacts(c *gin.Context) {
+	ctx := c.Request.Context()
+	result, err := services.SyncContacts(ctx, tenancy.TenantID(c), auth.OwnerID(c), c.Query("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSyncToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSyncTokenExpired):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	ids := contactIDs(result.Changed)
+	for _, tombstone := range result.Deleted {
+		ids = append(ids, tombstone.ID)
+	}
+	recordAudit(c, models.AuditList, ids)
+	c.JSON(http.StatusOK, result)
+}
+
+// maskedContact aplica a política de mascaramento ao contato conforme o papel
+// autenticado. As respostas JSON já são mascaradas por masking.Responses; o
+// helper serve às respostas em outros formatos, como vCard, CSV e streams.
+func maskedContact(c *gin.Context, contact models.Contact) models.Contact {
+	return masking.Current().Contact(auth.Role(c), contact)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusClientClosedRequest é o código que o nginx registra quando o cliente
+// desiste antes da resposta. Ninguém o recebe, mas ele separa essas
+// requisições dos erros do servidor nos logs e nas métricas.
+const statusClientClosedRequest = 499
+
+// writeInternalError responde aos erros que não são do cliente. Quando o
+// prazo da rota vence no meio do caminho, responde 504; quando o cliente
+// desconecta, 499; nos demais casos, 500.
+func writeInternalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(c.Request.Context(), "request deadline exceeded", "route", c.FullPath(), "error", err)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out"})
+	case errors.Is(err, context.Canceled):
+		c.JSON(statusClientClosedRequest, gin.H{"error": "request canceled"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+const eventsHeartbeatInterval = 15 * time.Second
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkEventsOrigin,
+}
+
+var (
+	eventsOriginsMu sync.RWMutex
+	eventsOrigins   map[string]bool
+)
+
+// ConfigureEventsOrigins define as origens, separadas por vírgula (por
+// exemplo "https://app.example.com"), de onde páginas de outro host podem
+// abrir o WebSocket de eventos.
+func ConfigureEventsOrigins(origins string) {
+	allowed := map[string]bool{}
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+		}
+	}
+	eventsOriginsMu.Lock()
+	defer eventsOriginsMu.Unlock()
+	eventsOrigins = allowed
+}
+
+// checkEventsOrigin aceita o WebSocket sem Origin, que só navegadores
+// enviam, com a origem no mesmo host da requisição ou numa das origens
+// configuradas. Sem isso, qualquer página poderia abrir o stream com as
+// credenciais que o navegador envia sozinho.
+func checkEventsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	eventsOriginsMu.RLock()
+	defer eventsOriginsMu.RUnlock()
+	return eventsOrigins[strings.ToLower(origin)]
+}
+
+func lastEventID(c *gin.Context) int64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// visibleEvent informa se o evento pertence à agenda acessada. O barramento é
+// único, então cada stream filtra pelo tenant e pelo dono autenticados.
+func visibleEvent(event services.ContactEvent, tenantID string, ownerID int) bool {
+	return event.TenantID == tenantID && event.Contact.OwnerID == ownerID
+}
+
+func ownedEvents(events []services.ContactEvent, tenantID string, ownerID int) []services.ContactEvent {
+	var owned []services.ContactEvent
+	for _, event := range events {
+		if visibleEvent(event, tenantID, ownerID) {
+			owned = append(owned, event)
+		}
+	}
+	return owned
+}
+
+// auditEvents registra os contatos enviados no stream: o backlog numa só
+// entrada de listagem e cada evento seguinte como uma leitura.
+func auditEvents(c *gin.Context, action string, events []services.ContactEvent) {
+	if len(events) == 0 {
+		return
+	}
+	ids := make([]int, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.Contact.ID)
+	}
+	recordAudit(c, action, ids)
+}
+
+// StreamContactEvents envia as alterações de contatos via Server-Sent Events
+// @Summary Stream de alterações de contatos (SSE)
+// @Description Publica eventos created, updated e deleted. Envie o cabeçalho Last-Event-ID para retomar o stream; um evento "reset" indica que o cliente deve recarregar a lista completa.
+// @Tags Events
+// @Produce text/event-stream
+// @Param Last-Event-ID header int false "ID do último evento recebido"
+// @Param last_event_id query int false "Alternativa ao cabeçalho Last-Event-ID"
+// @Success 200 {object} services.ContactEvent
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/events [get]
+func StreamContactEvents(c *gin.Context) {
+	tenantID, ownerID := tenancy.TenantID(c), auth.OwnerID(c)
+	backlog, complete, events, cancel := services.Events.Subscribe(lastEventID(c))
+	defer cancel()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// O stream não tem fim previsto; o WriteTimeout do servidor o cortaria.
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	if !complete {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{"reason": "events no longer available"}})
+	}
+	owned := ownedEvents(backlog, tenantID, ownerID)
+	for _, event := range owned {
+		renderSSEEvent(c, event)
+	}
+	c.Writer.Flush()
+	auditEvents(c, models.AuditList, owned)
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-streamsDone:
+			return
+		case event, ok := <-events:
+			if !ok {
+				// O barramento fechou a assinatura por atraso; o navegador
+				// reconecta com Last-Event-ID e recebe o que faltou.
+				return
+			}
+			if !visibleEvent(event, tenantID, ownerID) {
+				continue
+			}
+			renderSSEEvent(c, event)
+			c.Writer.Flush()
+			auditEvents(c, models.AuditRead, []services.ContactEvent{event})
+		case <-heartbeat.C:
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func renderSSEEvent(c *gin.Context, event services.ContactEvent) {
+	event.Contact = maskedContact(c, event.Contact)
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
+
+// ContactEventsWebSocket envia as alterações de contatos via WebSocket
+// @Summary Stream de alterações de contatos (WebSocket)
+// @Description Cada mensagem é um evento em JSON. Use last_event_id para retomar o stream; uma mensagem do tipo "reset" indica que o cliente deve recarregar a lista completa.
+// @Tags Events
+// @Param last_event_id query int false "ID do último evento recebido"
+// @Success 101 "Switching Protocols"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/events/ws [get]
+func ContactEventsWebSocket(c *gin.Context) {
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	// A conexão assumida mantém o prazo do WriteTimeout do servidor.
+	conn.NetConn().SetDeadline(time.Time{})
+
+	tenantID, ownerID := tenancy.TenantID(c), auth.OwnerID(c)
+	backlog, complete, events, cancel := services.Events.Subscribe(lastEventID(c))
+	defer cancel()
+
+	// O cliente não envia mensagens; a leitura só serve para detectar o
+	// fechamento da conexão e processar os frames de controle.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if !complete {
+		if err := conn.WriteJSON(gin.H{"type": "reset"}); err != nil {
+			return
+		}
+	}
+	owned := ownedEvents(backlog, tenantID, ownerID)
+	for _, event := range owned {
+		event.Contact = maskedContact(c, event.Contact)
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+	auditEvents(c, models.AuditList, owned)
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-streamsDone:
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+			return
+		case event, ok := <-events:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow, resume with last_event_id")
+				conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+				return
+			}
+			if !visibleEvent(event, tenantID, ownerID) {
+				continue
+			}
+			event.Contact = maskedContact(c, event.Contact)
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+			auditEvents(c, models.AuditRead, []services.ContactEvent{event})
+		case <-heartbeat.C:
+			deadline := time.Now().Add(eventsHeartbeatInterval)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
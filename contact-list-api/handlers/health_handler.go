@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+)
+
+var (
+	shuttingDown atomic.Bool
+	// streamsDone é fechado no desligamento para encerrar os streams de
+	// eventos, que de outro modo segurariam o servidor até o timeout.
+	streamsDone      = make(chan struct{})
+	closeStreamsOnce sync.Once
+)
+
+// Shutdown prepara o desligamento: /readyz passa a responder 503, para que o
+// balanceador pare de mandar tráfego, e os streams SSE e WebSocket abertos são
+// encerrados. As demais requisições em andamento continuam até o fim.
+func Shutdown() {
+	shuttingDown.Store(true)
+	closeStreamsOnce.Do(func() { close(streamsDone) })
+}
+
+// Healthz indica se o processo está no ar
+// @Summary Liveness
+// @Description Responde 200 enquanto o processo atende requisições, sem consultar o armazenamento.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz indica se o servidor pode receber tráfego
+// @Summary Readiness
+// @Description Confere se o armazenamento pode ser lido e gravado. Responde 503 quando não pode ou quando o servidor está desligando.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /readyz [get]
+func Readyz(c *gin.Context) {
+	if shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	if err := storage.CheckHealth(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+// SubjectRequest identifica o titular. Vai no corpo, e não na URL, para que
+// e-mail e telefone não apareçam em logs de acesso.
+type SubjectRequest struct {
+	Email string `json:"email" example:"fernanda.lima@yahoo.com"`
+	Phone string `json:"phone" example:"551198765432"`
+}
+
+type ConfirmErasureRequest struct {
+	SubjectRequest
+	ConfirmationToken string `json:"confirmation_token" binding:"required" example:"2f1c..."`
+}
+
+// ExportSubjectData exporta os dados de um titular
+// @Summary Exporta os dados de um titular (LGPD)
+// @Description Procura o e-mail ou telefone em todas as agendas do tenant e devolve, em JSON, os contatos, compartilhamentos, links públicos, consentimentos, histórico de alterações e eventos recentes ligados a ele. O pedido fica registrado na auditoria, sem os dados do titular.
+// @Tags Privacy
+// @Accept json
+// @Produce json
+// @Param subject body handlers.SubjectRequest true "E-mail e/ou telefone do titular"
+// @Success 200 {object} services.SubjectData
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /privacy/export [post]
+func ExportSubjectData(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req SubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, _ := auth.CurrentPrincipal(c)
+	data, err := services.ExportSubjectData(ctx, tenancy.TenantID(c), principal.Subject, req.Email, req.Phone)
+	if err != nil {
+		writePrivacyError(c, err)
+		return
+	}
+
+	recordAudit(c, models.AuditExport, contactIDs(data.Contacts))
+	c.Header("Content-Disposition", `attachment; filename="titular.json"`)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, data)
+}
+
+// RequestErasure inicia a exclusão dos dados de um titular
+// @Summary Inicia a exclusão dos dados de um titular (LGPD)
+// @Description Mostra tudo o que será apagado e devolve um token de confirmação válido por 15 minutos. Nada é apagado nesta etapa.
+// @Tags Privacy
+// @Accept json
+// @Produce json
+// @Param subject body handlers.SubjectRequest true "E-mail e/ou telefone do titular"
+// @Success 200 {object} services.ErasureRequest
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /privacy/erasure [post]
+func RequestErasure(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req SubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	erasure, err := services.RequestErasure(ctx, tenancy.TenantID(c), req.Email, req.Phone)
+	if err != nil {
+		writePrivacyError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, erasure)
+}
+
+// ConfirmErasure apaga os dados de um titular
+// @Summary Confirma a exclusão dos dados de um titular (LGPD)
+// @Description Exige o token da etapa anterior e o mesmo e-mail e telefone. Apaga os contatos do titular em todas as agendas, com compartilhamentos, links públicos e consentimentos, limpa os eventos recentes e os payloads de webhook e refaz a busca, também no journal, nos backups e na quarentena, para verificar a exclusão. Devolve a entrada anonimizada da auditoria.
+// @Tags Privacy
+// @Accept json
+// @Produce json
+// @Param confirmation body handlers.ConfirmErasureRequest true "Token de confirmação e titular"
+// @Success 200 {object} models.PrivacyRequest
+// @Failure 400,403 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /privacy/erasure/confirm [post]
+func ConfirmErasure(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req ConfirmErasureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, _ := auth.CurrentPrincipal(c)
+	entry, err := services.ConfirmErasure(ctx, tenancy.TenantID(c), principal.Subject, req.ConfirmationToken, req.Email, req.Phone)
+	if err != nil {
+		writePrivacyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetPrivacyRequests lista a auditoria de pedidos de titulares
+// @Summary Auditoria de pedidos de titulares (LGPD)
+// @Tags Privacy
+// @Produce json
+// @Success 200 {array} models.PrivacyRequest
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /privacy/requests [get]
+func GetPrivacyRequests(c *gin.Context) {
+	ctx := c.Request.Context()
+	requests, err := services.GetPrivacyRequests(ctx, tenancy.TenantID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func writePrivacyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSubjectRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidConfirmation):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		writeInternalError(c, err)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+type CreateShareRequest struct {
+	Username   string `json:"username" binding:"required" example:"maria"`
+	ContactID  int    `json:"contact_id" example:"3"`
+	Permission string `json:"permission" binding:"required" example:"read"`
+}
+
+// CreateShare compartilha um contato ou a agenda inteira
+// @Summary Compartilha contatos
+// @Description Concede a outro usuário do tenant acesso de leitura (read) ou edição (edit) a um contato. Sem contact_id, compartilha a agenda inteira. Compartilhar de novo com o mesmo usuário troca a permissão.
+// @Tags Shares
+// @Accept json
+// @Produce json
+// @Param share body handlers.CreateShareRequest true "Compartilhamento"
+// @Success 201 {object} models.Share
+// @Failure 400,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security BearerAuth
+// @Router /shares/ [post]
+func CreateShare(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := services.ShareContact(ctx, tenancy.TenantID(c), auth.OwnerID(c), req.ContactID, req.Username, req.Permission)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPermission), errors.Is(err, services.ErrShareWithSelf):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSharingRequiresUser):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrContactNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// GetShares lista os compartilhamentos feitos pelo usuário
+// @Summary Lista compartilhamentos feitos
+// @Tags Shares
+// @Produce json
+// @Success 200 {array} models.Share
+// @Failure 500 {object} handlers.HTTPError
+// @Security BearerAuth
+// @Router /shares/ [get]
+func GetShares(c *gin.Context) {
+	ctx := c.Request.Context()
+	shares, err := services.GetSharesByOwner(ctx, tenancy.TenantID(c), auth.OwnerID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// RevokeShare revoga um compartilhamento
+// @Summary Revoga um compartilhamento
+// @Tags Shares
+// @Param id path int true "ID do compartilhamento"
+// @Success 204 "No Content"
+// @Failure 400,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security BearerAuth
+// @Router /shares/{id} [delete]
+func RevokeShare(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := services.RevokeShare(ctx, tenancy.TenantID(c), auth.OwnerID(c), id); err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSharedWithMe lista os contatos compartilhados com o usuário
+// @Summary Contatos compartilhados comigo
+// @Tags Shares
+// @Produce json
+// @Success 200 {array} services.SharedContact
+// @Failure 500 {object} handlers.HTTPError
+// @Security BearerAuth
+// @Router /contacts/shared [get]
+func GetSharedWithMe(c *gin.Context) {
+	ctx := c.Request.Context()
+	contacts, err := services.GetSharedWithMe(ctx, tenancy.TenantID(c), auth.OwnerID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	ids := make([]int, 0, len(contacts))
+	for _, shared := range contacts {
+		ids = append(ids, shared.Contact.ID)
+	}
+	recordAudit(c, models.AuditList, ids)
+	c.JSON(http.StatusOK, contacts)
+}
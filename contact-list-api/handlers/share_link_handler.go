@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+const publicContactPath = "/public/contacts/"
+
+var (
+	trustedProxiesMu sync.Mutex
+	trustedProxies   []*net.IPNet
+)
+
+// ConfigureTrustedProxies define os IPs e as redes (CIDR) dos proxies cujo
+// X-Forwarded-Proto é aceito na URL dos links públicos, a mesma lista passada
+// a gin.Engine.SetTrustedProxies. Vazia, o esquema é o da conexão.
+func ConfigureTrustedProxies(proxies []string) error {
+	var networks []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		networks = append(networks, network)
+	}
+
+	trustedProxiesMu.Lock()
+	defer trustedProxiesMu.Unlock()
+	trustedProxies = networks
+	return nil
+}
+
+// fromTrustedProxy diz se a conexão veio de um dos proxies configurados.
+func fromTrustedProxy(c *gin.Context) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+
+	trustedProxiesMu.Lock()
+	defer trustedProxiesMu.Unlock()
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateShareLinkRequest struct {
+	// ExpiresIn é a validade em segundos; zero usa o padrão de 7 dias.
+	ExpiresIn int `json:"expires_in" example:"86400"`
+	MaxUses   int `json:"max_uses" example:"5"`
+}
+
+type ShareLinkResponse struct {
+	models.ShareLink
+	URL string `json:"url" example:"http://localhost:8080/public/contacts/LjEuMTc2MDAwMDAwMA.c2lnbmF0dXJh"`
+}
+
+// CreateShareLink cria um link público para um contato
+// @Summary Cria um link público
+// @Description Gera uma URL assinada que abre o contato sem autenticação, em JSON ou vCard, até expirar ou esgotar max_uses. Apenas o dono do contato pode criar links. A URL só é exibida nesta resposta.
+// @Tags Share links
+// @Accept json
+// @Produce json
+// @Param id path int true "ID do contato"
+// @Param link body handlers.CreateShareLinkRequest false "Validade e limite de usos"
+// @Success 201 {object} handlers.ShareLinkResponse
+// @Failure 400,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/{id}/share-links [post]
+func CreateShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tenantID := tenancy.TenantID(c)
+	token, link, err := services.CreateShareLink(ctx, tenantID, auth.OwnerID(c), id, time.Duration(req.ExpiresIn)*time.Second, req.MaxUses)
+	if err != nil {
+		writeShareLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ShareLinkResponse{ShareLink: link, URL: publicContactURL(c, token)})
+}
+
+// GetShareLinks lista os links públicos de um contato
+// @Summary Lista os links públicos de um contato
+// @Description Lista validade e usos de cada link, sem a URL, que só é exibida na criação.
+// @Tags Share links
+// @Produce json
+// @Param id path int true "ID do contato"
+// @Success 200 {array} models.ShareLink
+// @Failure 400,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/{id}/share-links [get]
+func GetShareLinks(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	links, err := services.GetShareLinks(ctx, tenancy.TenantID(c), auth.OwnerID(c), id)
+	if err != nil {
+		writeShareLinkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// RevokeShareLink revoga um link público
+// @Summary Revoga um link público
+// @Tags Share links
+// @Param id path int true "ID do contato"
+// @Param linkId path int true "ID do link"
+// @Success 204 "No Content"
+// @Failure 400,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /contacts/{id}/share-links/{linkId} [delete]
+func RevokeShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	linkID, err := strconv.Atoi(c.Param("linkId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := services.RevokeShareLink(ctx, tenancy.TenantID(c), auth.OwnerID(c), id, linkID); err != nil {
+		writeShareLinkError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetPublicContact abre o contato de um link público
+// @Summary Abre um link público
+// @Description Não exige autenticação. Responde em vCard com format=vcard ou Accept: text/vcard e em JSON nos demais casos. Cada acesso conta para max_uses; links expirados ou esgotados respondem 410.
+// @Tags Share links
+// @Produce json
+// @Produce text/vcard
+// @Param token path string true "Token do link"
+// @Param format query string false "json ou vcard"
+// @Success 200 {object} models.PublicContact
+// @Failure 404,410 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Router /public/contacts/{token} [get]
+func GetPublicContact(c *gin.Context) {
+	ctx := c.Request.Context()
+	resolved, err := services.ResolveShareLink(ctx, c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidShareLink):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrShareLinkExpired), errors.Is(err, services.ErrShareLinkExhausted):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	contact := resolved.Contact
+	recordAuditAs(c, resolved.TenantID, "share-link:"+strconv.Itoa(resolved.Link.ID), models.AuditRead, []int{contact.ID})
+	c.Header("Cache-Control", "no-store")
+	if c.Query("format") == "vcard" || strings.Contains(c.GetHeader("Accept"), "text/vcard") {
+		c.Data(http.StatusOK, "text/vcard; charset=utf-8", []byte(services.ContactToVCard(contact)))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PublicContact{Name: contact.Name, Email: contact.Email, Phone: contact.Phone})
+}
+
+func writeShareLinkError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidShareLinkTTL), errors.Is(err, services.ErrInvalidMaxUses):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrContactNotFound), errors.Is(err, services.ErrShareLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		writeInternalError(c, err)
+	}
+}
+
+// publicContactURL monta a URL absoluta do link a partir do host da
+// requisição. X-Forwarded-Proto só vale quando a conexão vem de um proxy
+// confiável; de qualquer outro cliente, escolheria o esquema da URL.
+func publicContactURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(c.GetHeader("X-Forwarded-Proto")); (proto == "http" || proto == "https") && fromTrustedProxy(c) {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + publicContactPath + token
+}
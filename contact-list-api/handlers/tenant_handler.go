@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+)
+
+type UpdateTenantRequest struct {
+	Name        string `json:"name" example:"Acme Ltda."`
+	MaxContacts int    `json:"max_contacts" example:"1000"`
+}
+
+type TenantResponse struct {
+	models.Tenant
+	ContactCount int `json:"contact_count" example:"42"`
+}
+
+// CreateTenant cadastra um tenant
+// @Summary Cadastra um tenant
+// @Description Apenas a chave de bootstrap pode administrar tenants. O ID é usado no cabeçalho X-Tenant-ID e como subdomínio.
+// @Tags Tenants
+// @Accept json
+// @Produce json
+// @Param tenant body models.Tenant true "Tenant"
+// @Success 201 {object} models.Tenant
+// @Failure 400,401,403,409 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /tenants/ [post]
+func CreateTenant(c *gin.Context) {
+	ctx := c.Request.Context()
+	var tenant models.Tenant
+	if err := c.ShouldBindJSON(&tenant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := services.CreateTenant(ctx, tenant)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTenantID), errors.Is(err, services.ErrInvalidQuota):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTenantExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetTenants lista os tenants
+// @Summary Lista tenants
+// @Tags Tenants
+// @Produce json
+// @Success 200 {array} models.Tenant
+// @Failure 401,403 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /tenants/ [get]
+func GetTenants(c *gin.Context) {
+	ctx := c.Request.Context()
+	tenants, err := services.GetAllTenants(ctx)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenants)
+}
+
+// GetTenant busca um tenant e seu uso da cota
+// @Summary Busca um tenant
+// @Tags Tenants
+// @Produce json
+// @Param id path string true "ID do tenant"
+// @Success 200 {object} handlers.TenantResponse
+// @Failure 401,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /tenants/{id} [get]
+func GetTenant(c *gin.Context) {
+	ctx := c.Request.Context()
+	tenant, err := services.GetTenant(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrTenantNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	count, err := services.CountTenantContacts(ctx, tenant.ID)
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TenantResponse{Tenant: tenant, ContactCount: count})
+}
+
+// UpdateTenant altera nome e cota de um tenant
+// @Summary Atualiza um tenant
+// @Tags Tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "ID do tenant"
+// @Param tenant body handlers.UpdateTenantRequest true "Nome e cota (0 = sem limite)"
+// @Success 200 {object} models.Tenant
+// @Failure 400,401,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /tenants/{id} [put]
+func UpdateTenant(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := services.UpdateTenant(ctx, c.Param("id"), models.Tenant{Name: req.Name, MaxContacts: req.MaxContacts})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidQuota):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTenantNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			writeInternalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// DeleteTenant remove um tenant e todos os seus dados
+// @Summary Remove um tenant
+// @Description Apaga também contatos, contas, chaves de API e webhooks do tenant.
+// @Tags Tenants
+// @Param id path string true "ID do tenant"
+// @Success 204 "No Content"
+// @Failure 401,403,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Router /tenants/{id} [delete]
+func DeleteTenant(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := services.DeleteTenant(ctx, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrTenantNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
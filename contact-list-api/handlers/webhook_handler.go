@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+)
+
+// CreateWebhook cadastra um webhook
+// @Summary Cadastra um webhook
+// @Description Registra uma URL que receberá os eventos de contatos assinados com HMAC-SHA256. Se o segredo não for informado, um é gerado e devolvido apenas nesta resposta.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.Webhook true "Webhook"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/ [post]
+func CreateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := services.CreateWebhook(ctx, tenancy.TenantID(c), webhook)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookURL) || errors.Is(err, services.ErrInvalidWebhookEvent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetWebhooks lista os webhooks cadastrados
+// @Summary Lista webhooks
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/ [get]
+func GetWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	webhooks, err := services.GetAllWebhooks(ctx, tenancy.TenantID(c))
+	if err != nil {
+		writeInternalError(c, err)
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// DeleteWebhook remove um webhook
+// @Summary Remove um webhook
+// @Tags Webhooks
+// @Param id path int true "ID do webhook"
+// @Success 204 "No Content"
+// @Failure 400,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := services.DeleteWebhook(ctx, tenancy.TenantID(c), id); err != nil {
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries lista o log de entregas
+// @Summary Log de entregas de webhooks
+// @Description Retorna as entregas mais recentes, com status, número de tentativas e último erro
+// @Tags Webhooks
+// @Produce json
+// @Param webhook_id query int false "Filtra pelo ID do webhook"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	webhookID := 0
+	if value := c.Query("webhook_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_id deve ser número"})
+			return
+		}
+		webhookID = id
+	}
+
+	c.JSON(http.StatusOK, services.GetWebhookDeliveries(tenancy.TenantID(c), webhookID))
+}
+
+// GetWebhookDeadLetters lista as entregas que esgotaram as tentativas
+// @Summary Fila de mensagens mortas
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {array} models.WebhookDelivery
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/dead-letters [get]
+func GetWebhookDeadLetters(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetWebhookDeadLetters(tenancy.TenantID(c)))
+}
+
+// RetryWebhookDeadLetter reenvia uma entrega da fila de mensagens mortas
+// @Summary Reenvia uma mensagem morta
+// @Tags Webhooks
+// @Param id path int true "ID da entrega"
+// @Success 202 "Accepted"
+// @Failure 400,404 {object} handlers.HTTPError
+// @Failure 500 {object} handlers.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/dead-letters/{id}/retry [post]
+func RetryWebhookDeadLetter(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := services.RetryDeadLetter(ctx, tenancy.TenantID(c), id); err != nil {
+		if errors.Is(err, services.ErrDeliveryNotFound) || errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeInternalError(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrInvalidLevel = errors.New("invalid log level, expected debug, info, warn or error")
+
+// level é compartilhado pelo handler instalado em Setup, o que permite trocar
+// o nível em execução com SetLevel.
+var level = new(slog.LevelVar)
+
+// Setup instala como logger padrão um handler JSON que escreve em w e inclui o
+// request_id do contexto em cada linha registrada com as variantes
+// ...Context de slog. O pacote log passa a escrever pelo mesmo handler.
+func Setup(w io.Writer, levelName string) error {
+	if err := SetLevel(levelName); err != nil {
+		return err
+	}
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// SetupFromEnv chama Setup com a saída padrão e o nível de LOG_LEVEL (info
+// por padrão).
+func SetupFromEnv() error {
+	levelName := os.Getenv("LOG_LEVEL")
+	if levelName == "" {
+		levelName = "info"
+	}
+	return Setup(os.Stdout, levelName)
+}
+
+// SetLevel troca o nível mínimo dos logs.
+func SetLevel(name string) error {
+	var l slog.Level
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		l = slog.LevelDebug
+	case "info":
+		l = slog.LevelInfo
+	case "warn", "warning":
+		l = slog.LevelWarn
+	case "error":
+		l = slog.LevelError
+	default:
+		return ErrInvalidLevel
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level devolve o nível atual em minúsculas, no mesmo formato aceito por
+// SetLevel.
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+type requestIDKey struct{}
+
+// WithRequestID guarda o ID da requisição no contexto.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext devolve o ID guardado por WithRequestID, ou "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler acrescenta o request_id e, quando há um span no contexto,
+// o trace_id e o span_id aos registros, para ligar os logs aos traces.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
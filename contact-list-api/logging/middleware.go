@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/masking"
+)
+
+// RequestIDHeader é o cabeçalho que traz o ID da requisição, aceito do
+// cliente e sempre devolvido na resposta.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKeyName = "logging.request_id"
+
+// validRequestID limita os IDs aceitos do cliente, que vão para logs e para a
+// auditoria, a um tamanho e alfabeto seguros.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID aceita o X-Request-ID enviado pelo cliente ou gera um novo, e o
+// guarda no contexto do gin e no da requisição, para que chegue aos logs dos
+// serviços e do armazenamento.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDKeyName, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID devolve o ID da requisição atribuído por RequestID.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKeyName)
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// secretParams são os parâmetros de rota que dão acesso sozinhos, como o
+// token de /public/contacts/:token, e nunca vão para o log.
+var secretParams = map[string]bool{"token": true}
+
+// Requests registra uma linha por requisição, no lugar do logger do gin. E-mails
+// e telefones no caminho e na query string são redigidos por masking.RedactText,
+// e os parâmetros de secretParams, trocados por [redacted]. Deve vir depois de
+// RequestID.
+func Requests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.Request.URL.Path
+		for _, param := range c.Params {
+			if secretParams[param.Key] && param.Value != "" {
+				path = strings.Replace(path, "/"+param.Value, "/[redacted]", 1)
+			}
+		}
+		if raw := c.Request.URL.RawQuery; raw != "" {
+			path += "?" + raw
+		}
+
+		status := c.Writer.Status()
+		logLevel := slog.LevelInfo
+		if status >= 500 {
+			logLevel = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", masking.RedactText(path)),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, slog.String("errors", masking.RedactText(errs)))
+		}
+		slog.LogAttrs(c.Request.Context(), logLevel, "request", attrs...)
+	}
+}
@@ -1,8 +1,31 @@
 package main
 
 import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jimlambrt/gldap"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/deadline"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/directory"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/fieldcrypt"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/handlers"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/masking"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/ratelimit"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/routes"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/server"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
 
 	_ "github.com/mathzpereira/c214-seminario/contact-list-api/docs"
 
@@ -16,9 +39,211 @@ import (
 // @host localhost:8080
 // @BasePath /
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Token de sessão ou JWT (HS256 ou RS256) no formato "Bearer <token>"
+
 func main() {
-	r := gin.Default()
+	if err := logging.SetupFromEnv(); err != nil {
+		log.Fatalf("logging: %v", err)
+	}
+
+	traceConfig, err := tracing.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), traceConfig)
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+	auth.Configure(authConfig)
+
+	keyring, err := fieldcrypt.KeyringFromEnv()
+	if err != nil {
+		log.Fatalf("field encryption: %v", err)
+	}
+	storage.ConfigureEncryption(keyring)
+
+	persistence, err := storage.PersistenceConfigFromEnv()
+	if err != nil {
+		log.Fatalf("storage: %v", err)
+	}
+	storage.ConfigurePersistence(persistence)
+
+	recovery, err := storage.RecoveryModeFromEnv()
+	if err != nil {
+		log.Fatalf("storage: %v", err)
+	}
+	checkContactFiles(recovery)
+
+	maskingPolicy, err := masking.PolicyFromEnv()
+	if err != nil {
+		log.Fatalf("masking: %v", err)
+	}
+	masking.Configure(maskingPolicy)
+
+	rateLimits, err := ratelimit.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("rate limit: %v", err)
+	}
+	ratelimit.Configure(ratelimit.NewLimiter(rateLimits, nil))
+	ipRateLimits, err := ratelimit.IPConfigFromEnv()
+	if err != nil {
+		log.Fatalf("rate limit: %v", err)
+	}
+	ratelimit.ConfigureIP(ratelimit.NewLimiter(ipRateLimits, nil))
+
+	tenancy.Configure(os.Getenv("TENANT_BASE_DOMAIN"))
+	handlers.ConfigureEventsOrigins(os.Getenv("EVENTS_ALLOWED_ORIGINS"))
+	services.ConfigureShareLinks([]byte(os.Getenv("SHARE_LINK_SECRET")))
+	auditEnabled, auditKey := os.Getenv("AUDIT_LOG_DISABLED") != "true", os.Getenv("AUDIT_HMAC_KEY")
+	if auditEnabled && auditKey == "" {
+		log.Fatalf("audit: AUDIT_HMAC_KEY is required unless AUDIT_LOG_DISABLED=true")
+	}
+	services.ConfigureAudit(auditEnabled, []byte(auditKey))
+
+	serverConfig, err := server.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("server: %v", err)
+	}
+
+	requestTimeouts, err := deadline.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("request timeout: %v", err)
+	}
+	requestTimeouts.WriteTimeout = serverConfig.WriteTimeout
+	deadline.Configure(requestTimeouts)
+
+	services.ConfigureWebhooks(services.WebhookConfig{AllowPrivateTargets: os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") == "true"})
+	stopWebhooks, err := services.StartWebhookDispatcher()
+	if err != nil {
+		log.Fatalf("webhooks: %v", err)
+	}
+	ldapServer := startLDAPServer()
+	watcher := watchContactsFile()
+
+	// Equivale a gin.Default(), com o log de requisições em JSON, com o ID da
+	// requisição e sem e-mails e telefones, o span de cada requisição, as
+	// métricas por rota e o prazo de cada rota.
+	r := gin.New()
+	// Sem proxies confiáveis, o gin aceitaria o X-Forwarded-For de qualquer
+	// cliente, que escolheria o próprio IP no limite de requisições e na
+	// auditoria. A mesma lista decide de quem o X-Forwarded-Proto vale nos
+	// links públicos.
+	if err := r.SetTrustedProxies(serverConfig.TrustedProxies); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+	if err := handlers.ConfigureTrustedProxies(serverConfig.TrustedProxies); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+	r.Use(logging.RequestID(), logging.Requests(), tracing.Requests(), metrics.Requests(), deadline.Requests(), gin.Recovery())
 	routes.SetupRoutes(r)
+	routes.SetupCardDAVRoutes(r)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	r.Run(":8080")
+	r.GET("/metrics", metrics.Handler())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Depois que as requisições em andamento terminam, as gravações são
+	// forçadas para o disco e os spans pendentes, enviados.
+	runErr := server.Run(ctx, r, serverConfig, handlers.Shutdown)
+	if runErr != nil {
+		slog.Error("server failed", "error", runErr)
+	}
+	if ldapServer != nil {
+		ldapServer.Stop()
+	}
+	if watcher != nil {
+		watcher.Close()
+	}
+	stopWebhooks()
+	if err := storage.Flush(context.Background()); err != nil {
+		slog.Error("storage not flushed", "error", err)
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("traces not flushed", "error", err)
+	}
+	if runErr != nil {
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
+
+// startLDAPServer sobe o diretório LDAP somente leitura quando LDAP_ADDR está
+// definido, por exemplo LDAP_ADDR=:10389. Sem LDAP_ADDR, devolve nil.
+func startLDAPServer() *gldap.Server {
+	addr := os.Getenv("LDAP_ADDR")
+	if addr == "" {
+		return nil
+	}
+
+	ldapServer, err := directory.NewServer(directory.Config{
+		BaseDN:         os.Getenv("LDAP_BASE_DN"),
+		BindDN:         os.Getenv("LDAP_BIND_DN"),
+		BindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+		AllowAnonymous: os.Getenv("LDAP_ALLOW_ANONYMOUS") == "true",
+		TenantID:       os.Getenv("LDAP_TENANT"),
+	})
+	if err != nil {
+		log.Fatalf("ldap: %v", err)
+	}
+
+	go func() {
+		if err := ldapServer.Run(addr); err != nil {
+			log.Printf("ldap: %v", err)
+		}
+	}()
+	return ldapServer
+}
+
+// checkContactFiles confere os arquivos de contatos de todos os tenants e,
+// a menos que mode seja RecoveryNone, recupera os corrompidos. O servidor
+// sobe mesmo assim; um tenant que não pôde ser recuperado responde com erro
+// até a recuperação por POST /admin/storage/recover.
+func checkContactFiles(mode storage.RecoveryMode) {
+	ctx := context.Background()
+	if mode != storage.RecoveryNone {
+		if _, err := services.RecoverAllContacts(ctx, mode); err != nil {
+			slog.Error("contacts not recovered", "error", err)
+		}
+		return
+	}
+
+	reports, err := services.CheckAllContacts(ctx)
+	if err != nil {
+		slog.Error("contacts not checked", "error", err)
+		return
+	}
+	for _, report := range reports {
+		if !report.Healthy {
+			slog.Error("contacts files corrupt", "tenant", report.TenantID, "files", report.Files)
+		}
+	}
+}
+
+// watchContactsFile aplica as edições de data/contacts.json feitas com o
+// servidor no ar, publicando os eventos do que mudou, a menos que
+// STORAGE_WATCH_DISABLED seja "true". Sem inotify, segue sem observar.
+func watchContactsFile() *storage.ContactWatcher {
+	if os.Getenv("STORAGE_WATCH_DISABLED") == "true" {
+		return nil
+	}
+
+	watcher, err := storage.WatchContacts(services.PublishContactChanges, models.DefaultTenantID)
+	if err != nil {
+		slog.Warn("contacts file not watched", "error", err)
+		return nil
+	}
+	return watcher
 }
@@ -0,0 +1,31 @@
+package masking
+
+import "regexp"
+
+var (
+	// logEmail aceita o "@" cru ou codificado na URL (%40).
+	logEmail = regexp.MustCompile(`(?i)[a-z0-9._%+-]+(?:@|%40)[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}`)
+	// logPhone aceita dígitos separados por espaço, ponto, hífen ou parênteses,
+	// crus ou codificados na URL, e só é redigido com ao menos oito dígitos.
+	logPhone = regexp.MustCompile(`(?i)(?:\+|%2B)?\d(?:[\d.()\-+ ]|%20|%2B|%28|%29)*\d`)
+)
+
+const minLogPhoneDigits = 8
+
+// RedactText troca por [email] e [phone] os e-mails e telefones encontrados
+// em s, inclusive em query strings. É usado nos logs de requisição.
+func RedactText(s string) string {
+	s = logEmail.ReplaceAllString(s, "[email]")
+	return logPhone.ReplaceAllStringFunc(s, func(match string) string {
+		digits := 0
+		for i := 0; i < len(match); i++ {
+			if match[i] >= '0' && match[i] <= '9' {
+				digits++
+			}
+		}
+		if digits < minLogPhoneDigits {
+			return match
+		}
+		return "[phone]"
+	})
+}
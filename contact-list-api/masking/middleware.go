@@ -0,0 +1,113 @@
+package masking
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonField encontra pares "email": "..." e "phone": "..." em qualquer nível
+// do JSON, preservando a ordem e o restante do corpo.
+var jsonField = regexp.MustCompile(`"(email|phone)"(\s*:\s*)"((?:[^"\\]|\\.)*)"`)
+
+// Responses mascara os campos de contato das respostas JSON conforme a
+// política em uso e o papel devolvido por role, consultado na hora da escrita
+// para que o middleware possa vir antes da autenticação. Respostas de outros
+// tipos, como streams SSE e vCards, passam sem alteração e devem ser
+// mascaradas pelo handler com Policy.Contact.
+func Responses(role func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &maskingWriter{ResponseWriter: c.Writer, role: func() string { return role(c) }}
+		c.Writer = w
+		c.Next()
+		w.flush()
+	}
+}
+
+type maskingWriter struct {
+	gin.ResponseWriter
+	role      func() string
+	decided   bool
+	buffering bool
+	fields    []string
+	body      bytes.Buffer
+}
+
+func (w *maskingWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	w.fields = Current()[w.role()]
+	contentType := w.Header().Get("Content-Type")
+	w.buffering = len(w.fields) > 0 && strings.HasPrefix(contentType, "application/json")
+}
+
+func (w *maskingWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *maskingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *maskingWriter) Flush() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Unwrap permite que http.ResponseController alcance a conexão, como nos
+// streams de eventos, que removem o prazo de escrita.
+func (w *maskingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *maskingWriter) flush() {
+	if !w.buffering {
+		return
+	}
+	w.buffering = false
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.Write(MaskJSON(w.body.Bytes(), w.fields))
+}
+
+// MaskJSON aplica as máscaras dos campos indicados a todas as chaves de mesmo
+// nome do corpo JSON.
+func MaskJSON(body []byte, fields []string) []byte {
+	masked := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		masked[field] = true
+	}
+
+	return jsonField.ReplaceAllFunc(body, func(match []byte) []byte {
+		groups := jsonField.FindSubmatch(match)
+		field := string(groups[1])
+		if !masked[field] {
+			return match
+		}
+
+		var value string
+		if err := json.Unmarshal(append(append([]byte(`"`), groups[3]...), '"'), &value); err != nil {
+			return match
+		}
+		encoded, _ := json.Marshal(maskers[field](value))
+
+		var out []byte
+		out = append(out, '"')
+		out = append(out, groups[1]...)
+		out = append(out, '"')
+		out = append(out, groups[2]...)
+		return append(out, encoded...)
+	})
+}
@@ -0,0 +1,161 @@
+package masking
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+)
+
+// Campos de contato que a política pode mascarar. Os nomes são os mesmos das
+// chaves JSON.
+const (
+	FieldEmail = "email"
+	FieldPhone = "phone"
+)
+
+var ErrInvalidPolicy = errors.New("invalid masking policy, expected role=field[,field];...")
+
+var maskers = map[string]func(string) string{
+	FieldEmail: MaskEmail,
+	FieldPhone: MaskPhone,
+}
+
+// Policy diz, para cada papel, quais campos chegam mascarados a quem fez a
+// requisição. Papéis fora da política veem os dados completos.
+type Policy map[string][]string
+
+// DefaultPolicy mascara e-mail e telefone para estagiários.
+func DefaultPolicy() Policy {
+	return Policy{models.RoleIntern: {FieldEmail, FieldPhone}}
+}
+
+var (
+	policyMu sync.RWMutex
+	policy   = DefaultPolicy()
+)
+
+func Configure(p Policy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policy = p
+}
+
+// Current devolve a política em uso.
+func Current() Policy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policy
+}
+
+// ParsePolicy lê uma política no formato "intern=email,phone;reader=phone".
+// Um papel sem campos, como "intern=", deixa de ser mascarado.
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		role, fields, ok := strings.Cut(entry, "=")
+		role = strings.TrimSpace(role)
+		if !ok || !models.IsValidRole(role) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPolicy, entry)
+		}
+
+		p[role] = []string{}
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if _, ok := maskers[field]; !ok {
+				return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidPolicy, field)
+			}
+			p[role] = append(p[role], field)
+		}
+	}
+	return p, nil
+}
+
+// PolicyFromEnv lê PII_MASKING_POLICY ou, quando ela não está definida,
+// devolve DefaultPolicy.
+func PolicyFromEnv() (Policy, error) {
+	value, ok := os.LookupEnv("PII_MASKING_POLICY")
+	if !ok {
+		return DefaultPolicy(), nil
+	}
+	return ParsePolicy(value)
+}
+
+// Masks informa se o papel recebe algum campo mascarado.
+func (p Policy) Masks(role string) bool {
+	return len(p[role]) > 0
+}
+
+// Contact devolve o contato como o papel deve vê-lo.
+func (p Policy) Contact(role string, contact models.Contact) models.Contact {
+	for _, field := range p[role] {
+		switch field {
+		case FieldEmail:
+			contact.Email = MaskEmail(contact.Email)
+		case FieldPhone:
+			contact.Phone = MaskPhone(contact.Phone)
+		}
+	}
+	return contact
+}
+
+// Contacts aplica Contact a cada contato, sem alterar o slice recebido.
+func (p Policy) Contacts(role string, contacts []models.Contact) []models.Contact {
+	if !p.Masks(role) || contacts == nil {
+		return contacts
+	}
+	masked := make([]models.Contact, len(contacts))
+	for i, contact := range contacts {
+		masked[i] = p.Contact(role, contact)
+	}
+	return masked
+}
+
+// MaskEmail mantém a primeira letra e o domínio: "joao@email.com" vira
+// "j***@email.com".
+func MaskEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return "***"
+	}
+	if local == "" {
+		return "***@" + domain
+	}
+	return local[:1] + "***@" + domain
+}
+
+// MaskPhone mantém só os três primeiros e os quatro últimos dígitos:
+// "(11) 99999-8888" vira "119****8888". Números curtos são mascarados por
+// inteiro. Aplicar a máscara a um valor já mascarado não o altera.
+func MaskPhone(phone string) string {
+	var digits []byte
+	for i := 0; i < len(phone); i++ {
+		if (phone[i] >= '0' && phone[i] <= '9') || phone[i] == '*' {
+			digits = append(digits, phone[i])
+		}
+	}
+	if len(digits) == 0 {
+		return ""
+	}
+	if len(digits) <= 7 {
+		return strings.Repeat("*", len(digits))
+	}
+	for i := 3; i < len(digits)-4; i++ {
+		digits[i] = '*'
+	}
+	return string(digits)
+}
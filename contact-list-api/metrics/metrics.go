@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry reúne as métricas expostas em /metrics. É separado do registro
+// global do client_golang para que nada além do que está aqui apareça na
+// resposta.
+var Registry = prometheus.NewRegistry()
+
+// Operações de armazenamento usadas no rótulo operation: load e save leem e
+// gravam o snapshot inteiro; append acrescenta um registro ao journal.
+const (
+	OperationLoad   = "load"
+	OperationSave   = "save"
+	OperationAppend = "append"
+)
+
+// Resultados da releitura de contacts.json editado fora do servidor, usados
+// no rótulo result.
+const (
+	ReloadApplied   = "applied"
+	ReloadUnchanged = "unchanged"
+	ReloadRejected  = "rejected"
+)
+
+// Campos de busca, usados no rótulo field das buscas e no rótulo index dos
+// índices cegos.
+const (
+	FieldName  = "name"
+	FieldEmail = "email"
+	FieldPhone = "phone"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requisições HTTP atendidas, por método, rota e status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Tempo de atendimento das requisições HTTP, por método, rota e status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "contacts_storage_duration_seconds",
+		Help:    "Tempo de leitura e gravação do snapshot e do journal de contatos.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	storageBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "contacts_storage_size_bytes",
+		Help:    "Tamanho do snapshot lido ou gravado e dos registros acrescentados ao journal.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+	}, []string{"operation"})
+
+	contactsTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "contacts_total",
+		Help: "Contatos do tenant na última leitura ou gravação.",
+	}, []string{"tenant"})
+
+	searchIndexEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "contacts_search_index_entries",
+		Help: "Contatos com entrada nos índices cegos de e-mail e telefone.",
+	}, []string{"tenant", "index"})
+
+	searches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "contacts_searches_total",
+		Help: "Buscas de contatos, pelo campo pesquisado.",
+	}, []string{"field"})
+
+	searchResults = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "contacts_search_results",
+		Help:    "Quantidade de contatos devolvidos por busca.",
+		Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100, 250},
+	}, []string{"field"})
+
+	fileReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "contacts_file_reloads_total",
+		Help: "Edições de contacts.json feitas fora do servidor, pelo resultado da releitura.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		storageDuration, storageBytes,
+		contactsTotal, searchIndexEntries,
+		searches, searchResults,
+		fileReloads,
+	)
+}
+
+// ObserveRequest registra uma requisição atendida.
+func ObserveRequest(method, route, status string, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+}
+
+// ObserveStorage registra uma leitura ou gravação do arquivo de contatos.
+func ObserveStorage(operation string, size int, duration time.Duration) {
+	storageDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	storageBytes.WithLabelValues(operation).Observe(float64(size))
+}
+
+// SetContactTotals atualiza o total de contatos do tenant e as entradas dos
+// índices cegos. Sem a cifra de campos, os índices ficam zerados.
+func SetContactTotals(tenantID string, total, emailIndexed, phoneIndexed int) {
+	contactsTotal.WithLabelValues(tenantID).Set(float64(total))
+	searchIndexEntries.WithLabelValues(tenantID, FieldEmail).Set(float64(emailIndexed))
+	searchIndexEntries.WithLabelValues(tenantID, FieldPhone).Set(float64(phoneIndexed))
+}
+
+// ObserveSearch registra uma busca pelo campo e quantos contatos ela devolveu.
+func ObserveSearch(field string, results int) {
+	searches.WithLabelValues(field).Inc()
+	searchResults.WithLabelValues(field).Observe(float64(results))
+}
+
+// ObserveReload registra uma releitura de contacts.json editado fora do
+// servidor.
+func ObserveReload(result string) {
+	fileReloads.WithLabelValues(result).Inc()
+}
+
+// DeleteTenant remove as séries do tenant, para que um tenant apagado não
+// continue aparecendo com o último total conhecido.
+func DeleteTenant(tenantID string) {
+	contactsTotal.DeletePartialMatch(prometheus.Labels{"tenant": tenantID})
+	searchIndexEntries.DeletePartialMatch(prometheus.Labels{"tenant": tenantID})
+}
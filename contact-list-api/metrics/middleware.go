@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute agrupa as requisições que não casaram com nenhuma rota, para
+// que caminhos arbitrários não criem uma série nova cada um.
+const unmatchedRoute = "unmatched"
+
+// Requests mede cada requisição pela rota registrada no gin, como
+// "/contacts/:id", e não pelo caminho, que inclui os IDs.
+func Requests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		ObserveRequest(c.Request.Method, route, strconv.Itoa(c.Writer.Status()), time.Since(start))
+	}
+}
+
+// Handler expõe Registry no formato de texto do Prometheus.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
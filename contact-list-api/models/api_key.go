@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	ID        int       `json:"id" example:"1"`
+	Name      string    `json:"name" example:"dashboard"`
+	Prefix    string    `json:"prefix" example:"clk_1a2b3c4d"`
+	Hash      string    `json:"hash,omitempty"`
+	Role      string    `json:"role" example:"reader"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Ações registradas no log de auditoria de acesso.
+const (
+	AuditRead   = "read"
+	AuditList   = "list"
+	AuditSearch = "search"
+	AuditExport = "export"
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+func IsValidAuditAction(action string) bool {
+	switch action {
+	case AuditRead, AuditList, AuditSearch, AuditExport, AuditCreate, AuditUpdate, AuditDelete:
+		return true
+	}
+	return false
+}
+
+// AuditEntry registra quem acessou ou alterou quais contatos. As entradas
+// formam uma cadeia: Hash, um HMAC com a chave do servidor, cobre a entrada
+// inteira, incluindo PrevHash, o hash da anterior, então alterar ou remover
+// uma entrada quebra todas as seguintes.
+type AuditEntry struct {
+	Seq        int64     `json:"seq" example:"1"`
+	Timestamp  time.Time `json:"timestamp"`
+	Actor      string    `json:"actor" example:"api-key:1"`
+	Action     string    `json:"action" example:"read"`
+	ContactIDs []int     `json:"contact_ids"`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	RequestID  string    `json:"request_id,omitempty" example:"4f9c2a7e1b3d5c8a"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Canais de comunicação que exigem consentimento.
+const (
+	ChannelEmail = "email"
+	ChannelPhone = "phone"
+	ChannelSMS   = "sms"
+)
+
+const (
+	ConsentGranted = "granted"
+	ConsentRevoked = "revoked"
+)
+
+// PurposeMarketing é a finalidade usada nas exportações de marketing; outras
+// finalidades podem ser registradas livremente.
+const PurposeMarketing = "marketing"
+
+// ConsentRecord registra uma concessão ou revogação de consentimento. Os
+// registros nunca são alterados: o estado atual de cada canal e finalidade
+// é o registro mais recente, e os anteriores servem de prova. AddressHash
+// é o hash do e-mail ou telefone que o contato tinha ao registrar, e a
+// concessão só vale enquanto o endereço do canal for o mesmo; as respostas
+// o omitem.
+type ConsentRecord struct {
+	ID          int       `json:"id" example:"1"`
+	ContactID   int       `json:"contact_id" example:"3"`
+	Channel     string    `json:"channel" example:"email"`
+	Purpose     string    `json:"purpose" example:"marketing"`
+	Status      string    `json:"status" example:"granted"`
+	Source      string    `json:"source" example:"formulario-site"`
+	RecordedBy  string    `json:"recorded_by" example:"joao"`
+	RecordedAt  time.Time `json:"recorded_at"`
+	AddressHash string    `json:"address_hash,omitempty" swaggerignore:"true"`
+}
+
+func IsValidChannel(channel string) bool {
+	return channel == ChannelEmail || channel == ChannelPhone || channel == ChannelSMS
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Tipos de pedido de titular de dados (LGPD).
+const (
+	PrivacyRequestExport  = "export"
+	PrivacyRequestErasure = "erasure"
+)
+
+// PrivacyRequest é a entrada de auditoria de um pedido de titular. Não guarda
+// e-mail, telefone nem qualquer dado do titular, apenas quem pediu, quando e
+// quantos registros foram encontrados ou apagados.
+type PrivacyRequest struct {
+	ID          int            `json:"id" example:"1"`
+	Type        string         `json:"type" example:"erasure"`
+	RequestedBy string         `json:"requested_by" example:"api-key:1"`
+	Records     map[string]int `json:"records"`
+	Verified    bool           `json:"verified,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CompletedAt time.Time      `json:"completed_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// Papéis de acesso, do menos ao mais privilegiado. Cada papel inclui as
+// permissões dos anteriores. RoleIntern tem o acesso de RoleReader, mas recebe
+// e-mail e telefone mascarados (veja o pacote masking).
+const (
+	RoleIntern = "intern"
+	RoleReader = "reader"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleIntern: 1,
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAllows informa se quem tem o papel have pode executar algo que exige o
+// papel need.
+func RoleAllows(have, need string) bool {
+	return IsValidRole(have) && roleLevels[have] >= roleLevels[need]
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Níveis de acesso a contatos de outra agenda. PermissionOwner não é
+// concedido por compartilhamento; indica o dono do contato.
+const (
+	PermissionRead  = "read"
+	PermissionEdit  = "edit"
+	PermissionOwner = "owner"
+)
+
+// Share concede a um usuário acesso a um contato ou, com ContactID zero, à
+// agenda inteira de OwnerID.
+type Share struct {
+	ID         int       `json:"id" example:"1"`
+	OwnerID    int       `json:"owner_id" example:"1"`
+	GranteeID  int       `json:"grantee_id" example:"2"`
+	Grantee    string    `json:"grantee" example:"maria"`
+	ContactID  int       `json:"contact_id,omitempty" example:"3"`
+	Permission string    `json:"permission" example:"read"`
+	CreatedAt  time.Time `json:"created_at"`
+}
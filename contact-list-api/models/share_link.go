@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ShareLink é um link público, assinado e com validade, para um único
+// contato. MaxUses zero indica usos ilimitados até a expiração. NonceHash é
+// o SHA-256 do valor aleatório que vai no token; só é gravado, nunca
+// devolvido pela API.
+type ShareLink struct {
+	ID        int       `json:"id" example:"1"`
+	NonceHash string    `json:"nonce_hash,omitempty" swaggerignore:"true"`
+	ContactID int       `json:"contact_id" example:"3"`
+	OwnerID   int       `json:"owner_id" example:"1"`
+	MaxUses   int       `json:"max_uses,omitempty" example:"5"`
+	Uses      int       `json:"uses" example:"0"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PublicContact é a visão somente leitura de um contato aberta por um link
+// público, sem identificadores internos.
+type PublicContact struct {
+	Name  string `json:"name" example:"Fernanda Lima"`
+	Email string `json:"email,omitempty" example:"fernanda.lima@yahoo.com"`
+	Phone string `json:"phone,omitempty" example:"551198765432"`
+}
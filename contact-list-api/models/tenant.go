@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DefaultTenantID identifica o tenant usado quando a requisição não indica
+// nenhum. Seus dados ficam nos arquivos anteriores à separação por tenant.
+const DefaultTenantID = ""
+
+type Tenant struct {
+	ID   string `json:"id" example:"acme"`
+	Name string `json:"name" example:"Acme Ltda."`
+	// MaxContacts limita quantos contatos o tenant pode ter, somando todas as
+	// agendas. Zero significa sem limite.
+	MaxContacts int       `json:"max_contacts" example:"1000"`
+	CreatedAt   time.Time `json:"created_at"`
+}
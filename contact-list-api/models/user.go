@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SharedOwnerID identifica a agenda compartilhada, anterior às contas de
+// usuário. Ela continua acessível às chaves de API e aos tokens JWT.
+const SharedOwnerID = 0
+
+type User struct {
+	ID           int       `json:"id" example:"1"`
+	Username     string    `json:"username" example:"joao"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Session struct {
+	UserID    int       `json:"user_id"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type Webhook struct {
+	ID        int       `json:"id" example:"1"`
+	URL       string    `json:"url" example:"https://crm.example.com/hooks/contacts"`
+	Events    []string  `json:"events" example:"created,updated,deleted"`
+	Secret    string    `json:"secret,omitempty" example:"s3cr3t"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int       `json:"id"`
+	TenantID       string    `json:"tenant_id,omitempty"`
+	WebhookID      int       `json:"webhook_id"`
+	EventID        int64     `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Status         string    `json:"status" example:"delivered"`
+	Attempts       int       `json:"attempts"`
+	LastStatusCode int       `json:"last_status_code,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
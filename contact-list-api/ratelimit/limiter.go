@@ -0,0 +1,292 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit, expected <requests per second>,<burst>")
+
+// Limit é um token bucket: Burst requisições seguidas e, depois, Rate por
+// segundo.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Config reúne os limites. Routes usa a chave "MÉTODO /rota", com a rota como
+// registrada no gin (por exemplo "GET /contacts/search"), e cada rota listada
+// tem um bucket próprio por cliente; as demais dividem o bucket de Default.
+// DailyQuota limita o total de requisições do cliente por dia (UTC); zero
+// desliga a cota.
+type Config struct {
+	Default    Limit
+	Routes     map[string]Limit
+	DailyQuota int
+}
+
+// DefaultConfig é usada quando as variáveis de ambiente não são definidas.
+// A busca tem um limite menor, pois percorre todos os contatos do tenant a
+// cada requisição.
+func DefaultConfig() Config {
+	return Config{
+		Default: Limit{Rate: 10, Burst: 20},
+		Routes: map[string]Limit{
+			"GET /contacts/search": {Rate: 2, Burst: 5},
+		},
+	}
+}
+
+// DefaultIPConfig é o limite por IP aplicado antes da autenticação. É mais
+// folgado que o por cliente, pois vários clientes podem sair pelo mesmo IP,
+// e nunca tem cota diária. O login, que só passa por este limite, tem um
+// bucket próprio e pequeno por ser alvo de tentativas de senha.
+func DefaultIPConfig() Config {
+	return Config{
+		Default: Limit{Rate: 50, Burst: 100},
+		Routes: map[string]Limit{
+			"POST /auth/login": {Rate: 0.2, Burst: 5},
+		},
+	}
+}
+
+// ParseLimit lê um limite no formato "2,5": duas requisições por segundo e
+// rajadas de até cinco.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(s), ",")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	if err != nil || r <= 0 || math.IsInf(r, 0) {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	b, err := strconv.Atoi(strings.TrimSpace(burst))
+	if err != nil || b < 1 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+// ConfigFromEnv parte de DefaultConfig e aplica RATE_LIMIT_DEFAULT ("10,20"),
+// RATE_LIMIT_ROUTES ("GET /contacts/search=2,5") e RATE_LIMIT_DAILY_QUOTA.
+func ConfigFromEnv() (Config, error) {
+	c := DefaultConfig()
+	if err := applyLimitsFromEnv(&c, "RATE_LIMIT_DEFAULT", "RATE_LIMIT_ROUTES"); err != nil {
+		return Config{}, err
+	}
+
+	if value := os.Getenv("RATE_LIMIT_DAILY_QUOTA"); value != "" {
+		quota, err := strconv.Atoi(value)
+		if err != nil || quota < 0 {
+			return Config{}, fmt.Errorf("RATE_LIMIT_DAILY_QUOTA: invalid value %q", value)
+		}
+		c.DailyQuota = quota
+	}
+
+	return c, nil
+}
+
+// IPConfigFromEnv parte de DefaultIPConfig e aplica RATE_LIMIT_IP_DEFAULT
+// ("50,100") e RATE_LIMIT_IP_ROUTES ("POST /auth/login=0.2,5").
+func IPConfigFromEnv() (Config, error) {
+	c := DefaultIPConfig()
+	if err := applyLimitsFromEnv(&c, "RATE_LIMIT_IP_DEFAULT", "RATE_LIMIT_IP_ROUTES"); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func applyLimitsFromEnv(c *Config, defaultName, routesName string) error {
+	if value := os.Getenv(defaultName); value != "" {
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", defaultName, err)
+		}
+		c.Default = limit
+	}
+
+	if value := os.Getenv(routesName); value != "" {
+		for _, entry := range strings.Split(value, ";") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			route, limitValue, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("%s: %w: %q", routesName, ErrInvalidLimit, entry)
+			}
+			limit, err := ParseLimit(limitValue)
+			if err != nil {
+				return fmt.Errorf("%s: %w", routesName, err)
+			}
+			c.Routes[strings.Join(strings.Fields(route), " ")] = limit
+		}
+	}
+	return nil
+}
+
+// Decision é o resultado de Allow para uma requisição.
+type Decision struct {
+	Allowed bool
+	// Limit e Remaining descrevem o bucket usado; Reset é o tempo até ele
+	// encher de novo.
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	// RetryAfter só é preenchido quando a requisição é recusada.
+	RetryAfter time.Duration
+	// QuotaLimit é zero quando não há cota diária.
+	QuotaLimit     int
+	QuotaRemaining int
+	QuotaExceeded  bool
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+type dailyUsage struct {
+	day   string
+	count int
+}
+
+// sweepInterval é o intervalo mínimo entre as limpezas de buckets cheios,
+// que não mudam o resultado de Allow e só ocupariam memória.
+const sweepInterval = time.Minute
+
+// Limiter guarda os buckets e as cotas de cada cliente em memória.
+type Limiter struct {
+	mu        sync.Mutex
+	config    Config
+	now       func() time.Time
+	buckets   map[string]*bucket
+	usage     map[string]*dailyUsage
+	lastSweep time.Time
+}
+
+// NewLimiter cria um limitador. now permite fixar o relógio nos testes; nil
+// usa time.Now.
+func NewLimiter(config Config, now func() time.Time) *Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &Limiter{
+		config:  config,
+		now:     now,
+		buckets: map[string]*bucket{},
+		usage:   map[string]*dailyUsage{},
+	}
+}
+
+// Allow consome um token do bucket do cliente para a rota e uma unidade da
+// cota diária. Requisições recusadas não consomem nada.
+func (l *Limiter) Allow(client, route string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	limit, ok := l.config.Routes[route]
+	bucketKey := client + "|" + route
+	if !ok {
+		limit = l.config.Default
+		bucketKey = client + "|*"
+	}
+
+	b, ok := l.buckets[bucketKey]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		l.buckets[bucketKey] = b
+	}
+	b.refill(now)
+
+	decision := Decision{Limit: limit.Burst}
+
+	var usage *dailyUsage
+	if quota := l.config.DailyQuota; quota > 0 {
+		day := now.UTC().Format("2006-01-02")
+		usage = l.usage[client]
+		if usage == nil || usage.day != day {
+			usage = &dailyUsage{day: day}
+			l.usage[client] = usage
+		}
+		decision.QuotaLimit = quota
+		decision.QuotaRemaining = quota - usage.count
+
+		if usage.count >= quota {
+			decision.QuotaExceeded = true
+			decision.Remaining = int(b.tokens)
+			decision.Reset = b.untilFull()
+			decision.RetryAfter = untilNextDay(now)
+			return decision
+		}
+	}
+
+	if b.tokens < 1 {
+		decision.Remaining = 0
+		decision.Reset = b.untilFull()
+		decision.RetryAfter = time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return decision
+	}
+
+	b.tokens--
+	if usage != nil {
+		usage.count++
+		decision.QuotaRemaining--
+	}
+	decision.Allowed = true
+	decision.Remaining = int(b.tokens)
+	decision.Reset = b.untilFull()
+	return decision
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+func (b *bucket) untilFull() time.Duration {
+	missing := float64(b.limit.Burst) - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.limit.Rate * float64(time.Second))
+}
+
+func untilNextDay(now time.Time) time.Duration {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	day := now.UTC().Format("2006-01-02")
+	for client, usage := range l.usage {
+		if usage.day != day {
+			delete(l.usage, client)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	limiterMu sync.RWMutex
+	limiter   *Limiter
+	ipLimiter *Limiter
+)
+
+// Configure define o limitador usado por Middleware. Sem limitador, o que
+// acontece até main configurar um, as requisições passam sem limite.
+func Configure(l *Limiter) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	limiter = l
+}
+
+// ConfigureIP define o limitador usado por IPMiddleware, com as mesmas
+// regras de Configure.
+func ConfigureIP(l *Limiter) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	ipLimiter = l
+}
+
+func currentLimiter() *Limiter {
+	limiterMu.RLock()
+	defer limiterMu.RUnlock()
+	return limiter
+}
+
+func currentIPLimiter() *Limiter {
+	limiterMu.RLock()
+	defer limiterMu.RUnlock()
+	return ipLimiter
+}
+
+// IPMiddleware aplica o limitador de ConfigureIP, que conta antes da
+// autenticação e tem configuração própria, sem cota diária (veja
+// DefaultIPConfig). key deve devolver o IP do cliente.
+func IPMiddleware(key func(*gin.Context) string) gin.HandlerFunc {
+	return middleware(key, currentIPLimiter)
+}
+
+// Middleware aplica o limitador de Configure à requisição. key identifica o
+// cliente depois da autenticação, pela chave de API ou pelo usuário.
+//
+// As respostas trazem RateLimit-Limit, RateLimit-Remaining e RateLimit-Reset
+// e, com cota diária, X-RateLimit-Daily-Limit e X-RateLimit-Daily-Remaining.
+// Requisições recusadas recebem 429 com Retry-After.
+func Middleware(key func(*gin.Context) string) gin.HandlerFunc {
+	return middleware(key, currentLimiter)
+}
+
+func middleware(key func(*gin.Context) string, current func() *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := current()
+		if l == nil {
+			c.Next()
+			return
+		}
+
+		decision := l.Allow(key(c), c.Request.Method+" "+c.FullPath())
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		h.Set("RateLimit-Reset", seconds(decision.Reset))
+		if decision.QuotaLimit > 0 {
+			h.Set("X-RateLimit-Daily-Limit", strconv.Itoa(decision.QuotaLimit))
+			h.Set("X-RateLimit-Daily-Remaining", strconv.Itoa(max(decision.QuotaRemaining, 0)))
+		}
+
+		if !decision.Allowed {
+			h.Set("Retry-After", seconds(decision.RetryAfter))
+			message := "rate limit exceeded"
+			if decision.QuotaExceeded {
+				message = "daily request quota exceeded"
+			}
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// seconds arredonda para cima, para que o cliente que espera o tempo indicado
+// não seja recusado de novo por frações de segundo.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
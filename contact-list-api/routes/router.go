@@ -1,21 +1,133 @@
 package routes
 
 import (
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/handlers"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/masking"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/ratelimit"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(router *gin.Engine) {
-	contactGroup := router.Group("/contacts")
+	// Toda requisição passa primeiro pelo limite por IP, antes da
+	// autenticação, para que tentativas com credenciais inválidas também
+	// contem. Depois da autenticação, um segundo limite, com a cota diária,
+	// conta por chave de API ou usuário.
+	limitIP := ratelimit.IPMiddleware(auth.ClientIP)
+	limit := ratelimit.Middleware(auth.ClientID)
+
+	// As sondas do orquestrador não se autenticam nem entram no limite.
+	router.GET("/healthz", handlers.Healthz)
+	router.GET("/readyz", handlers.Readyz)
+
+	contactGroup := router.Group("/contacts", limitIP, tenancy.Resolve(), auth.Authenticate(), limit, masking.Responses(auth.Role))
+	{
+		readers := contactGroup.Group("", auth.RequireRole(models.RoleReader))
+		readers.GET("/", handlers.GetContacts)
+		readers.GET("/:id", handlers.GetContactByID)
+		readers.GET("/summary", handlers.GetContactsSummary)
+		readers.GET("/search", handlers.SearchContactsByName)
+		readers.GET("/email-providers", handlers.GetEmailProviders)
+		readers.GET("/shared", handlers.GetSharedWithMe)
+		readers.GET("/export", handlers.ExportContacts)
+		readers.GET("/consents/missing", handlers.GetContactsWithoutConsent)
+		readers.GET("/:id/consents", handlers.GetContactConsents)
+		readers.GET("/sync", handlers.SyncContacts)
+		readers.GET("/events", handlers.StreamContactEvents)
+		readers.GET("/events/ws", handlers.ContactEventsWebSocket)
+
+		editors := contactGroup.Group("", auth.RequireRole(models.RoleEditor))
+		editors.POST("/", handlers.CreateContact)
+		editors.PUT("/:id", handlers.UpdateContactById)
+		editors.DELETE("/:id", handlers.DeleteContact)
+		editors.POST("/:id/consents", handlers.RecordConsent)
+		editors.GET("/:id/share-links", handlers.GetShareLinks)
+		editors.POST("/:id/share-links", handlers.CreateShareLink)
+		editors.DELETE("/:id/share-links/:linkId", handlers.RevokeShareLink)
+	}
+
+	// Links públicos não usam autenticação; o token assinado identifica o
+	// tenant e o contato.
+	router.GET("/public/contacts/:token", limitIP, handlers.GetPublicContact)
+
+	webhookGroup := router.Group("/webhooks", limitIP, tenancy.Resolve(), auth.Authenticate(), limit, auth.RequireRole(models.RoleAdmin))
+	{
+		webhookGroup.GET("/", handlers.GetWebhooks)
+		webhookGroup.POST("/", handlers.CreateWebhook)
+		webhookGroup.DELETE("/:id", handlers.DeleteWebhook)
+		webhookGroup.GET("/deliveries", handlers.GetWebhookDeliveries)
+		webhookGroup.GET("/dead-letters", handlers.GetWebhookDeadLetters)
+		webhookGroup.POST("/dead-letters/:id/retry", handlers.RetryWebhookDeadLetter)
+	}
+
+	shareGroup := router.Group("/shares", limitIP, tenancy.Resolve(), auth.Authenticate(), limit, auth.RequireRole(models.RoleEditor))
+	{
+		shareGroup.GET("/", handlers.GetShares)
+		shareGroup.POST("/", handlers.CreateShare)
+		shareGroup.DELETE("/:id", handlers.RevokeShare)
+	}
+
+	privacyGroup := router.Group("/privacy", limitIP, tenancy.Resolve(), auth.Authenticate(), limit, auth.RequireRole(models.RoleAdmin))
 	{
-		contactGroup.GET("/", handlers.GetContacts)
-		contactGroup.POST("/", handlers.CreateContact)
-		contactGroup.GET("/:id", handlers.GetContactByID)
-		contactGroup.PUT("/:id", handlers.UpdateContactById)
-		contactGroup.DELETE("/:id", handlers.DeleteContact)
-		contactGroup.GET("/summary", handlers.GetContactsSummary)
-		contactGroup.GET("/search", handlers.SearchContactsByName)
-		contactGroup.GET("/email-providers", handlers.GetEmailProviders)
+		privacyGroup.POST("/export", handlers.ExportSubjectData)
+		privacyGroup.POST("/erasure", handlers.RequestErasure)
+		privacyGroup.POST("/erasure/confirm", handlers.ConfirmErasure)
+		privacyGroup.GET("/requests", handlers.GetPrivacyRequests)
 	}
+
+	authGroup := router.Group("/auth", limitIP, tenancy.Resolve())
+	{
+		authGroup.POST("/register", handlers.RegisterUser)
+		authGroup.POST("/login", handlers.Login)
+
+		authenticated := authGroup.Group("", auth.Authenticate(), limit)
+		authenticated.GET("/me", handlers.GetCurrentPrincipal)
+		authenticated.POST("/logout", handlers.Logout)
+
+		admins := authenticated.Group("", auth.RequireRole(models.RoleAdmin))
+		admins.GET("/api-keys", handlers.GetAPIKeys)
+		admins.POST("/api-keys", handlers.CreateAPIKey)
+		admins.DELETE("/api-keys/:id", handlers.RevokeAPIKey)
+	}
+
+	auditGroup := router.Group("/audit", limitIP, tenancy.Resolve(), auth.Authenticate(), limit, auth.RequireRole(models.RoleAdmin))
+	{
+		auditGroup.GET("/", handlers.GetAuditLog)
+		auditGroup.GET("/verify", handlers.VerifyAuditLog)
+	}
+
+	tenantGroup := router.Group("/tenants", limitIP, auth.Authenticate(), limit, auth.RequirePlatformAdmin())
+	{
+		tenantGroup.GET("/", handlers.GetTenants)
+		tenantGroup.POST("/", handlers.CreateTenant)
+		tenantGroup.GET("/:id", handlers.GetTenant)
+		tenantGroup.PUT("/:id", handlers.UpdateTenant)
+		tenantGroup.DELETE("/:id", handlers.DeleteTenant)
+	}
+
+	adminGroup := router.Group("/admin", limitIP, auth.Authenticate(), limit, auth.RequirePlatformAdmin())
+	{
+		adminGroup.POST("/encryption/reencrypt", handlers.ReencryptContacts)
+		adminGroup.GET("/storage/integrity", handlers.CheckStorageIntegrity)
+		adminGroup.POST("/storage/recover", handlers.RecoverStorage)
+		adminGroup.GET("/log-level", handlers.GetLogLevel)
+		adminGroup.PUT("/log-level", handlers.SetLogLevel)
+	}
+}
+
+// SetupCardDAVRoutes monta o servidor CardDAV somente leitura e o endereço de
+// descoberta /.well-known/carddav.
+func SetupCardDAVRoutes(router *gin.Engine) {
+	limitIP := ratelimit.IPMiddleware(auth.ClientIP)
+	limit := ratelimit.Middleware(auth.ClientID)
+	methods := []string{"OPTIONS", "PROPFIND", "REPORT", "GET", "HEAD", "PUT", "DELETE", "PROPPATCH", "MKCOL", "COPY", "MOVE"}
+	for _, method := range methods {
+		router.Handle(method, "/carddav/*path", limitIP, tenancy.Resolve(), auth.Authenticate(), limit, auth.RequireRole(models.RoleReader), handlers.CardDAV)
+	}
+
+	router.GET("/.well-known/carddav", limitIP, handlers.CardDAVWellKnown)
+	router.Handle("PROPFIND", "/.well-known/carddav", limitIP, handlers.CardDAVWellKnown)
 }
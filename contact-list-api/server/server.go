@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config define o endereço e os limites de tempo do servidor HTTP.
+type Config struct {
+	Addr string
+	// ReadTimeout limita a leitura da requisição inteira, corpo incluído.
+	ReadTimeout time.Duration
+	// WriteTimeout limita o tempo até o fim da resposta. Os streams de
+	// eventos removem o limite da própria conexão, e as rotas com prazo
+	// maior o estendem (veja deadline.Config).
+	WriteTimeout time.Duration
+	// IdleTimeout é quanto uma conexão keep-alive pode ficar parada.
+	IdleTimeout time.Duration
+	// ShutdownTimeout é quanto o desligamento espera as requisições em
+	// andamento antes de fechar as conexões à força.
+	ShutdownTimeout time.Duration
+	// TrustedProxies lista os IPs e as redes (CIDR) dos proxies cujo
+	// X-Forwarded-For é aceito como IP do cliente. Vazia, o IP é o da
+	// conexão e o cabeçalho é ignorado.
+	TrustedProxies []string
+}
+
+// DefaultConfig é usada quando as variáveis de ambiente não são definidas.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// ConfigFromEnv parte de DefaultConfig e aplica SERVER_ADDR e as durações
+// SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT e
+// SHUTDOWN_TIMEOUT, no formato de time.ParseDuration ("15s", "1m"), e
+// TRUSTED_PROXIES, com IPs ou CIDRs separados por vírgula.
+func ConfigFromEnv() (Config, error) {
+	c := DefaultConfig()
+	if addr := os.Getenv("SERVER_ADDR"); addr != "" {
+		c.Addr = addr
+	}
+
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return Config{}, fmt.Errorf("TRUSTED_PROXIES: invalid IP or CIDR %q", proxy)
+		}
+		c.TrustedProxies = append(c.TrustedProxies, proxy)
+	}
+
+	durations := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", &c.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", &c.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", &c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", &c.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		value := os.Getenv(d.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			return Config{}, fmt.Errorf("%s: invalid duration %q", d.name, value)
+		}
+		*d.value = parsed
+	}
+	return c, nil
+}
+
+// Run escuta em config.Addr e chama Serve.
+func Run(ctx context.Context, handler http.Handler, config Config, onShutdown func()) error {
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, listener, handler, config, onShutdown)
+}
+
+// Serve atende as conexões de listener até ctx terminar, normalmente por
+// SIGTERM. Então chama onShutdown, que deve tirar o servidor da prontidão e
+// encerrar os streams longos, para de aceitar conexões e espera as
+// requisições em andamento, inclusive as gravações, por até ShutdownTimeout.
+// Depois disso, as conexões restantes são fechadas e Serve devolve o erro.
+func Serve(ctx context.Context, listener net.Listener, handler http.Handler, config Config, onShutdown func()) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "timeout", config.ShutdownTimeout)
+	if onShutdown != nil {
+		onShutdown()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const apiKeyPrefix = "clk_"
+
+var (
+	ErrInvalidRole    = errors.New("invalid role, expected intern, reader, editor or admin")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// CreateAPIKey gera uma nova chave para o papel informado. A chave em texto
+// puro só é devolvida aqui; o arquivo guarda apenas o hash SHA-256, que basta
+// porque a chave tem 256 bits aleatórios.
+func CreateAPIKey(ctx context.Context, tenantID, name, role string) (string, models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "services.CreateAPIKey")
+	defer span.End()
+
+	if !models.IsValidRole(role) {
+		return "", models.APIKey{}, ErrInvalidRole
+	}
+
+	publicPart := make([]byte, 4)
+	secretPart := make([]byte, 32)
+	if _, err := rand.Read(publicPart); err != nil {
+		return "", models.APIKey{}, err
+	}
+	if _, err := rand.Read(secretPart); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(publicPart)
+	plaintext := prefix + "_" + hex.EncodeToString(secretPart)
+
+	keys, err := storage.LoadAPIKeys(ctx, tenantID)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	maxID := 0
+	for _, k := range keys {
+		if k.ID > maxID {
+			maxID = k.ID
+		}
+	}
+
+	key := models.APIKey{
+		ID:        maxID + 1,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      HashAPIKey(plaintext),
+		Role:      role,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	keys = append(keys, key)
+	if err := storage.SaveAPIKeys(ctx, tenantID, keys); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	key.Hash = ""
+	return plaintext, key, nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func GetAllAPIKeys(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "services.GetAllAPIKeys")
+	defer span.End()
+
+	keys, err := storage.LoadAPIKeys(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+	return keys, nil
+}
+
+func RevokeAPIKey(ctx context.Context, tenantID string, id int) error {
+	ctx, span := tracing.Start(ctx, "services.RevokeAPIKey")
+	defer span.End()
+
+	keys, err := storage.LoadAPIKeys(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.APIKey
+	found := false
+	for _, k := range keys {
+		if k.ID == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, k)
+	}
+
+	if !found {
+		return ErrAPIKeyNotFound
+	}
+
+	return storage.SaveAPIKeys(ctx, tenantID, remaining)
+}
+
+// VerifyAPIKey procura a chave pelo prefixo público e compara o hash em tempo
+// constante.
+func VerifyAPIKey(ctx context.Context, tenantID, plaintext string) (models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "services.VerifyAPIKey")
+	defer span.End()
+
+	index := strings.LastIndex(plaintext, "_")
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) || index <= len(apiKeyPrefix) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	prefix := plaintext[:index]
+
+	keys, err := storage.LoadAPIKeys(ctx, tenantID)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	hash := []byte(HashAPIKey(plaintext))
+	for _, k := range keys {
+		if k.Prefix == prefix && subtle.ConstantTimeCompare([]byte(k.Hash), hash) == 1 {
+			k.Hash = ""
+			return k, nil
+		}
+	}
+
+	return models.APIKey{}, ErrInvalidAPIKey
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+var (
+	ErrInvalidAuditAction = errors.New("invalid action, expected read, list, search, export, create, update or delete")
+	ErrAuditKeyMissing    = errors.New("audit hmac key not configured")
+)
+
+const (
+	DefaultAuditQueryLimit = 100
+	MaxAuditQueryLimit     = 1000
+)
+
+// auditChain guarda o último elo de cada tenant, carregado do arquivo na
+// primeira gravação, para não reler o log a cada acesso.
+type auditChain struct {
+	seq  int64
+	hash string
+}
+
+var (
+	auditMu      sync.Mutex
+	auditEnabled bool
+	auditKey     []byte
+	auditChains  = map[string]auditChain{}
+)
+
+// ConfigureAudit liga ou desliga o log de auditoria de acesso. Fica desligado
+// até que main o ligue, para que testes e ferramentas não gravem no log. key
+// é a chave HMAC da cadeia, que fica só no servidor: quem pode editar o log
+// não consegue recalcular os hashes.
+func ConfigureAudit(enabled bool, key []byte) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditEnabled = enabled
+	auditKey = key
+	auditChains = map[string]auditChain{}
+}
+
+// AuditFilter restringe a consulta ao log. Campos vazios não filtram.
+type AuditFilter struct {
+	Actor     string
+	Action    string
+	ContactID int
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// AuditVerification é o resultado da verificação da cadeia de hashes.
+// BrokenAt traz o seq da primeira entrada adulterada, fora de ordem ou
+// faltando no fim do log.
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Entries  int   `json:"entries"`
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// RecordAccess acrescenta uma entrada ao log de auditoria do tenant,
+// encadeada à anterior. Sem auditoria ligada, não faz nada.
+func RecordAccess(ctx context.Context, tenantID string, entry models.AuditEntry) (models.AuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "services.RecordAccess")
+	defer span.End()
+
+	if !models.IsValidAuditAction(entry.Action) {
+		return models.AuditEntry{}, ErrInvalidAuditAction
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if !auditEnabled {
+		return entry, nil
+	}
+	if len(auditKey) == 0 {
+		return models.AuditEntry{}, ErrAuditKeyMissing
+	}
+
+	chain, ok := auditChains[tenantID]
+	if !ok {
+		entries, err := storage.LoadAuditLog(ctx, tenantID)
+		if err != nil {
+			return models.AuditEntry{}, err
+		}
+		if len(entries) > 0 {
+			last := entries[len(entries)-1]
+			chain = auditChain{seq: last.Seq, hash: last.Hash}
+		}
+	}
+
+	if entry.ContactIDs == nil {
+		entry.ContactIDs = []int{}
+	}
+	entry.Seq = chain.seq + 1
+	entry.Timestamp = time.Now().UTC()
+	entry.PrevHash = chain.hash
+	entry.Hash = auditHash(auditKey, entry)
+
+	if err := storage.AppendAuditEntry(ctx, tenantID, entry); err != nil {
+		return models.AuditEntry{}, err
+	}
+	auditChains[tenantID] = auditChain{seq: entry.Seq, hash: entry.Hash}
+
+	// Se o topo não for gravado, o log fica uma entrada à frente dele, o que
+	// a verificação aceita: a entrada extra tem HMAC válido.
+	if err := storage.SaveAuditHead(ctx, tenantID, storage.AuditHead{Seq: entry.Seq, Hash: entry.Hash}); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// QueryAuditLog devolve as entradas que passam no filtro, das mais recentes
+// para as mais antigas.
+func QueryAuditLog(ctx context.Context, tenantID string, filter AuditFilter) ([]models.AuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "services.QueryAuditLog")
+	defer span.End()
+
+	if filter.Action != "" && !models.IsValidAuditAction(filter.Action) {
+		return nil, ErrInvalidAuditAction
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditQueryLimit
+	}
+	if filter.Limit > MaxAuditQueryLimit {
+		filter.Limit = MaxAuditQueryLimit
+	}
+
+	entries, err := storage.LoadAuditLog(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.AuditEntry{}
+	for i := len(entries) - 1; i >= 0 && len(result) < filter.Limit; i-- {
+		if auditMatches(entries[i], filter) {
+			result = append(result, entries[i])
+		}
+	}
+	return result, nil
+}
+
+// VerifyAuditLog refaz a cadeia de hashes do log do tenant e a confere com
+// o topo gravado fora do log, que denuncia entradas cortadas do fim.
+func VerifyAuditLog(ctx context.Context, tenantID string) (AuditVerification, error) {
+	ctx, span := tracing.Start(ctx, "services.VerifyAuditLog")
+	defer span.End()
+
+	auditMu.Lock()
+	key := auditKey
+	auditMu.Unlock()
+	if len(key) == 0 {
+		return AuditVerification{}, tracing.Fail(span, ErrAuditKeyMissing)
+	}
+
+	entries, err := storage.LoadAuditLog(ctx, tenantID)
+	if err != nil {
+		return AuditVerification{}, err
+	}
+	head, err := storage.LoadAuditHead(ctx, tenantID)
+	if err != nil {
+		return AuditVerification{}, err
+	}
+
+	result := AuditVerification{Valid: true, Entries: len(entries)}
+	prevHash := ""
+	for i, entry := range entries {
+		if entry.Seq != int64(i+1) || entry.PrevHash != prevHash || !hmac.Equal([]byte(entry.Hash), []byte(auditHash(key, entry))) {
+			result.Valid = false
+			result.BrokenAt = entry.Seq
+			return result, nil
+		}
+		prevHash = entry.Hash
+	}
+
+	switch {
+	case head.Seq > int64(len(entries)):
+		result.Valid = false
+		result.BrokenAt = int64(len(entries)) + 1
+	case head.Seq > 0 && entries[head.Seq-1].Hash != head.Hash:
+		result.Valid = false
+		result.BrokenAt = head.Seq
+	}
+	return result, nil
+}
+
+// auditHash é o HMAC-SHA256 da entrada em JSON sem o próprio hash. PrevHash
+// entra no cálculo, o que encadeia as entradas.
+func auditHash(key []byte, entry models.AuditEntry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func auditMatches(entry models.AuditEntry, filter AuditFilter) bool {
+	if filter.Actor != "" && entry.Actor != filter.Actor {
+		return false
+	}
+	if filter.Action != "" && entry.Action != filter.Action {
+		return false
+	}
+	if !filter.From.IsZero() && entry.Timestamp.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && entry.Timestamp.After(filter.To) {
+		return false
+	}
+	if filter.ContactID != 0 {
+		for _, id := range entry.ContactIDs {
+			if id == filter.ContactID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
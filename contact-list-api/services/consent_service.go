@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+var (
+	ErrInvalidChannel       = errors.New("invalid channel, expected email, phone or sms")
+	ErrInvalidPurpose       = errors.New("invalid purpose, expected lowercase letters, digits, '-' or '_'")
+	ErrInvalidConsentStatus = errors.New("invalid status, expected granted or revoked")
+	ErrConsentSourceMissing = errors.New("consent source is required")
+)
+
+var purposePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+var consentsMu sync.Mutex
+
+// ContactConsents traz o estado atual de cada canal e finalidade e o
+// histórico completo de registros do contato.
+type ContactConsents struct {
+	Current []models.ConsentRecord `json:"current"`
+	History []models.ConsentRecord `json:"history"`
+}
+
+type consentKey struct {
+	contactID int
+	channel   string
+	purpose   string
+}
+
+// RecordConsent acrescenta uma concessão ou revogação ao histórico do
+// contato. Exige ser o dono ou ter permissão de edição.
+func RecordConsent(ctx context.Context, tenantID string, userID, contactID int, recordedBy string, record models.ConsentRecord) (models.ConsentRecord, error) {
+	ctx, span := tracing.Start(ctx, "services.RecordConsent")
+	defer span.End()
+
+	record.Channel = strings.ToLower(strings.TrimSpace(record.Channel))
+	record.Purpose = strings.ToLower(strings.TrimSpace(record.Purpose))
+	record.Source = strings.TrimSpace(record.Source)
+
+	if !models.IsValidChannel(record.Channel) {
+		return models.ConsentRecord{}, ErrInvalidChannel
+	}
+	if !purposePattern.MatchString(record.Purpose) {
+		return models.ConsentRecord{}, ErrInvalidPurpose
+	}
+	if record.Status != models.ConsentGranted && record.Status != models.ConsentRevoked {
+		return models.ConsentRecord{}, ErrInvalidConsentStatus
+	}
+	if record.Source == "" {
+		return models.ConsentRecord{}, ErrConsentSourceMissing
+	}
+
+	contact, permission, err := accessibleContact(ctx, tenantID, userID, contactID)
+	if err != nil {
+		return models.ConsentRecord{}, err
+	}
+	if permission == models.PermissionRead {
+		return models.ConsentRecord{}, ErrPermissionDenied
+	}
+
+	consentsMu.Lock()
+	defer consentsMu.Unlock()
+
+	records, err := storage.LoadConsents(ctx, tenantID)
+	if err != nil {
+		return models.ConsentRecord{}, err
+	}
+
+	maxID := 0
+	for _, r := range records {
+		if r.ID > maxID {
+			maxID = r.ID
+		}
+	}
+
+	record.ID = maxID + 1
+	record.ContactID = contactID
+	record.AddressHash = consentAddressHash(contact, record.Channel)
+	record.RecordedBy = recordedBy
+	record.RecordedAt = time.Now().UTC()
+
+	records = append(records, record)
+	if err := storage.SaveConsents(ctx, tenantID, records); err != nil {
+		return models.ConsentRecord{}, err
+	}
+
+	record.AddressHash = ""
+	return record, nil
+}
+
+// GetContactConsents devolve os consentimentos de um contato acessível a
+// userID.
+func GetContactConsents(ctx context.Context, tenantID string, userID, contactID int) (ContactConsents, error) {
+	ctx, span := tracing.Start(ctx, "services.GetContactConsents")
+	defer span.End()
+
+	if _, _, err := accessibleContact(ctx, tenantID, userID, contactID); err != nil {
+		return ContactConsents{}, err
+	}
+
+	records, err := storage.LoadConsents(ctx, tenantID)
+	if err != nil {
+		return ContactConsents{}, err
+	}
+
+	result := ContactConsents{Current: []models.ConsentRecord{}, History: []models.ConsentRecord{}}
+	for _, r := range records {
+		if r.ContactID == contactID {
+			r.AddressHash = ""
+			result.History = append(result.History, r)
+		}
+	}
+	for _, r := range currentConsents(result.History) {
+		result.Current = append(result.Current, r)
+	}
+	sort.Slice(result.Current, func(i, j int) bool {
+		return result.Current[i].ID < result.Current[j].ID
+	})
+
+	return result, nil
+}
+
+// GetContactsWithoutConsent lista os contatos da agenda de ownerID que têm o
+// dado do canal (e-mail para email, telefone para phone e sms) mas não têm
+// consentimento válido para a finalidade. Contatos sem o dado não entram,
+// pois não há como contatá-los pelo canal.
+func GetContactsWithoutConsent(ctx context.Context, tenantID string, ownerID int, channel, purpose string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.GetContactsWithoutConsent")
+	defer span.End()
+
+	if !models.IsValidChannel(channel) {
+		return nil, ErrInvalidChannel
+	}
+	if !purposePattern.MatchString(purpose) {
+		return nil, ErrInvalidPurpose
+	}
+
+	contacts, err := loadOwnedContacts(ctx, tenantID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	records, err := storage.LoadConsents(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	current := currentConsents(records)
+
+	result := []models.Contact{}
+	for _, contact := range contacts {
+		if channelValue(contact, channel) == "" {
+			continue
+		}
+		if !hasConsent(current, contact, channel, purpose) {
+			result = append(result, contact)
+		}
+	}
+	return result, nil
+}
+
+// ExportContacts devolve os contatos da agenda de ownerID para exportação.
+// Com uma finalidade, como marketing, cada e-mail só é exportado com
+// consentimento válido no canal email e cada telefone com consentimento em
+// phone ou sms; contatos que ficam sem nenhum dos dois são omitidos. channel
+// restringe a exportação a um único canal.
+func ExportContacts(ctx context.Context, tenantID string, ownerID int, purpose, channel string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.ExportContacts")
+	defer span.End()
+
+	if channel != "" && !models.IsValidChannel(channel) {
+		return nil, ErrInvalidChannel
+	}
+
+	contacts, err := loadOwnedContacts(ctx, tenantID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if purpose == "" {
+		if contacts == nil {
+			contacts = []models.Contact{}
+		}
+		return contacts, nil
+	}
+	if !purposePattern.MatchString(purpose) {
+		return nil, ErrInvalidPurpose
+	}
+
+	records, err := storage.LoadConsents(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	current := currentConsents(records)
+
+	result := []models.Contact{}
+	for _, contact := range contacts {
+		allowed := func(c string) bool {
+			return (channel == "" || channel == c) && hasConsent(current, contact, c, purpose)
+		}
+
+		exported := contact
+		if !allowed(models.ChannelEmail) {
+			exported.Email = ""
+		}
+		if !allowed(models.ChannelPhone) && !allowed(models.ChannelSMS) {
+			exported.Phone = ""
+		}
+		if exported.Email == "" && exported.Phone == "" {
+			continue
+		}
+		result = append(result, exported)
+	}
+	return result, nil
+}
+
+func currentConsents(records []models.ConsentRecord) map[consentKey]models.ConsentRecord {
+	current := make(map[consentKey]models.ConsentRecord)
+	for _, r := range records {
+		key := consentKey{contactID: r.ContactID, channel: r.Channel, purpose: r.Purpose}
+		if existing, ok := current[key]; !ok || r.ID > existing.ID {
+			current[key] = r
+		}
+	}
+	return current
+}
+
+// hasConsent informa se a concessão mais recente do canal e da finalidade
+// vale para o endereço atual do contato. Trocado o e-mail ou o telefone, a
+// concessão dada pelo endereço anterior deixa de valer até um novo registro.
+func hasConsent(current map[consentKey]models.ConsentRecord, contact models.Contact, channel, purpose string) bool {
+	record, ok := current[consentKey{contactID: contact.ID, channel: channel, purpose: purpose}]
+	return ok && record.Status == models.ConsentGranted && record.AddressHash == consentAddressHash(contact, channel)
+}
+
+// consentAddressHash é o hash do endereço normalizado do contato no canal,
+// vazio quando o contato não tem o dado.
+func consentAddressHash(contact models.Contact, channel string) string {
+	var address string
+	if channel == models.ChannelEmail {
+		address = storage.NormalizeEmail(contact.Email)
+	} else {
+		address = storage.NormalizePhone(contact.Phone)
+	}
+	if address == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(channel + ":" + address))
+	return hex.EncodeToString(sum[:])
+}
+
+func channelValue(contact models.Contact, channel string) string {
+	if channel == models.ChannelEmail {
+		return strings.TrimSpace(contact.Email)
+	}
+	return strings.TrimSpace(contact.Phone)
+}
+
+// accessibleContact devolve o contato e a permissão de userID nele, ou
+// ErrContactNotFound quando ele não existe ou não é acessível.
+func accessibleContact(ctx context.Context, tenantID string, userID, contactID int) (models.Contact, string, error) {
+	contacts, err := storage.LoadContacts(ctx, tenantID)
+	if err != nil {
+		return models.Contact{}, "", err
+	}
+
+	contact, ok := findContact(contacts, contactID)
+	if !ok {
+		return models.Contact{}, "", ErrContactNotFound
+	}
+
+	permission, err := contactPermission(ctx, tenantID, userID, contact)
+	if err != nil {
+		return models.Contact{}, "", err
+	}
+	if permission == "" {
+		return models.Contact{}, "", ErrContactNotFound
+	}
+	return contact, permission, nil
+}
+
+// removeContactConsents apaga os consentimentos de um contato removido, que
+// não autorizariam mais nenhum envio e ficariam esquecidos em consents.json.
+func removeContactConsents(ctx context.Context, tenantID string, contactID int) error {
+	consentsMu.Lock()
+	defer consentsMu.Unlock()
+
+	records, err := storage.LoadConsents(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.ConsentRecord
+	for _, r := range records {
+		if r.ContactID != contactID {
+			remaining = append(remaining, r)
+		}
+	}
+	if len(remaining) == len(records) {
+		return nil
+	}
+
+	return storage.SaveConsents(ctx, tenantID, remaining)
+}
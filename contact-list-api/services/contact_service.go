@@ -1,11 +1,21 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"strings"
 
 	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+var (
+	// ErrContactNotFound é o mesmo erro do storage, que o devolve quando o
+	// contato some entre a leitura e a gravação.
+	ErrContactNotFound  = storage.ErrContactNotFound
+	ErrPermissionDenied = errors.New("permission denied")
 )
 
 type ContactSummary struct {
@@ -16,107 +26,178 @@ type ContactSummary struct {
 	DuplicatedNames []string `json:"duplicated_names,omitempty"`
 }
 
-func GetAllContacts() ([]models.Contact, error) {
-	return storage.LoadContacts()
-}
-
-func AddContact(newContact models.Contact) error {
-	contacts, err := storage.LoadContacts()
+// loadOwnedContacts devolve apenas os contatos da agenda de ownerID no tenant.
+// Todas as funções deste arquivo recebem o tenant e o dono e nunca enxergam
+// outras agendas; os totais de GetContactsSummary e GetEmailProviders também
+// ficam restritos a elas.
+func loadOwnedContacts(ctx context.Context, tenantID string, ownerID int) ([]models.Contact, error) {
+	contacts, err := storage.LoadContacts(ctx, tenantID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	newContact.ID = getNextID(contacts)
-	contacts = append(contacts, newContact)
-	return storage.SaveContacts(contacts)
-}
-
-func getNextID(contacts []models.Contact) int {
-	maxID := 0
-	for _, c := range contacts {
-		if c.ID > maxID {
-			maxID = c.ID
+	var owned []models.Contact
+	for _, contact := range contacts {
+		if contact.OwnerID == ownerID {
+			owned = append(owned, contact)
 		}
 	}
-	return maxID + 1
+	return owned, nil
+}
+
+func GetAllContacts(ctx context.Context, tenantID string, ownerID int) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.GetAllContacts")
+	defer span.End()
+
+	return loadOwnedContacts(ctx, tenantID, ownerID)
 }
 
-func GetContactByID(id int) (models.Contact, error) {
-	contacts, err := storage.LoadContacts()
+func AddContact(ctx context.Context, tenantID string, ownerID int, newContact models.Contact) (models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.AddContact")
+	defer span.End()
+
+	// Os IDs continuam únicos entre todas as agendas, pois identificam o
+	// contato no log de alterações e no CardDAV; quem os atribui é o
+	// storage, com as gravações do tenant travadas.
+	created, err := storage.PutContact(ctx, tenantID, 0, func(_ models.Contact, total int) (models.Contact, error) {
+		if err := checkContactQuota(ctx, tenantID, total); err != nil {
+			return models.Contact{}, err
+		}
+		newContact.OwnerID = ownerID
+		return newContact, nil
+	})
 	if err != nil {
 		return models.Contact{}, err
 	}
 
+	Events.Publish(tenantID, EventContactCreated, created)
+	return created, nil
+}
+
+// PublishContactChanges publica os eventos de uma edição de contacts.json
+// feita fora do servidor, como se cada alteração tivesse vindo da API.
+func PublishContactChanges(tenantID string, changes storage.ContactChanges) {
+	for _, contact := range changes.Created {
+		Events.Publish(tenantID, EventContactCreated, contact)
+	}
+	for _, contact := range changes.Updated {
+		Events.Publish(tenantID, EventContactUpdated, contact)
+	}
+	for _, contact := range changes.Deleted {
+		Events.Publish(tenantID, EventContactDeleted, contact)
+	}
+}
+
+func findContact(contacts []models.Contact, id int) (models.Contact, bool) {
 	for _, contact := range contacts {
 		if contact.ID == id {
-			return contact, nil
+			return contact, true
 		}
 	}
-
-	return models.Contact{}, err
+	return models.Contact{}, false
 }
 
-func UpdateContactById(id int, updatedContact models.Contact) (models.Contact, error) {
-	contacts, err := storage.LoadContacts()
+// GetContactByID devolve o contato se userID for o dono ou tiver recebido
+// acesso por compartilhamento. Para os demais, o contato não existe:
+// devolve ErrContactNotFound, como se ele não existisse.
+func GetContactByID(ctx context.Context, tenantID string, userID, id int) (models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.GetContactByID")
+	defer span.End()
+
+	contact, ok, err := storage.GetContact(ctx, tenantID, id)
 	if err != nil {
 		return models.Contact{}, err
 	}
-
-	var found bool
-	var updatedList []models.Contact
-
-	for _, contact := range contacts {
-		if contact.ID == id {
-			updatedContact.ID = id
-			updatedList = append(updatedList, updatedContact)
-			found = true
-		} else {
-			updatedList = append(updatedList, contact)
-		}
+	if !ok {
+		return models.Contact{}, ErrContactNotFound
 	}
 
-	if !found {
+	permission, err := contactPermission(ctx, tenantID, userID, contact)
+	if err != nil {
 		return models.Contact{}, err
-
 	}
+	if permission == "" {
+		return models.Contact{}, ErrContactNotFound
+	}
+	return contact, nil
+}
+
+// UpdateContactById exige ser o dono ou ter permissão de edição; o contato
+// continua na agenda do dono. Sem acesso nenhum, devolve ErrContactNotFound.
+func UpdateContactById(ctx context.Context, tenantID string, userID, id int, updatedContact models.Contact) (models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.UpdateContactById")
+	defer span.End()
 
-	if err := storage.SaveContacts(updatedList); err != nil {
+	updated, err := storage.PutContact(ctx, tenantID, id, func(existing models.Contact, _ int) (models.Contact, error) {
+		permission, err := contactPermission(ctx, tenantID, userID, existing)
+		if err != nil {
+			return models.Contact{}, err
+		}
+		switch permission {
+		case "":
+			return models.Contact{}, ErrContactNotFound
+		case models.PermissionRead:
+			return models.Contact{}, ErrPermissionDenied
+		}
+		updatedContact.OwnerID = existing.OwnerID
+		return updatedContact, nil
+	})
+	if err != nil {
 		return models.Contact{}, err
 	}
 
-	return updatedContact, nil
+	Events.Publish(tenantID, EventContactUpdated, updated)
+	return updated, nil
 }
 
-func DeleteContactById(id int) error {
-	contacts, err := storage.LoadContacts()
+// DeleteContactById só é permitido ao dono, mesmo para quem tem permissão de
+// edição.
+func DeleteContactById(ctx context.Context, tenantID string, userID, id int) error {
+	ctx, span := tracing.Start(ctx, "services.DeleteContactById")
+	defer span.End()
+
+	deleted, err := storage.DeleteContact(ctx, tenantID, id, func(existing models.Contact) error {
+		permission, err := contactPermission(ctx, tenantID, userID, existing)
+		if err != nil {
+			return err
+		}
+		switch permission {
+		case models.PermissionOwner:
+			return nil
+		case "":
+			return ErrContactNotFound
+		default:
+			return ErrPermissionDenied
+		}
+	})
 	if err != nil {
 		return err
 	}
 
-	var updatedContacts []models.Contact
-	found := false
-
-	for _, contact := range contacts {
-		if contact.ID != id {
-			updatedContacts = append(updatedContacts, contact)
-		} else {
-			found = true
-		}
+	// O contato já foi apagado, então a exclusão não falha mais daqui em
+	// diante. Links, consentimentos e compartilhamentos que sobrarem não dão
+	// acesso a nada sem o contato; a falha fica só no log.
+	if err := removeContactShareLinks(ctx, tenantID, id); err != nil {
+		slog.ErrorContext(ctx, "contact share links not removed", "tenant", tenantID, "contact_id", id, "error", err)
 	}
-
-	if !found {
-		return errors.New("contact not found")
+	if err := removeContactConsents(ctx, tenantID, id); err != nil {
+		slog.ErrorContext(ctx, "contact consents not removed", "tenant", tenantID, "contact_id", id, "error", err)
 	}
-
-	if err := storage.SaveContacts(updatedContacts); err != nil {
-		return err
+	if deleted.OwnerID != models.SharedOwnerID {
+		if err := removeContactShares(ctx, tenantID, id); err != nil {
+			slog.ErrorContext(ctx, "contact shares not removed", "tenant", tenantID, "contact_id", id, "error", err)
+		}
 	}
 
+	Events.Publish(tenantID, EventContactDeleted, deleted)
 	return nil
 }
 
-func GetContactsSummary() (ContactSummary, error) {
-	contacts, err := storage.LoadContacts()
+func GetContactsSummary(ctx context.Context, tenantID string, ownerID int) (ContactSummary, error) {
+	ctx, span := tracing.Start(ctx, "services.GetContactsSummary")
+	defer span.End()
+
+	contacts, err := loadOwnedContacts(ctx, tenantID, ownerID)
 	if err != nil {
 		return ContactSummary{}, err
 	}
@@ -152,8 +233,11 @@ func GetContactsSummary() (ContactSummary, error) {
 	return summary, nil
 }
 
-func SearchContactsByName(name string) ([]models.Contact, error) {
-	contacts, err := storage.LoadContacts()
+func SearchContactsByName(ctx context.Context, tenantID string, ownerID int, name string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.SearchContactsByName")
+	defer span.End()
+
+	contacts, err := loadOwnedContacts(ctx, tenantID, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -168,8 +252,46 @@ func SearchContactsByName(name string) ([]models.Contact, error) {
 	return results, nil
 }
 
-func GetEmailProviders() (map[string]int, error) {
-	contacts, err := storage.LoadContacts()
+// FindContactsByEmail busca pelo e-mail exato, sem diferenciar maiúsculas.
+// Com a cifra de campos ligada, a comparação usa os índices cegos.
+func FindContactsByEmail(ctx context.Context, tenantID string, ownerID int, email string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.FindContactsByEmail")
+	defer span.End()
+
+	contacts, err := storage.FindContactsByEmail(ctx, tenantID, email)
+	if err != nil {
+		return nil, err
+	}
+	return filterOwned(contacts, ownerID), nil
+}
+
+// FindContactsByPhone busca pelo telefone, comparando apenas os dígitos.
+func FindContactsByPhone(ctx context.Context, tenantID string, ownerID int, phone string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.FindContactsByPhone")
+	defer span.End()
+
+	contacts, err := storage.FindContactsByPhone(ctx, tenantID, phone)
+	if err != nil {
+		return nil, err
+	}
+	return filterOwned(contacts, ownerID), nil
+}
+
+func filterOwned(contacts []models.Contact, ownerID int) []models.Contact {
+	owned := []models.Contact{}
+	for _, contact := range contacts {
+		if contact.OwnerID == ownerID {
+			owned = append(owned, contact)
+		}
+	}
+	return owned
+}
+
+func GetEmailProviders(ctx context.Context, tenantID string, ownerID int) (map[string]int, error) {
+	ctx, span := tracing.Start(ctx, "services.GetEmailProviders")
+	defer span.End()
+
+	contacts, err := loadOwnedContacts(ctx, tenantID, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// Operadores de DirectoryFilter, equivalentes aos filtros LDAP (RFC 4511).
+const (
+	FilterAnd       = "and"
+	FilterOr        = "or"
+	FilterNot       = "not"
+	FilterEqual     = "equal"
+	FilterSubstring = "substring"
+	FilterPresent   = "present"
+	FilterApprox    = "approx"
+	FilterGreater   = "greater_or_equal"
+	FilterLess      = "less_or_equal"
+)
+
+// DirectoryFilter é a consulta feita ao diretório de contatos, montada a
+// partir de um filtro LDAP. Os nomes de atributo são os do inetOrgPerson.
+type DirectoryFilter struct {
+	Op        string
+	Attribute string
+	Value     string
+	Initial   string
+	Any       []string
+	Final     string
+	Children  []DirectoryFilter
+}
+
+// DirectoryAttributes mapeia o contato para os atributos de uma entrada
+// inetOrgPerson. As chaves estão em minúsculas.
+func DirectoryAttributes(contact models.Contact) map[string][]string {
+	given, family := splitName(contact.Name)
+	if family == "" {
+		family = contact.Name
+	}
+
+	attributes := map[string][]string{
+		"objectclass": {"top", "person", "organizationalPerson", "inetOrgPerson"},
+		"uid":         {VCardUID(contact.ID)},
+		"cn":          {contact.Name},
+		"displayname": {contact.Name},
+		"sn":          {family},
+	}
+	if given != "" && given != contact.Name {
+		attributes["givenname"] = []string{given}
+	}
+	if email := strings.TrimSpace(contact.Email); email != "" {
+		attributes["mail"] = []string{email}
+	}
+	if phone := strings.TrimSpace(contact.Phone); phone != "" {
+		attributes["telephonenumber"] = []string{phone}
+	}
+
+	return attributes
+}
+
+// SearchDirectory devolve os contatos da agenda de ownerID cujas entradas
+// satisfazem o filtro.
+func SearchDirectory(ctx context.Context, tenantID string, ownerID int, filter DirectoryFilter) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "services.SearchDirectory")
+	defer span.End()
+
+	contacts, err := GetAllContacts(ctx, tenantID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []models.Contact{}
+	for _, contact := range contacts {
+		if filter.Matches(DirectoryAttributes(contact)) {
+			results = append(results, contact)
+		}
+	}
+
+	return results, nil
+}
+
+// Matches avalia o filtro contra os atributos de uma entrada. A comparação
+// ignora maiúsculas e, para telephoneNumber, também espaços e hífens, como as
+// regras caseIgnoreMatch e telephoneNumberMatch do LDAP.
+func (f DirectoryFilter) Matches(attributes map[string][]string) bool {
+	switch f.Op {
+	case FilterAnd:
+		for _, child := range f.Children {
+			if !child.Matches(attributes) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, child := range f.Children {
+			if child.Matches(attributes) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return len(f.Children) == 1 && !f.Children[0].Matches(attributes)
+	}
+
+	attribute := strings.ToLower(f.Attribute)
+	values := attributes[attribute]
+	if f.Op == FilterPresent {
+		return len(values) > 0
+	}
+
+	for _, value := range values {
+		if f.matchValue(normalizeDirectoryValue(attribute, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f DirectoryFilter) matchValue(value string) bool {
+	attribute := strings.ToLower(f.Attribute)
+
+	switch f.Op {
+	case FilterEqual, FilterApprox:
+		return value == normalizeDirectoryValue(attribute, f.Value)
+	case FilterGreater:
+		return value >= normalizeDirectoryValue(attribute, f.Value)
+	case FilterLess:
+		return value <= normalizeDirectoryValue(attribute, f.Value)
+	case FilterSubstring:
+		initial := normalizeDirectoryValue(attribute, f.Initial)
+		if !strings.HasPrefix(value, initial) {
+			return false
+		}
+		value = value[len(initial):]
+
+		for _, part := range f.Any {
+			part = normalizeDirectoryValue(attribute, part)
+			index := strings.Index(value, part)
+			if index < 0 {
+				return false
+			}
+			value = value[index+len(part):]
+		}
+
+		return strings.HasSuffix(value, normalizeDirectoryValue(attribute, f.Final))
+	}
+
+	return false
+}
+
+func normalizeDirectoryValue(attribute, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if attribute == "telephonenumber" {
+		value = strings.NewReplacer(" ", "", "-", "").Replace(value)
+	}
+	return value
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// ReencryptionResult informa quantos contatos de um tenant foram recifrados.
+type ReencryptionResult struct {
+	TenantID    string `json:"tenant_id" example:"acme"`
+	Reencrypted int    `json:"reencrypted" example:"42"`
+}
+
+// ReencryptAllContacts recifra com a chave ativa os contatos do tenant padrão
+// e de todos os tenants cadastrados. É o passo seguinte a incluir uma nova
+// chave no topo do arquivo de chaves; ao terminar, as antigas podem ser
+// removidas.
+func ReencryptAllContacts(ctx context.Context) ([]ReencryptionResult, error) {
+	ctx, span := tracing.Start(ctx, "services.ReencryptAllContacts")
+	defer span.End()
+
+	tenantIDs, err := allTenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []ReencryptionResult{}
+	for _, tenantID := range tenantIDs {
+		count, err := storage.ReencryptContacts(ctx, tenantID)
+		if err != nil {
+			return results, err
+		}
+		slog.InfoContext(ctx, "contacts reencrypted", "tenant", tenantID, "count", count)
+		results = append(results, ReencryptionResult{TenantID: tenantID, Reencrypted: count})
+	}
+	return results, nil
+}
+
+// allTenantIDs devolve o tenant padrão e todos os tenants cadastrados.
+func allTenantIDs(ctx context.Context) ([]string, error) {
+	tenants, err := storage.LoadTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantIDs := []string{models.DefaultTenantID}
+	for _, t := range tenants {
+		tenantIDs = append(tenantIDs, t.ID)
+	}
+	return tenantIDs, nil
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+)
+
+const (
+	EventContactCreated = "created"
+	EventContactUpdated = "updated"
+	EventContactDeleted = "deleted"
+)
+
+const defaultEventLogSize = 1000
+
+type ContactEvent struct {
+	ID        int64          `json:"id"`
+	TenantID  string         `json:"tenant_id,omitempty"`
+	Type      string         `json:"type"`
+	Contact   models.Contact `json:"contact"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// EventBus distribui eventos de alteração de contatos para os assinantes e
+// mantém um log limitado dos últimos eventos para permitir a retomada de
+// streams a partir de um Last-Event-ID.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      int64
+	log         []ContactEvent
+	maxLog      int
+	subscribers map[chan ContactEvent]struct{}
+	durable     map[*durableSubscriber]struct{}
+}
+
+// durableSubscriber recebe todos os eventos: Publish só os acrescenta à fila,
+// sem limite, e uma goroutine os repassa ao canal na ordem.
+type durableSubscriber struct {
+	mu      sync.Mutex
+	pending []ContactEvent
+	ready   chan struct{}
+	done    chan struct{}
+	out     chan ContactEvent
+}
+
+func (s *durableSubscriber) push(event ContactEvent) {
+	s.mu.Lock()
+	s.pending = append(s.pending, event)
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *durableSubscriber) run() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		batch := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		for _, event := range batch {
+			select {
+			case s.out <- event:
+			case <-s.done:
+				return
+			}
+		}
+		select {
+		case <-s.ready:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func NewEventBus(maxLog int) *EventBus {
+	if maxLog <= 0 {
+		maxLog = defaultEventLogSize
+	}
+	return &EventBus{
+		nextID:      1,
+		maxLog:      maxLog,
+		subscribers: make(map[chan ContactEvent]struct{}),
+		durable:     make(map[*durableSubscriber]struct{}),
+	}
+}
+
+var Events = NewEventBus(defaultEventLogSize)
+
+func (b *EventBus) Publish(tenantID, eventType string, contact models.Contact) ContactEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := ContactEvent{
+		ID:        b.nextID,
+		TenantID:  tenantID,
+		Type:      eventType,
+		Contact:   contact,
+		Timestamp: time.Now().UTC(),
+	}
+	b.nextID++
+
+	b.log = append(b.log, event)
+	if len(b.log) > b.maxLog {
+		b.log = b.log[len(b.log)-b.maxLog:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Assinante lento: em vez de travar quem publica ou perder o
+			// evento em silêncio, o canal é fechado. O cliente retoma
+			// reconectando com Last-Event-ID e recebe do log o que perdeu.
+			delete(b.subscribers, ch)
+			close(ch)
+			slog.Warn("slow subscriber closed", "tenant", tenantID, "event_id", event.ID)
+		}
+	}
+	for s := range b.durable {
+		s.push(event)
+	}
+
+	return event
+}
+
+// Since retorna os eventos com ID maior que lastID. O segundo retorno é false
+// quando parte dos eventos solicitados já saiu do log e o cliente precisa
+// recarregar a lista completa.
+func (b *EventBus) Since(lastID int64) ([]ContactEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.since(lastID)
+}
+
+func (b *EventBus) since(lastID int64) ([]ContactEvent, bool) {
+	if lastID <= 0 || lastID >= b.nextID-1 {
+		return nil, lastID < b.nextID
+	}
+
+	complete := len(b.log) > 0 && b.log[0].ID <= lastID+1
+
+	var events []ContactEvent
+	for _, event := range b.log {
+		if event.ID > lastID {
+			events = append(events, event)
+		}
+	}
+	return events, complete
+}
+
+// Subscribe registra um novo assinante. Os eventos posteriores a lastID que
+// ainda estão no log são devolvidos em backlog, sem risco de perda entre a
+// leitura do log e o registro do canal. Se o assinante não acompanhar os
+// eventos e o buffer do canal encher, o canal é fechado; o assinante deve
+// assinar de novo a partir do último ID recebido. A função cancel deve ser
+// chamada quando o assinante não precisar mais dos eventos.
+func (b *EventBus) Subscribe(lastID int64) (backlog []ContactEvent, complete bool, events <-chan ContactEvent, cancel func()) {
+	ch := make(chan ContactEvent, 64)
+
+	b.mu.Lock()
+	backlog, complete = b.since(lastID)
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+
+	return backlog, complete, ch, cancel
+}
+
+// SubscribeDurable registra um assinante que nunca é fechado por atraso:
+// os eventos que ele ainda não leu esperam numa fila sem limite, em vez de
+// ocupar o buffer do canal. Serve a consumidores internos que não podem
+// perder eventos, como o dispatcher de webhooks; cancel encerra o canal.
+func (b *EventBus) SubscribeDurable() (events <-chan ContactEvent, cancel func()) {
+	s := &durableSubscriber{
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+		out:   make(chan ContactEvent),
+	}
+
+	b.mu.Lock()
+	b.durable[s] = struct{}{}
+	b.mu.Unlock()
+	go s.run()
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.durable, s)
+			b.mu.Unlock()
+			close(s.done)
+		})
+	}
+	return s.out, cancel
+}
+
+// Find devolve os eventos do tenant ainda no log cujo contato satisfaz match.
+func (b *EventBus) Find(tenantID string, match func(models.Contact) bool) []ContactEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := []ContactEvent{}
+	for _, event := range b.log {
+		if event.TenantID == tenantID && match(event.Contact) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Redact reduz o contato dos eventos do tenant que satisfazem match ao ID e
+// ao dono. Os eventos continuam no log para não quebrar a retomada de
+// streams. Devolve quantos eventos foram alterados.
+func (b *EventBus) Redact(tenantID string, match func(models.Contact) bool) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for i, event := range b.log {
+		if event.TenantID != tenantID || !match(event.Contact) {
+			continue
+		}
+		redacted := models.Contact{ID: event.Contact.ID, OwnerID: event.Contact.OwnerID}
+		if event.Contact != redacted {
+			b.log[i].Contact = redacted
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// CheckAllContacts confere os arquivos de contatos do tenant padrão e de
+// todos os tenants cadastrados, sem alterar nada.
+func CheckAllContacts(ctx context.Context) ([]storage.IntegrityReport, error) {
+	ctx, span := tracing.Start(ctx, "services.CheckAllContacts")
+	defer span.End()
+
+	tenantIDs, err := allTenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []storage.IntegrityReport{}
+	for _, tenantID := range tenantIDs {
+		report, err := storage.CheckIntegrity(ctx, tenantID)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// RecoverAllContacts recupera, no modo informado, os tenants com arquivos
+// corrompidos e devolve o que foi feito em cada um. Um tenant que não pôde
+// ser recuperado não impede os demais; os erros voltam juntos.
+func RecoverAllContacts(ctx context.Context, mode storage.RecoveryMode) ([]storage.RecoveryResult, error) {
+	ctx, span := tracing.Start(ctx, "services.RecoverAllContacts")
+	defer span.End()
+
+	reports, err := CheckAllContacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []storage.RecoveryResult{}
+	var errs []error
+	for _, report := range reports {
+		if report.Healthy {
+			continue
+		}
+		result, err := storage.RecoverContacts(ctx, report.TenantID, mode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %q: %w", report.TenantID, err))
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, tracing.Fail(span, errors.Join(errs...))
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// ErasureConfirmationTTL é o prazo para confirmar um pedido de exclusão.
+const ErasureConfirmationTTL = 15 * time.Minute
+
+var (
+	ErrSubjectRequired     = errors.New("email or phone is required")
+	ErrInvalidConfirmation = errors.New("confirmation token is invalid, expired or does not match the subject")
+	ErrErasureNotVerified  = errors.New("subject data still found after erasure")
+)
+
+var privacyRequestsMu sync.Mutex
+
+// SubjectData reúne tudo o que a API guarda sobre um titular, identificado
+// por e-mail ou telefone, em todas as agendas do tenant.
+type SubjectData struct {
+	TenantID    string                 `json:"tenant_id,omitempty"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Contacts    []models.Contact       `json:"contacts"`
+	Shares      []models.Share         `json:"shares"`
+	ShareLinks  []models.ShareLink     `json:"share_links"`
+	Consents    []models.ConsentRecord `json:"consents"`
+	History     []storage.ChangeEntry  `json:"history"`
+	Events      []ContactEvent         `json:"events"`
+}
+
+func (d SubjectData) recordCounts() map[string]int {
+	return map[string]int{
+		"contacts":    len(d.Contacts),
+		"shares":      len(d.Shares),
+		"share_links": len(d.ShareLinks),
+		"consents":    len(d.Consents),
+		"history":     len(d.History),
+		"events":      len(d.Events),
+	}
+}
+
+// ErasureRequest é a primeira etapa da exclusão: mostra o que será apagado e
+// traz o token que precisa ser enviado na confirmação.
+type ErasureRequest struct {
+	ConfirmationToken string      `json:"confirmation_token" example:"2f1c..."`
+	ExpiresAt         time.Time   `json:"expires_at"`
+	Data              SubjectData `json:"data"`
+}
+
+// pendingErasure fica só em memória e guarda um hash do titular, e não o
+// e-mail ou telefone, para conferir a confirmação.
+type pendingErasure struct {
+	tenantID    string
+	subjectHash string
+	createdAt   time.Time
+	expiresAt   time.Time
+}
+
+var (
+	pendingErasuresMu sync.Mutex
+	pendingErasures   = make(map[string]pendingErasure)
+)
+
+// subject identifica o titular pelos valores normalizados.
+type subject struct {
+	email string
+	phone string
+}
+
+func newSubject(email, phone string) (subject, error) {
+	s := subject{email: storage.NormalizeEmail(email), phone: storage.NormalizePhone(phone)}
+	if s.email == "" && s.phone == "" {
+		return subject{}, ErrSubjectRequired
+	}
+	return s, nil
+}
+
+func (s subject) matches(contact models.Contact) bool {
+	if s.email != "" && storage.NormalizeEmail(contact.Email) == s.email {
+		return true
+	}
+	return s.phone != "" && storage.NormalizePhone(contact.Phone) == s.phone
+}
+
+func (s subject) hash(tenantID string) string {
+	sum := sha256.Sum256([]byte(tenantID + "\x00" + s.email + "\x00" + s.phone))
+	return hex.EncodeToString(sum[:])
+}
+
+// FindSubjectData procura o titular nos contatos de todas as agendas do
+// tenant e, a partir deles, nos compartilhamentos, links públicos,
+// consentimentos, log de alterações e eventos recentes. Os eventos também são comparados pelo
+// conteúdo, o que encontra contatos já removidos que ainda aparecem neles.
+func FindSubjectData(ctx context.Context, tenantID, email, phone string) (SubjectData, error) {
+	ctx, span := tracing.Start(ctx, "services.FindSubjectData")
+	defer span.End()
+
+	s, err := newSubject(email, phone)
+	if err != nil {
+		return SubjectData{}, err
+	}
+	return findSubjectData(ctx, tenantID, s)
+}
+
+func findSubjectData(ctx context.Context, tenantID string, s subject) (SubjectData, error) {
+	data := SubjectData{
+		TenantID:    tenantID,
+		GeneratedAt: time.Now().UTC(),
+		Contacts:    []models.Contact{},
+		Shares:      []models.Share{},
+		ShareLinks:  []models.ShareLink{},
+		Consents:    []models.ConsentRecord{},
+		History:     []storage.ChangeEntry{},
+	}
+
+	ids := make(map[int]bool)
+	addContacts := func(contacts []models.Contact, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, contact := range contacts {
+			if !ids[contact.ID] {
+				ids[contact.ID] = true
+				data.Contacts = append(data.Contacts, contact)
+			}
+		}
+		return nil
+	}
+	if s.email != "" {
+		if err := addContacts(storage.FindContactsByEmail(ctx, tenantID, s.email)); err != nil {
+			return SubjectData{}, err
+		}
+	}
+	if s.phone != "" {
+		if err := addContacts(storage.FindContactsByPhone(ctx, tenantID, s.phone)); err != nil {
+			return SubjectData{}, err
+		}
+	}
+
+	if len(ids) > 0 {
+		shares, err := storage.LoadShares(ctx, tenantID)
+		if err != nil {
+			return SubjectData{}, err
+		}
+		for _, share := range shares {
+			if ids[share.ContactID] {
+				data.Shares = append(data.Shares, share)
+			}
+		}
+
+		links, err := storage.LoadShareLinks(ctx, tenantID)
+		if err != nil {
+			return SubjectData{}, err
+		}
+		for _, link := range links {
+			if ids[link.ContactID] {
+				link.NonceHash = ""
+				data.ShareLinks = append(data.ShareLinks, link)
+			}
+		}
+
+		consents, err := storage.LoadConsents(ctx, tenantID)
+		if err != nil {
+			return SubjectData{}, err
+		}
+		for _, record := range consents {
+			if ids[record.ContactID] {
+				record.AddressHash = ""
+				data.Consents = append(data.Consents, record)
+			}
+		}
+
+		changeLog, err := storage.LoadChangeLog(ctx, tenantID)
+		if err != nil {
+			return SubjectData{}, err
+		}
+		for _, entry := range changeLog.Entries {
+			if ids[entry.ContactID] {
+				data.History = append(data.History, entry)
+			}
+		}
+	}
+
+	data.Events = Events.Find(tenantID, func(contact models.Contact) bool {
+		return ids[contact.ID] || s.matches(contact)
+	})
+
+	return data, nil
+}
+
+// ExportSubjectData devolve os dados do titular e registra o pedido na
+// auditoria.
+func ExportSubjectData(ctx context.Context, tenantID, requestedBy, email, phone string) (SubjectData, error) {
+	ctx, span := tracing.Start(ctx, "services.ExportSubjectData")
+	defer span.End()
+
+	data, err := FindSubjectData(ctx, tenantID, email, phone)
+	if err != nil {
+		return SubjectData{}, err
+	}
+
+	now := time.Now().UTC()
+	_, err = recordPrivacyRequest(ctx, tenantID, models.PrivacyRequest{
+		Type:        models.PrivacyRequestExport,
+		RequestedBy: requestedBy,
+		Records:     data.recordCounts(),
+		CreatedAt:   now,
+		CompletedAt: now,
+	})
+	if err != nil {
+		return SubjectData{}, err
+	}
+
+	return data, nil
+}
+
+// RequestErasure inicia a exclusão dos dados do titular. Nada é apagado até
+// ConfirmErasure receber o token devolvido aqui junto com o mesmo e-mail e
+// telefone, dentro de ErasureConfirmationTTL.
+func RequestErasure(ctx context.Context, tenantID, email, phone string) (ErasureRequest, error) {
+	ctx, span := tracing.Start(ctx, "services.RequestErasure")
+	defer span.End()
+
+	s, err := newSubject(email, phone)
+	if err != nil {
+		return ErasureRequest{}, err
+	}
+
+	data, err := findSubjectData(ctx, tenantID, s)
+	if err != nil {
+		return ErasureRequest{}, err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return ErasureRequest{}, err
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now().UTC()
+	pending := pendingErasure{
+		tenantID:    tenantID,
+		subjectHash: s.hash(tenantID),
+		createdAt:   now,
+		expiresAt:   now.Add(ErasureConfirmationTTL),
+	}
+
+	pendingErasuresMu.Lock()
+	for key, p := range pendingErasures {
+		if now.After(p.expiresAt) {
+			delete(pendingErasures, key)
+		}
+	}
+	pendingErasures[HashAPIKey(token)] = pending
+	pendingErasuresMu.Unlock()
+
+	return ErasureRequest{ConfirmationToken: token, ExpiresAt: pending.expiresAt, Data: data}, nil
+}
+
+// ConfirmErasure apaga os contatos do titular em todas as agendas do tenant,
+// com seus compartilhamentos, links públicos e consentimentos, e reduz ao ID
+// os eventos recentes e os payloads de webhook guardados para reenvio. O log
+// de alterações já não guarda dados pessoais e mantém as exclusões para que
+// os clientes de sincronização também apaguem suas cópias. A API não mantém
+// lixeira; o journal é esvaziado e os backups e os arquivos em quarentena
+// são regravados sem os contatos do titular.
+//
+// Ao final, a busca é refeita, inclusive no journal, nos backups e na
+// quarentena; se algo do titular ainda for encontrado, devolve
+// ErrErasureNotVerified. A auditoria registra apenas quem pediu e as
+// quantidades apagadas.
+func ConfirmErasure(ctx context.Context, tenantID, requestedBy, token, email, phone string) (models.PrivacyRequest, error) {
+	ctx, span := tracing.Start(ctx, "services.ConfirmErasure")
+	defer span.End()
+
+	s, err := newSubject(email, phone)
+	if err != nil {
+		return models.PrivacyRequest{}, err
+	}
+
+	pendingErasuresMu.Lock()
+	key := HashAPIKey(token)
+	pending, ok := pendingErasures[key]
+	valid := ok && pending.tenantID == tenantID && time.Now().Before(pending.expiresAt) &&
+		subtle.ConstantTimeCompare([]byte(pending.subjectHash), []byte(s.hash(tenantID))) == 1
+	if valid {
+		delete(pendingErasures, key)
+	}
+	pendingErasuresMu.Unlock()
+	if !valid {
+		return models.PrivacyRequest{}, ErrInvalidConfirmation
+	}
+
+	// O token já foi consumido: a exclusão vai até o fim mesmo que o prazo
+	// da requisição vença, em vez de parar no meio sem como ser repetida.
+	ctx = context.WithoutCancel(ctx)
+	records, err := eraseSubject(ctx, tenantID, s)
+	if err != nil {
+		return models.PrivacyRequest{}, err
+	}
+
+	remaining, err := findSubjectData(ctx, tenantID, s)
+	if err != nil {
+		return models.PrivacyRequest{}, err
+	}
+	history, err := storage.CountContactHistory(ctx, tenantID, s.matches)
+	if err != nil {
+		return models.PrivacyRequest{}, err
+	}
+	leftovers := len(remaining.Contacts) + history + scanWebhookPayloads(tenantID, s.matches, false)
+	for _, event := range remaining.Events {
+		if s.matches(event.Contact) {
+			leftovers++
+		}
+	}
+
+	entry, err := recordPrivacyRequest(ctx, tenantID, models.PrivacyRequest{
+		Type:        models.PrivacyRequestErasure,
+		RequestedBy: requestedBy,
+		Records:     records,
+		Verified:    leftovers == 0,
+		CreatedAt:   pending.createdAt,
+		CompletedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return models.PrivacyRequest{}, err
+	}
+	if leftovers > 0 {
+		return entry, ErrErasureNotVerified
+	}
+
+	return entry, nil
+}
+
+func eraseSubject(ctx context.Context, tenantID string, s subject) (map[string]int, error) {
+	data, err := findSubjectData(ctx, tenantID, s)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int]bool, len(data.Contacts))
+	var erased []int
+	for _, contact := range data.Contacts {
+		if !ids[contact.ID] {
+			erased = append(erased, contact.ID)
+		}
+		ids[contact.ID] = true
+	}
+
+	if len(ids) > 0 {
+		for id := range ids {
+			_, err := storage.DeleteContact(ctx, tenantID, id, nil)
+			if err != nil && !errors.Is(err, storage.ErrContactNotFound) {
+				return nil, err
+			}
+		}
+		// O journal, os backups e a quarentena ainda guardam os dados
+		// apagados.
+		if err := storage.PurgeContactHistory(ctx, tenantID, erased); err != nil {
+			return nil, err
+		}
+
+		for id := range ids {
+			if err := removeContactShares(ctx, tenantID, id); err != nil {
+				return nil, err
+			}
+			if err := removeContactShareLinks(ctx, tenantID, id); err != nil {
+				return nil, err
+			}
+			if err := removeContactConsents(ctx, tenantID, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	match := func(contact models.Contact) bool {
+		return ids[contact.ID] || s.matches(contact)
+	}
+	records := map[string]int{
+		"contacts":         len(data.Contacts),
+		"shares":           len(data.Shares),
+		"share_links":      len(data.ShareLinks),
+		"consents":         len(data.Consents),
+		"events":           Events.Redact(tenantID, match),
+		"webhook_payloads": scanWebhookPayloads(tenantID, match, true),
+	}
+
+	// Os eventos de exclusão levam apenas o ID e o dono, para que webhooks e
+	// streams removam o contato sem receber de novo os dados apagados.
+	for _, contact := range data.Contacts {
+		Events.Publish(tenantID, EventContactDeleted, models.Contact{ID: contact.ID, OwnerID: contact.OwnerID})
+	}
+
+	return records, nil
+}
+
+// GetPrivacyRequests lista a auditoria de pedidos de titulares do tenant.
+func GetPrivacyRequests(ctx context.Context, tenantID string) ([]models.PrivacyRequest, error) {
+	ctx, span := tracing.Start(ctx, "services.GetPrivacyRequests")
+	defer span.End()
+
+	requests, err := storage.LoadPrivacyRequests(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []models.PrivacyRequest{}
+	}
+	return requests, nil
+}
+
+func recordPrivacyRequest(ctx context.Context, tenantID string, entry models.PrivacyRequest) (models.PrivacyRequest, error) {
+	privacyRequestsMu.Lock()
+	defer privacyRequestsMu.Unlock()
+
+	requests, err := storage.LoadPrivacyRequests(ctx, tenantID)
+	if err != nil {
+		return models.PrivacyRequest{}, err
+	}
+
+	maxID := 0
+	for _, r := range requests {
+		if r.ID > maxID {
+			maxID = r.ID
+		}
+	}
+	entry.ID = maxID + 1
+
+	requests = append(requests, entry)
+	if err := storage.SavePrivacyRequests(ctx, tenantID, requests); err != nil {
+		return models.PrivacyRequest{}, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const (
+	DefaultShareLinkTTL = 7 * 24 * time.Hour
+	MaxShareLinkTTL     = 90 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidShareLinkTTL = errors.New("invalid expiration, expected up to 90 days")
+	ErrInvalidMaxUses      = errors.New("max_uses must not be negative")
+	ErrShareLinkNotFound   = errors.New("share link not found")
+	ErrInvalidShareLink    = errors.New("invalid or revoked share link")
+	ErrShareLinkExpired    = errors.New("share link expired")
+	ErrShareLinkExhausted  = errors.New("share link has no uses left")
+)
+
+var (
+	shareLinksMu sync.Mutex
+
+	shareLinkSecretMu sync.Mutex
+	shareLinkSecret   []byte
+)
+
+// ConfigureShareLinks define a chave HMAC que assina os links públicos. Sem
+// chave, uma aleatória é gerada na primeira assinatura e os links emitidos
+// deixam de valer quando o servidor reinicia.
+func ConfigureShareLinks(secret []byte) {
+	shareLinkSecretMu.Lock()
+	defer shareLinkSecretMu.Unlock()
+	shareLinkSecret = secret
+}
+
+func currentShareLinkSecret() ([]byte, error) {
+	shareLinkSecretMu.Lock()
+	defer shareLinkSecretMu.Unlock()
+
+	if len(shareLinkSecret) == 0 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		shareLinkSecret = secret
+	}
+	return shareLinkSecret, nil
+}
+
+// CreateShareLink cria um link público para um contato de userID, válido por
+// ttl (DefaultShareLinkTTL quando zero) e, com maxUses positivo, por no
+// máximo essa quantidade de acessos. Devolve o token que compõe a URL; só o
+// hash do nonce do token fica gravado, então a URL não pode ser refeita
+// depois.
+func CreateShareLink(ctx context.Context, tenantID string, userID, contactID int, ttl time.Duration, maxUses int) (string, models.ShareLink, error) {
+	ctx, span := tracing.Start(ctx, "services.CreateShareLink")
+	defer span.End()
+
+	if ttl == 0 {
+		ttl = DefaultShareLinkTTL
+	}
+	if ttl < 0 || ttl > MaxShareLinkTTL {
+		return "", models.ShareLink{}, ErrInvalidShareLinkTTL
+	}
+	if maxUses < 0 {
+		return "", models.ShareLink{}, ErrInvalidMaxUses
+	}
+
+	if err := requireContactOwner(ctx, tenantID, userID, contactID); err != nil {
+		return "", models.ShareLink{}, err
+	}
+
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+
+	links, err := storage.LoadShareLinks(ctx, tenantID)
+	if err != nil {
+		return "", models.ShareLink{}, err
+	}
+
+	// Os IDs vêm de um contador que só cresce: um link revogado sai da
+	// lista, e o ID dele não pode ser emitido de novo.
+	lastID, err := storage.LoadShareLinkCounter(ctx, tenantID)
+	if err != nil {
+		return "", models.ShareLink{}, err
+	}
+	for _, l := range links {
+		lastID = max(lastID, l.ID)
+	}
+
+	nonce, err := newShareLinkNonce()
+	if err != nil {
+		return "", models.ShareLink{}, err
+	}
+
+	// O token carrega a expiração em segundos, então o registro também.
+	now := time.Now().UTC().Truncate(time.Second)
+	link := models.ShareLink{
+		ID:        lastID + 1,
+		NonceHash: HashAPIKey(nonce),
+		ContactID: contactID,
+		OwnerID:   userID,
+		MaxUses:   maxUses,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	token, err := ShareLinkToken(tenantID, link, nonce)
+	if err != nil {
+		return "", models.ShareLink{}, err
+	}
+
+	if err := storage.SaveShareLinkCounter(ctx, tenantID, link.ID); err != nil {
+		return "", models.ShareLink{}, err
+	}
+	links = append(links, link)
+	if err := storage.SaveShareLinks(ctx, tenantID, links); err != nil {
+		return "", models.ShareLink{}, err
+	}
+
+	link.NonceHash = ""
+	return token, link, nil
+}
+
+// GetShareLinks lista os links públicos de um contato de userID.
+func GetShareLinks(ctx context.Context, tenantID string, userID, contactID int) ([]models.ShareLink, error) {
+	ctx, span := tracing.Start(ctx, "services.GetShareLinks")
+	defer span.End()
+
+	if err := requireContactOwner(ctx, tenantID, userID, contactID); err != nil {
+		return nil, err
+	}
+
+	links, err := storage.LoadShareLinks(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.ShareLink{}
+	for _, l := range links {
+		if l.ContactID == contactID {
+			l.NonceHash = ""
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+// RevokeShareLink apaga um link; a URL passa a responder como inválida.
+func RevokeShareLink(ctx context.Context, tenantID string, userID, contactID, linkID int) error {
+	ctx, span := tracing.Start(ctx, "services.RevokeShareLink")
+	defer span.End()
+
+	if err := requireContactOwner(ctx, tenantID, userID, contactID); err != nil {
+		return err
+	}
+
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+
+	links, err := storage.LoadShareLinks(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.ShareLink
+	found := false
+	for _, l := range links {
+		if l.ID == linkID && l.ContactID == contactID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, l)
+	}
+
+	if !found {
+		return ErrShareLinkNotFound
+	}
+
+	return storage.SaveShareLinks(ctx, tenantID, remaining)
+}
+
+// ResolvedShareLink é o contato aberto por um link público, com o tenant e o
+// link de onde veio, que identificam o acesso na auditoria.
+type ResolvedShareLink struct {
+	TenantID string
+	Link     models.ShareLink
+	Contact  models.Contact
+}
+
+// ResolveShareLink valida o token de um link público e devolve o contato,
+// contabilizando o acesso. Não exige autenticação: o tenant vem do próprio
+// token, protegido pela assinatura.
+func ResolveShareLink(ctx context.Context, token string) (ResolvedShareLink, error) {
+	ctx, span := tracing.Start(ctx, "services.ResolveShareLink")
+	defer span.End()
+
+	tenantID, linkID, expiresAt, nonce, err := parseShareLinkToken(token)
+	if err != nil {
+		return ResolvedShareLink{}, err
+	}
+	if !time.Now().Before(expiresAt) {
+		return ResolvedShareLink{}, ErrShareLinkExpired
+	}
+
+	// Evita recriar o diretório de um tenant já removido.
+	if tenantID != models.DefaultTenantID {
+		if _, err := GetTenant(ctx, tenantID); err != nil {
+			if errors.Is(err, ErrTenantNotFound) {
+				return ResolvedShareLink{}, ErrInvalidShareLink
+			}
+			return ResolvedShareLink{}, err
+		}
+	}
+
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+
+	links, err := storage.LoadShareLinks(ctx, tenantID)
+	if err != nil {
+		return ResolvedShareLink{}, err
+	}
+
+	// Sem o nonce, um token assinado para um link revogado voltaria a valer
+	// para qualquer link que recebesse o mesmo ID e a mesma expiração.
+	nonceHash := []byte(HashAPIKey(nonce))
+	index := -1
+	for i, l := range links {
+		if l.ID == linkID && l.ExpiresAt.Equal(expiresAt) && l.NonceHash != "" &&
+			subtle.ConstantTimeCompare([]byte(l.NonceHash), nonceHash) == 1 {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ResolvedShareLink{}, ErrInvalidShareLink
+	}
+
+	link := links[index]
+	if link.MaxUses > 0 && link.Uses >= link.MaxUses {
+		return ResolvedShareLink{}, ErrShareLinkExhausted
+	}
+
+	contacts, err := storage.LoadContacts(ctx, tenantID)
+	if err != nil {
+		return ResolvedShareLink{}, err
+	}
+	contact, ok := findContact(contacts, link.ContactID)
+	if !ok || contact.OwnerID != link.OwnerID {
+		return ResolvedShareLink{}, ErrInvalidShareLink
+	}
+
+	links[index].Uses++
+	if err := storage.SaveShareLinks(ctx, tenantID, links); err != nil {
+		return ResolvedShareLink{}, err
+	}
+
+	link.Uses++
+	link.NonceHash = ""
+	return ResolvedShareLink{TenantID: tenantID, Link: link, Contact: contact}, nil
+}
+
+// ShareLinkToken monta o token do link: "<tenant>.<id>.<expiração
+// unix>.<nonce>" em base64url, seguido de "." e do HMAC-SHA256 desse
+// conteúdo. O token só abre o link cujo NonceHash é o hash de nonce.
+func ShareLinkToken(tenantID string, link models.ShareLink, nonce string) (string, error) {
+	secret, err := currentShareLinkSecret()
+	if err != nil {
+		return "", err
+	}
+
+	payload := tenantID + "." + strconv.Itoa(link.ID) + "." + strconv.FormatInt(link.ExpiresAt.Unix(), 10) + "." + nonce
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signShareLink(secret, encoded), nil
+}
+
+// newShareLinkNonce gera o valor aleatório de um link, em base64url, que não
+// contém o "." usado como separador no token.
+func newShareLinkNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func signShareLink(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func parseShareLinkToken(token string) (string, int, time.Time, string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", 0, time.Time{}, "", ErrInvalidShareLink
+	}
+
+	secret, err := currentShareLinkSecret()
+	if err != nil {
+		return "", 0, time.Time{}, "", err
+	}
+	if !hmac.Equal([]byte(signature), []byte(signShareLink(secret, encoded))) {
+		return "", 0, time.Time{}, "", ErrInvalidShareLink
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", 0, time.Time{}, "", ErrInvalidShareLink
+	}
+	parts := strings.Split(string(payload), ".")
+	if len(parts) != 4 || parts[3] == "" {
+		return "", 0, time.Time{}, "", ErrInvalidShareLink
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, time.Time{}, "", ErrInvalidShareLink
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, time.Time{}, "", ErrInvalidShareLink
+	}
+
+	return parts[0], id, time.Unix(expires, 0).UTC(), parts[3], nil
+}
+
+// requireContactOwner só aceita o dono do contato: quem recebeu o contato por
+// compartilhamento não pode publicá-lo.
+func requireContactOwner(ctx context.Context, tenantID string, userID, contactID int) error {
+	contacts, err := storage.LoadContacts(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	contact, ok := findContact(contacts, contactID)
+	if !ok {
+		return ErrContactNotFound
+	}
+
+	permission, err := contactPermission(ctx, tenantID, userID, contact)
+	if err != nil {
+		return err
+	}
+	switch permission {
+	case models.PermissionOwner:
+		return nil
+	case "":
+		return ErrContactNotFound
+	default:
+		return ErrPermissionDenied
+	}
+}
+
+// removeContactShareLinks apaga os links de um contato removido, que não
+// abririam mais nada e ficariam esquecidos em share_links.json.
+func removeContactShareLinks(ctx context.Context, tenantID string, contactID int) error {
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+
+	links, err := storage.LoadShareLinks(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.ShareLink
+	for _, l := range links {
+		if l.ContactID != contactID {
+			remaining = append(remaining, l)
+		}
+	}
+	if len(remaining) == len(links) {
+		return nil
+	}
+
+	return storage.SaveShareLinks(ctx, tenantID, remaining)
+}
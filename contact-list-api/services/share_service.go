@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+var (
+	ErrInvalidPermission   = errors.New("invalid permission, expected read or edit")
+	ErrSharingRequiresUser = errors.New("only user accounts can share contacts")
+	ErrShareWithSelf       = errors.New("cannot share with yourself")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrShareNotFound       = errors.New("share not found")
+)
+
+var sharesMu sync.Mutex
+
+// SharedContact é um contato de outra agenda acessível ao usuário.
+type SharedContact struct {
+	Contact    models.Contact `json:"contact"`
+	Owner      string         `json:"owner" example:"joao"`
+	Permission string         `json:"permission" example:"read"`
+}
+
+// ShareContact concede a granteeUsername acesso de leitura ou edição a um
+// contato de ownerID ou, com contactID zero, à agenda inteira. Compartilhar de
+// novo com o mesmo usuário apenas troca a permissão.
+func ShareContact(ctx context.Context, tenantID string, ownerID, contactID int, granteeUsername, permission string) (models.Share, error) {
+	ctx, span := tracing.Start(ctx, "services.ShareContact")
+	defer span.End()
+
+	if ownerID == models.SharedOwnerID {
+		return models.Share{}, ErrSharingRequiresUser
+	}
+	if permission != models.PermissionRead && permission != models.PermissionEdit {
+		return models.Share{}, ErrInvalidPermission
+	}
+
+	grantee, err := getUserByUsername(ctx, tenantID, granteeUsername)
+	if err != nil {
+		return models.Share{}, err
+	}
+	if grantee.ID == ownerID {
+		return models.Share{}, ErrShareWithSelf
+	}
+
+	if contactID != 0 {
+		contact, err := GetContactByID(ctx, tenantID, ownerID, contactID)
+		if err != nil {
+			return models.Share{}, err
+		}
+		if contact.OwnerID != ownerID {
+			return models.Share{}, ErrContactNotFound
+		}
+	}
+
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := storage.LoadShares(ctx, tenantID)
+	if err != nil {
+		return models.Share{}, err
+	}
+
+	maxID := 0
+	for i, s := range shares {
+		if s.OwnerID == ownerID && s.GranteeID == grantee.ID && s.ContactID == contactID {
+			shares[i].Permission = permission
+			if err := storage.SaveShares(ctx, tenantID, shares); err != nil {
+				return models.Share{}, err
+			}
+			return shares[i], nil
+		}
+		if s.ID > maxID {
+			maxID = s.ID
+		}
+	}
+
+	share := models.Share{
+		ID:         maxID + 1,
+		OwnerID:    ownerID,
+		GranteeID:  grantee.ID,
+		Grantee:    grantee.Username,
+		ContactID:  contactID,
+		Permission: permission,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	shares = append(shares, share)
+	if err := storage.SaveShares(ctx, tenantID, shares); err != nil {
+		return models.Share{}, err
+	}
+
+	return share, nil
+}
+
+// GetSharesByOwner lista os compartilhamentos feitos por ownerID.
+func GetSharesByOwner(ctx context.Context, tenantID string, ownerID int) ([]models.Share, error) {
+	ctx, span := tracing.Start(ctx, "services.GetSharesByOwner")
+	defer span.End()
+
+	shares, err := storage.LoadShares(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.Share{}
+	for _, s := range shares {
+		if s.OwnerID == ownerID {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// RevokeShare remove um compartilhamento. Só o dono pode revogar.
+func RevokeShare(ctx context.Context, tenantID string, ownerID, shareID int) error {
+	ctx, span := tracing.Start(ctx, "services.RevokeShare")
+	defer span.End()
+
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := storage.LoadShares(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.Share
+	found := false
+	for _, s := range shares {
+		if s.ID == shareID && s.OwnerID == ownerID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, s)
+	}
+
+	if !found {
+		return ErrShareNotFound
+	}
+
+	return storage.SaveShares(ctx, tenantID, remaining)
+}
+
+// GetSharedWithMe devolve os contatos de outras agendas que userID pode ver,
+// com a maior permissão concedida a cada um.
+func GetSharedWithMe(ctx context.Context, tenantID string, userID int) ([]SharedContact, error) {
+	ctx, span := tracing.Start(ctx, "services.GetSharedWithMe")
+	defer span.End()
+
+	result := []SharedContact{}
+	if userID == models.SharedOwnerID {
+		return result, nil
+	}
+
+	shares, err := storage.LoadShares(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[int]string)
+	for _, s := range shares {
+		if s.GranteeID == userID {
+			owners[s.OwnerID] = ""
+		}
+	}
+	if len(owners) == 0 {
+		return result, nil
+	}
+
+	users, err := storage.LoadUsers(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if _, ok := owners[u.ID]; ok {
+			owners[u.ID] = u.Username
+		}
+	}
+
+	contacts, err := storage.LoadContacts(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contact := range contacts {
+		if _, ok := owners[contact.OwnerID]; !ok {
+			continue
+		}
+		if permission := sharePermission(shares, userID, contact); permission != "" {
+			result = append(result, SharedContact{Contact: contact, Owner: owners[contact.OwnerID], Permission: permission})
+		}
+	}
+
+	return result, nil
+}
+
+// contactPermission devolve o acesso de userID ao contato: PermissionOwner,
+// uma permissão compartilhada ou "" quando não há acesso. A agenda
+// compartilhada não participa de compartilhamentos.
+func contactPermission(ctx context.Context, tenantID string, userID int, contact models.Contact) (string, error) {
+	if contact.OwnerID == userID {
+		return models.PermissionOwner, nil
+	}
+	if userID == models.SharedOwnerID || contact.OwnerID == models.SharedOwnerID {
+		return "", nil
+	}
+
+	shares, err := storage.LoadShares(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	return sharePermission(shares, userID, contact), nil
+}
+
+func sharePermission(shares []models.Share, userID int, contact models.Contact) string {
+	permission := ""
+	for _, s := range shares {
+		if s.GranteeID != userID || s.OwnerID != contact.OwnerID {
+			continue
+		}
+		if s.ContactID != 0 && s.ContactID != contact.ID {
+			continue
+		}
+		if s.Permission == models.PermissionEdit {
+			return models.PermissionEdit
+		}
+		permission = models.PermissionRead
+	}
+	return permission
+}
+
+// removeContactShares apaga os compartilhamentos de um contato removido, que
+// não dariam acesso a mais nada e ficariam esquecidos em shares.json.
+func removeContactShares(ctx context.Context, tenantID string, contactID int) error {
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+
+	shares, err := storage.LoadShares(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.Share
+	for _, s := range shares {
+		if s.ContactID != contactID {
+			remaining = append(remaining, s)
+		}
+	}
+	if len(remaining) == len(shares) {
+		return nil
+	}
+
+	return storage.SaveShares(ctx, tenantID, remaining)
+}
+
+func getUserByUsername(ctx context.Context, tenantID, username string) (models.User, error) {
+	users, err := storage.LoadUsers(ctx, tenantID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	username = strings.ToLower(strings.TrimSpace(username))
+	for _, u := range users {
+		if u.Username == username {
+			u.PasswordHash = ""
+			return u, nil
+		}
+	}
+
+	return models.User{}, ErrUserNotFound
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const syncTokenPrefix = "v1."
+
+var (
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	ErrSyncTokenExpired = errors.New("sync token expired, a full sync is required")
+)
+
+type ContactTombstone struct {
+	ID        int       `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type SyncResult struct {
+	Full      bool               `json:"full"`
+	Changed   []models.Contact   `json:"changed"`
+	Deleted   []ContactTombstone `json:"deleted"`
+	NextToken string             `json:"next_token"`
+}
+
+func encodeSyncToken(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatInt(seq, 10)))
+}
+
+func decodeSyncToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), syncTokenPrefix) {
+		return 0, ErrInvalidSyncToken
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(string(raw), syncTokenPrefix), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
+
+// SyncContacts devolve os contatos criados ou alterados e as exclusões desde o
+// token informado na agenda de ownerID, junto com o token para a próxima
+// sincronização. Sem token, devolve a lista completa.
+func SyncContacts(ctx context.Context, tenantID string, ownerID int, token string) (SyncResult, error) {
+	ctx, span := tracing.Start(ctx, "services.SyncContacts")
+	defer span.End()
+
+	var since int64
+	if token != "" {
+		seq, err := decodeSyncToken(token)
+		if err != nil {
+			return SyncResult{}, err
+		}
+		since = seq
+	}
+
+	// O log é lido antes dos contatos: uma alteração gravada entre as duas
+	// leituras volta a aparecer na próxima sincronização em vez de se perder.
+	changeLog, err := storage.LoadChangeLog(ctx, tenantID)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	if since > changeLog.LastSeq {
+		return SyncResult{}, ErrInvalidSyncToken
+	}
+	if since > 0 && since < changeLog.PrunedSeq {
+		return SyncResult{}, ErrSyncTokenExpired
+	}
+
+	contacts, err := loadOwnedContacts(ctx, tenantID, ownerID)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	result := SyncResult{
+		Full:      since == 0,
+		Changed:   []models.Contact{},
+		Deleted:   []ContactTombstone{},
+		NextToken: encodeSyncToken(changeLog.LastSeq),
+	}
+
+	if result.Full {
+		result.Changed = append(result.Changed, contacts...)
+		return result, nil
+	}
+
+	seqByID := make(map[int]int64, len(changeLog.Entries))
+	for _, entry := range changeLog.Entries {
+		if entry.OwnerID != ownerID {
+			continue
+		}
+		if entry.Deleted {
+			if entry.Seq > since {
+				result.Deleted = append(result.Deleted, ContactTombstone{ID: entry.ContactID, DeletedAt: entry.ChangedAt})
+			}
+			continue
+		}
+		seqByID[entry.ContactID] = entry.Seq
+	}
+
+	for _, contact := range contacts {
+		if seqByID[contact.ID] > since {
+			result.Changed = append(result.Changed, contact)
+		}
+	}
+
+	return result, nil
+}
+
+// CurrentSyncToken devolve o token que representa o estado atual da lista,
+// sem carregar os contatos.
+func CurrentSyncToken(ctx context.Context, tenantID string) (string, error) {
+	ctx, span := tracing.Start(ctx, "services.CurrentSyncToken")
+	defer span.End()
+
+	changeLog, err := storage.LoadChangeLog(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	return encodeSyncToken(changeLog.LastSeq), nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+var (
+	ErrInvalidTenantID      = errors.New("tenant id must have 2 to 63 characters: lowercase letters, digits or '-'")
+	ErrInvalidQuota         = errors.New("max_contacts must not be negative")
+	ErrTenantExists         = errors.New("tenant already exists")
+	ErrTenantNotFound       = errors.New("tenant not found")
+	ErrContactQuotaExceeded = errors.New("tenant contact quota exceeded")
+)
+
+// O ID vira nome de diretório e rótulo de subdomínio, por isso segue as regras
+// de um rótulo DNS.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+var tenantsMu sync.Mutex
+
+// IsValidTenantID informa se o ID pode identificar um tenant.
+func IsValidTenantID(id string) bool {
+	return len(id) >= 2 && tenantIDPattern.MatchString(id)
+}
+
+func CreateTenant(ctx context.Context, tenant models.Tenant) (models.Tenant, error) {
+	ctx, span := tracing.Start(ctx, "services.CreateTenant")
+	defer span.End()
+
+	if !IsValidTenantID(tenant.ID) {
+		return models.Tenant{}, ErrInvalidTenantID
+	}
+	if tenant.MaxContacts < 0 {
+		return models.Tenant{}, ErrInvalidQuota
+	}
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+
+	tenants, err := storage.LoadTenants(ctx)
+	if err != nil {
+		return models.Tenant{}, err
+	}
+
+	for _, t := range tenants {
+		if t.ID == tenant.ID {
+			return models.Tenant{}, ErrTenantExists
+		}
+	}
+
+	tenant.CreatedAt = time.Now().UTC()
+	tenants = append(tenants, tenant)
+	if err := storage.SaveTenants(ctx, tenants); err != nil {
+		return models.Tenant{}, err
+	}
+
+	return tenant, nil
+}
+
+func GetAllTenants(ctx context.Context) ([]models.Tenant, error) {
+	ctx, span := tracing.Start(ctx, "services.GetAllTenants")
+	defer span.End()
+
+	return storage.LoadTenants(ctx)
+}
+
+func GetTenant(ctx context.Context, id string) (models.Tenant, error) {
+	ctx, span := tracing.Start(ctx, "services.GetTenant")
+	defer span.End()
+
+	tenants, err := storage.LoadTenants(ctx)
+	if err != nil {
+		return models.Tenant{}, err
+	}
+
+	for _, t := range tenants {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+
+	return models.Tenant{}, ErrTenantNotFound
+}
+
+// UpdateTenant altera o nome e a cota. Reduzir a cota abaixo do total atual
+// não remove contatos; apenas impede novos cadastros.
+func UpdateTenant(ctx context.Context, id string, updated models.Tenant) (models.Tenant, error) {
+	ctx, span := tracing.Start(ctx, "services.UpdateTenant")
+	defer span.End()
+
+	if updated.MaxContacts < 0 {
+		return models.Tenant{}, ErrInvalidQuota
+	}
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+
+	tenants, err := storage.LoadTenants(ctx)
+	if err != nil {
+		return models.Tenant{}, err
+	}
+
+	for i, t := range tenants {
+		if t.ID != id {
+			continue
+		}
+		t.Name = updated.Name
+		t.MaxContacts = updated.MaxContacts
+		tenants[i] = t
+		if err := storage.SaveTenants(ctx, tenants); err != nil {
+			return models.Tenant{}, err
+		}
+		return t, nil
+	}
+
+	return models.Tenant{}, ErrTenantNotFound
+}
+
+// DeleteTenant remove o tenant do cadastro e apaga todos os seus dados:
+// contatos, usuários, chaves de API e webhooks.
+func DeleteTenant(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "services.DeleteTenant")
+	defer span.End()
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+
+	tenants, err := storage.LoadTenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.Tenant
+	found := false
+	for _, t := range tenants {
+		if t.ID == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, t)
+	}
+
+	if !found {
+		return ErrTenantNotFound
+	}
+
+	if err := storage.SaveTenants(ctx, remaining); err != nil {
+		return err
+	}
+	return storage.DeleteTenantData(ctx, id)
+}
+
+// CountTenantContacts soma os contatos de todas as agendas do tenant.
+func CountTenantContacts(ctx context.Context, tenantID string) (int, error) {
+	ctx, span := tracing.Start(ctx, "services.CountTenantContacts")
+	defer span.End()
+
+	contacts, err := storage.LoadContacts(ctx, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	return len(contacts), nil
+}
+
+// checkContactQuota recusa um novo contato quando o tenant já atingiu a cota.
+// O tenant padrão não tem cadastro e, portanto, não tem cota.
+func checkContactQuota(ctx context.Context, tenantID string, current int) error {
+	if tenantID == models.DefaultTenantID {
+		return nil
+	}
+
+	tenant, err := GetTenant(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant.MaxContacts > 0 && current >= tenant.MaxContacts {
+		return ErrContactQuotaExceeded
+	}
+	return nil
+}
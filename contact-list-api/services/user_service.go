@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const sessionTokenPrefix = "cls_"
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignora o que passa de 72 bytes; senhas maiores são recusadas
+	// para que o sufixo não seja descartado em silêncio.
+	maxPasswordLength = 72
+)
+
+// SessionTTL é a validade dos tokens emitidos no login.
+var SessionTTL = 24 * time.Hour
+
+var (
+	ErrInvalidUsername    = errors.New("username must have 3 to 32 characters: lowercase letters, digits, '.', '_' or '-'")
+	ErrWeakPassword       = errors.New("password must have between 8 and 72 bytes")
+	ErrUsernameTaken      = errors.New("username already taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidSession     = errors.New("invalid or expired session")
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,32}$`)
+
+// accountsMu serializa cadastro e sessões, que leem e regravam o arquivo
+// inteiro; sem ele dois cadastros simultâneos poderiam repetir o username.
+var accountsMu sync.Mutex
+
+// dummyPasswordHash é comparado quando o usuário não existe, para que o tempo
+// de resposta do login não revele quais usernames estão cadastrados.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("contact-list-api"), bcrypt.DefaultCost)
+
+// RegisterUser cria uma conta com a senha guardada como hash bcrypt. Cada
+// conta tem sua própria agenda, identificada pelo ID do usuário.
+func RegisterUser(ctx context.Context, tenantID, username, password string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "services.RegisterUser")
+	defer span.End()
+
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		return models.User{}, ErrInvalidUsername
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return models.User{}, ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	users, err := storage.LoadUsers(ctx, tenantID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	maxID := 0
+	for _, u := range users {
+		if u.Username == username {
+			return models.User{}, ErrUsernameTaken
+		}
+		if u.ID > maxID {
+			maxID = u.ID
+		}
+	}
+
+	user := models.User{
+		ID:           maxID + 1,
+		Username:     username,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	users = append(users, user)
+	if err := storage.SaveUsers(ctx, tenantID, users); err != nil {
+		return models.User{}, err
+	}
+
+	user.PasswordHash = ""
+	return user, nil
+}
+
+// AuthenticateUser confere usuário e senha.
+func AuthenticateUser(ctx context.Context, tenantID, username, password string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "services.AuthenticateUser")
+	defer span.End()
+
+	users, err := storage.LoadUsers(ctx, tenantID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	username = strings.ToLower(strings.TrimSpace(username))
+	for _, u := range users {
+		if u.Username != username {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+			return models.User{}, ErrInvalidCredentials
+		}
+		u.PasswordHash = ""
+		return u, nil
+	}
+
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+	return models.User{}, ErrInvalidCredentials
+}
+
+// Login autentica o usuário e emite um token de sessão. O token em texto puro
+// só é devolvido aqui; o arquivo guarda apenas o hash.
+func Login(ctx context.Context, tenantID, username, password string) (string, models.Session, error) {
+	ctx, span := tracing.Start(ctx, "services.Login")
+	defer span.End()
+
+	user, err := AuthenticateUser(ctx, tenantID, username, password)
+	if err != nil {
+		return "", models.Session{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", models.Session{}, err
+	}
+	token := sessionTokenPrefix + hex.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	session := models.Session{
+		UserID:    user.ID,
+		Hash:      HashAPIKey(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL),
+	}
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	sessions, err := storage.LoadSessions(ctx, tenantID)
+	if err != nil {
+		return "", models.Session{}, err
+	}
+
+	sessions = append(activeSessions(sessions, now), session)
+	if err := storage.SaveSessions(ctx, tenantID, sessions); err != nil {
+		return "", models.Session{}, err
+	}
+
+	session.Hash = ""
+	return token, session, nil
+}
+
+// IsSessionToken informa se a credencial tem o formato de um token de sessão.
+func IsSessionToken(token string) bool {
+	return strings.HasPrefix(token, sessionTokenPrefix)
+}
+
+// VerifySession devolve o usuário dono de um token de sessão válido.
+func VerifySession(ctx context.Context, tenantID, token string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "services.VerifySession")
+	defer span.End()
+
+	if !IsSessionToken(token) {
+		return models.User{}, ErrInvalidSession
+	}
+
+	sessions, err := storage.LoadSessions(ctx, tenantID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	now := time.Now()
+	hash := []byte(HashAPIKey(token))
+	for _, s := range sessions {
+		if subtle.ConstantTimeCompare([]byte(s.Hash), hash) == 1 && now.Before(s.ExpiresAt) {
+			return getUserByID(ctx, tenantID, s.UserID)
+		}
+	}
+
+	return models.User{}, ErrInvalidSession
+}
+
+// Logout invalida o token de sessão.
+func Logout(ctx context.Context, tenantID, token string) error {
+	ctx, span := tracing.Start(ctx, "services.Logout")
+	defer span.End()
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	sessions, err := storage.LoadSessions(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	hash := HashAPIKey(token)
+	var remaining []models.Session
+	found := false
+	for _, s := range sessions {
+		if s.Hash == hash {
+			found = true
+			continue
+		}
+		remaining = append(remaining, s)
+	}
+
+	if !found {
+		return ErrInvalidSession
+	}
+
+	return storage.SaveSessions(ctx, tenantID, activeSessions(remaining, time.Now()))
+}
+
+func getUserByID(ctx context.Context, tenantID string, id int) (models.User, error) {
+	users, err := storage.LoadUsers(ctx, tenantID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	for _, u := range users {
+		if u.ID == id {
+			u.PasswordHash = ""
+			return u, nil
+		}
+	}
+
+	return models.User{}, ErrInvalidSession
+}
+
+// activeSessions descarta as sessões expiradas, para que o arquivo não cresça
+// indefinidamente.
+func activeSessions(sessions []models.Session, now time.Time) []models.Session {
+	var active []models.Session
+	for _, s := range sessions {
+		if now.Before(s.ExpiresAt) {
+			active = append(active, s)
+		}
+	}
+	return active
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+)
+
+const vCardLineLimit = 75
+
+// VCardUID retorna o UID estável usado para o contato nos vCards.
+func VCardUID(id int) string {
+	return fmt.Sprintf("contact-%d", id)
+}
+
+// ContactToVCard serializa o contato como vCard 3.0 (RFC 2426), com linhas
+// terminadas em CRLF e dobradas em 75 octetos.
+func ContactToVCard(contact models.Contact) string {
+	var b strings.Builder
+
+	writeVCardLine(&b, "BEGIN:VCARD")
+	writeVCardLine(&b, "VERSION:3.0")
+	writeVCardLine(&b, "UID:"+VCardUID(contact.ID))
+	writeVCardLine(&b, "FN:"+escapeVCardValue(contact.Name))
+
+	given, family := splitName(contact.Name)
+	writeVCardLine(&b, "N:"+escapeVCardValue(family)+";"+escapeVCardValue(given)+";;;")
+
+	if strings.TrimSpace(contact.Email) != "" {
+		writeVCardLine(&b, "EMAIL;TYPE=INTERNET:"+escapeVCardValue(contact.Email))
+	}
+	if strings.TrimSpace(contact.Phone) != "" {
+		writeVCardLine(&b, "TEL;TYPE=CELL:"+escapeVCardValue(contact.Phone))
+	}
+
+	writeVCardLine(&b, "END:VCARD")
+	return b.String()
+}
+
+func splitName(name string) (given, family string) {
+	parts := strings.Fields(name)
+	if len(parts) <= 1 {
+		return name, ""
+	}
+	return strings.Join(parts[:len(parts)-1], " "), parts[len(parts)-1]
+}
+
+func escapeVCardValue(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		",", `\,`,
+		";", `\;`,
+	)
+	return replacer.Replace(value)
+}
+
+// writeVCardLine dobra a linha sem quebrar caracteres UTF-8 no meio: as
+// continuações começam com um espaço, que conta no limite de octetos.
+func writeVCardLine(b *strings.Builder, line string) {
+	limit := vCardLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = vCardLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,657 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+const maxDeliveryLog = 500
+
+var (
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrInvalidWebhookURL   = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidWebhookEvent = errors.New("invalid webhook event type")
+	ErrDeliveryNotFound    = errors.New("delivery not found")
+	ErrWebhookTarget       = errors.New("webhook target address is not allowed")
+)
+
+// WebhookClient é exposto para que os testes possam trocar o transporte.
+var WebhookClient = &http.Client{Timeout: 10 * time.Second, Transport: newWebhookTransport()}
+
+// WebhookConfig define as entregas de webhooks. AllowPrivateTargets permite
+// entregar em endereços internos (loopback, redes privadas e link-local),
+// bloqueados por padrão para que um webhook não sirva de ponte até serviços
+// da rede do servidor. Cada entrega é tentada até MaxAttempts vezes, com
+// esperas que começam em RetryBaseDelay e dobram a cada falha.
+type WebhookConfig struct {
+	AllowPrivateTargets bool
+	MaxAttempts         int
+	RetryBaseDelay      time.Duration
+}
+
+// DefaultWebhookConfig é usada até main configurar as entregas.
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{MaxAttempts: 5, RetryBaseDelay: time.Second}
+}
+
+var (
+	// webhooksMu serializa as alterações do cadastro de webhooks, que lêem e
+	// regravam o arquivo inteiro.
+	webhooksMu sync.Mutex
+
+	webhookConfigMu sync.RWMutex
+	webhookConfig   = DefaultWebhookConfig()
+
+	// deliveriesInFlight conta as entregas com tentativas em andamento.
+	deliveriesInFlight sync.WaitGroup
+)
+
+// ConfigureWebhooks troca a configuração das entregas. Campos zerados de
+// tentativas e espera ficam com os valores de DefaultWebhookConfig. As
+// entregas em andamento seguem com a configuração com que começaram.
+func ConfigureWebhooks(c WebhookConfig) {
+	defaults := DefaultWebhookConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.RetryBaseDelay <= 0 {
+		c.RetryBaseDelay = defaults.RetryBaseDelay
+	}
+
+	webhookConfigMu.Lock()
+	defer webhookConfigMu.Unlock()
+	webhookConfig = c
+}
+
+func currentWebhookConfig() WebhookConfig {
+	webhookConfigMu.RLock()
+	defer webhookConfigMu.RUnlock()
+	return webhookConfig
+}
+
+// WaitWebhookDeliveries espera terminarem as entregas em andamento, entregues
+// ou movidas para as mensagens mortas.
+func WaitWebhookDeliveries() {
+	deliveriesInFlight.Wait()
+}
+
+// sharedAddressSpace (100.64.0.0/10, RFC 6598) não entra em IsPrivate, mas
+// é interno e abriga o endereço de metadados de algumas nuvens.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newWebhookTransport confere o endereço de cada conexão já resolvido, na
+// hora de conectar: validar só a URL no cadastro deixaria passar um nome que
+// resolve para um IP interno, ou que passa a resolver depois, e os
+// redirecionamentos. Proxies do ambiente não são usados, porque a conexão
+// ao proxy esconderia o destino.
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second, Control: checkWebhookTarget}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+func checkWebhookTarget(network, address string, _ syscall.RawConn) error {
+	if currentWebhookConfig().AllowPrivateTargets {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrWebhookTarget, address)
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", ErrWebhookTarget, addr)
+	}
+	return nil
+}
+
+type webhookDeliveries struct {
+	mu          sync.Mutex
+	nextID      int
+	log         []*models.WebhookDelivery
+	deadLetters []*models.WebhookDelivery
+	// events guarda o payload das entregas ainda não concluídas com sucesso,
+	// necessário para reenviar mensagens mortas.
+	events map[int]ContactEvent
+	// persist liga a gravação da fila, feita a cada mudança depois que
+	// StartWebhookDispatcher a carrega. Antes disso, como nos testes, a fila
+	// fica só na memória e não sobrescreve a gravada.
+	persist bool
+}
+
+var deliveries = &webhookDeliveries{nextID: 1, events: make(map[int]ContactEvent)}
+
+// restore carrega a fila gravada, liga a gravação e devolve as entregas
+// que estavam pendentes.
+func (d *webhookDeliveries) restore(ctx context.Context) ([]*models.WebhookDelivery, error) {
+	queue, err := storage.LoadWebhookQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	byID := map[int]*models.WebhookDelivery{}
+	var pending []*models.WebhookDelivery
+	d.log = nil
+	for _, delivery := range queue.Deliveries {
+		byID[delivery.ID] = &delivery
+		d.log = append(d.log, &delivery)
+		if delivery.Status == DeliveryPending {
+			pending = append(pending, &delivery)
+		}
+	}
+	d.deadLetters = nil
+	for _, delivery := range queue.DeadLetters {
+		if linked, ok := byID[delivery.ID]; ok {
+			d.deadLetters = append(d.deadLetters, linked)
+			continue
+		}
+		d.deadLetters = append(d.deadLetters, &delivery)
+	}
+	d.events = make(map[int]ContactEvent, len(queue.Payloads))
+	for id, payload := range queue.Payloads {
+		d.events[id] = ContactEvent{
+			ID:        payload.EventID,
+			TenantID:  payload.TenantID,
+			Type:      payload.Type,
+			Contact:   payload.Contact,
+			Timestamp: payload.Timestamp,
+		}
+	}
+	d.nextID = max(queue.NextID, d.nextID)
+	d.persist = true
+	return pending, nil
+}
+
+// saveLocked grava a fila. Quem chama segura d.mu; uma falha é registrada
+// no log e a entrega segue, já que a fila em memória continua válida.
+func (d *webhookDeliveries) saveLocked() {
+	if !d.persist {
+		return
+	}
+
+	queue := storage.WebhookQueue{NextID: d.nextID, Payloads: make(map[int]storage.WebhookPayload, len(d.events))}
+	for _, delivery := range d.log {
+		queue.Deliveries = append(queue.Deliveries, *delivery)
+	}
+	for _, delivery := range d.deadLetters {
+		queue.DeadLetters = append(queue.DeadLetters, *delivery)
+	}
+	for id, event := range d.events {
+		queue.Payloads[id] = storage.WebhookPayload{
+			EventID:   event.ID,
+			TenantID:  event.TenantID,
+			Type:      event.Type,
+			Contact:   event.Contact,
+			Timestamp: event.Timestamp,
+		}
+	}
+	if err := storage.SaveWebhookQueue(context.Background(), queue); err != nil {
+		slog.Error("webhook queue not saved", "error", err)
+	}
+}
+
+func CreateWebhook(ctx context.Context, tenantID string, webhook models.Webhook) (models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "services.CreateWebhook")
+	defer span.End()
+
+	parsed, err := url.ParseRequestURI(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return models.Webhook{}, ErrInvalidWebhookURL
+	}
+
+	for _, eventType := range webhook.Events {
+		if eventType != EventContactCreated && eventType != EventContactUpdated && eventType != EventContactDeleted {
+			return models.Webhook{}, fmt.Errorf("%w: %s", ErrInvalidWebhookEvent, eventType)
+		}
+	}
+
+	if webhook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return models.Webhook{}, err
+		}
+		webhook.Secret = secret
+	}
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	webhooks, err := storage.LoadWebhooks(ctx, tenantID)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	maxID := 0
+	for _, w := range webhooks {
+		if w.ID > maxID {
+			maxID = w.ID
+		}
+	}
+	webhook.ID = maxID + 1
+	webhook.CreatedAt = time.Now().UTC()
+
+	webhooks = append(webhooks, webhook)
+	if err := storage.SaveWebhooks(ctx, tenantID, webhooks); err != nil {
+		return models.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func GetAllWebhooks(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "services.GetAllWebhooks")
+	defer span.End()
+
+	return storage.LoadWebhooks(ctx, tenantID)
+}
+
+func DeleteWebhook(ctx context.Context, tenantID string, id int) error {
+	ctx, span := tracing.Start(ctx, "services.DeleteWebhook")
+	defer span.End()
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	webhooks, err := storage.LoadWebhooks(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	var remaining []models.Webhook
+	found := false
+	for _, w := range webhooks {
+		if w.ID == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+
+	if !found {
+		return ErrWebhookNotFound
+	}
+
+	return storage.SaveWebhooks(ctx, tenantID, remaining)
+}
+
+// SignWebhookPayload calcula a assinatura enviada no cabeçalho
+// X-Webhook-Signature: HMAC-SHA256 de "<timestamp>.<corpo>" com o segredo do
+// webhook. Incluir o timestamp permite ao receptor rejeitar reenvios antigos.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookWantsEvent(webhook models.Webhook, eventType string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, e := range webhook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// StartWebhookDispatcher carrega a fila de entregas gravada, retoma as que
+// estavam pendentes e passa a entregar cada evento do barramento aos
+// webhooks cadastrados, em segundo plano. A assinatura é durável: um
+// dispatcher atrasado acumula os eventos em vez de perdê-los. stop encerra a
+// assinatura e a gravação da fila; as entregas ainda pendentes continuam
+// gravadas e são retomadas na próxima partida.
+func StartWebhookDispatcher() (stop func(), err error) {
+	ctx := context.Background()
+	pending, err := deliveries.restore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, delivery := range pending {
+		resumeDelivery(ctx, delivery)
+	}
+
+	events, cancel := Events.SubscribeDurable()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			if err := DispatchEvent(context.Background(), event); err != nil {
+				slog.Error("webhook dispatch failed", "tenant", event.TenantID, "event_id", event.ID, "error", err)
+			}
+		}
+	}()
+
+	stop = func() {
+		cancel()
+		<-done
+		deliveries.mu.Lock()
+		defer deliveries.mu.Unlock()
+		deliveries.persist = false
+	}
+	return stop, nil
+}
+
+// resumeDelivery reinicia as tentativas de uma entrega pendente gravada. Se
+// o webhook foi removido, ou não pode ser lido, a entrega vai para as
+// mensagens mortas.
+func resumeDelivery(ctx context.Context, delivery *models.WebhookDelivery) {
+	webhooks, err := storage.LoadWebhooks(ctx, delivery.TenantID)
+	if err == nil {
+		err = ErrWebhookNotFound
+		for _, webhook := range webhooks {
+			if webhook.ID == delivery.WebhookID {
+				deliveries.mu.Lock()
+				event := deliveries.events[delivery.ID]
+				deliveries.mu.Unlock()
+				startDelivery(webhook, event, delivery)
+				return
+			}
+		}
+	}
+
+	deliveries.mu.Lock()
+	defer deliveries.mu.Unlock()
+	delivery.Status = DeliveryDead
+	delivery.LastError = err.Error()
+	delivery.UpdatedAt = time.Now().UTC()
+	deliveries.deadLetters = append(deliveries.deadLetters, delivery)
+	deliveries.saveLocked()
+}
+
+// DispatchEvent cria uma entrega para cada webhook do tenant do evento que
+// tenha interesse nele e dispara as tentativas de envio sem bloquear quem
+// chamou.
+func DispatchEvent(ctx context.Context, event ContactEvent) error {
+	ctx, span := tracing.Start(ctx, "services.DispatchEvent")
+	defer span.End()
+
+	webhooks, err := storage.LoadWebhooks(ctx, event.TenantID)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhookWantsEvent(webhook, event.Type) {
+			continue
+		}
+		delivery := deliveries.create(webhook.ID, event)
+		startDelivery(webhook, event, delivery)
+	}
+
+	return nil
+}
+
+// startDelivery inicia as tentativas de envio numa goroutine, com a
+// configuração do momento.
+func startDelivery(webhook models.Webhook, event ContactEvent, delivery *models.WebhookDelivery) {
+	config := currentWebhookConfig()
+	deliveriesInFlight.Add(1)
+	go func() {
+		defer deliveriesInFlight.Done()
+		deliverWithRetry(config, webhook, event, delivery)
+	}()
+}
+
+func deliverWithRetry(config WebhookConfig, webhook models.Webhook, event ContactEvent, delivery *models.WebhookDelivery) {
+	delay := config.RetryBaseDelay
+	for {
+		statusCode, err := sendWebhook(webhook, event, delivery.ID)
+		if attempts, done := deliveries.recordAttempt(delivery, statusCode, err, config.MaxAttempts); done {
+			if err != nil {
+				slog.Warn("webhook delivery moved to dead letters", "tenant", event.TenantID, "webhook_id", webhook.ID,
+					"delivery_id", delivery.ID, "attempts", attempts, "error", err)
+			}
+			return
+		}
+		slog.Debug("webhook delivery failed, retrying", "tenant", event.TenantID, "webhook_id", webhook.ID,
+			"delivery_id", delivery.ID, "status", statusCode, "retry_in", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func sendWebhook(webhook models.Webhook, event ContactEvent, deliveryID int) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "contact-list-api-webhooks")
+	req.Header.Set("X-Webhook-ID", strconv.Itoa(webhook.ID))
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(deliveryID))
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := WebhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *webhookDeliveries) create(webhookID int, event ContactEvent) *models.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	delivery := &models.WebhookDelivery{
+		ID:        d.nextID,
+		TenantID:  event.TenantID,
+		WebhookID: webhookID,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Status:    DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	d.nextID++
+	d.events[delivery.ID] = event
+
+	d.log = append(d.log, delivery)
+	if len(d.log) > maxDeliveryLog {
+		// As entregas pendentes ficam no log até terminar, para que sejam
+		// retomadas se o servidor reiniciar.
+		if i := slices.IndexFunc(d.log, func(old *models.WebhookDelivery) bool { return old.Status != DeliveryPending }); i >= 0 {
+			d.log = slices.Delete(d.log, i, i+1)
+		}
+	}
+	d.saveLocked()
+
+	return delivery
+}
+
+// recordAttempt registra o resultado de uma tentativa e devolve quantas
+// tentativas a entrega já teve e se ela terminou, seja com sucesso ou movida
+// para a fila de mensagens mortas depois de maxAttempts tentativas.
+func (d *webhookDeliveries) recordAttempt(delivery *models.WebhookDelivery, statusCode int, err error, maxAttempts int) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delivery.Attempts++
+	attempts := delivery.Attempts
+	delivery.LastStatusCode = statusCode
+	delivery.UpdatedAt = time.Now().UTC()
+
+	defer d.saveLocked()
+
+	if err == nil {
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+		delete(d.events, delivery.ID)
+		return attempts, true
+	}
+
+	delivery.LastError = err.Error()
+	if attempts < maxAttempts {
+		return attempts, false
+	}
+
+	delivery.Status = DeliveryDead
+	d.deadLetters = append(d.deadLetters, delivery)
+	if len(d.deadLetters) > maxDeliveryLog {
+		delete(d.events, d.deadLetters[0].ID)
+		d.deadLetters = d.deadLetters[1:]
+	}
+	return attempts, true
+}
+
+func snapshotDeliveries(list []*models.WebhookDelivery, tenantID string, webhookID int) []models.WebhookDelivery {
+	result := []models.WebhookDelivery{}
+	for _, delivery := range list {
+		if delivery.TenantID != tenantID {
+			continue
+		}
+		if webhookID != 0 && delivery.WebhookID != webhookID {
+			continue
+		}
+		result = append(result, *delivery)
+	}
+	return result
+}
+
+// GetWebhookDeliveries retorna o log das entregas mais recentes do tenant. Com
+// webhookID diferente de zero, filtra as entregas de um único webhook.
+func GetWebhookDeliveries(tenantID string, webhookID int) []models.WebhookDelivery {
+	deliveries.mu.Lock()
+	defer deliveries.mu.Unlock()
+	return snapshotDeliveries(deliveries.log, tenantID, webhookID)
+}
+
+func GetWebhookDeadLetters(tenantID string) []models.WebhookDelivery {
+	deliveries.mu.Lock()
+	defer deliveries.mu.Unlock()
+	return snapshotDeliveries(deliveries.deadLetters, tenantID, 0)
+}
+
+// scanWebhookPayloads conta os payloads guardados para reenvio cujo contato
+// satisfaz match e, com redact, reduz esse contato ao ID e ao dono.
+func scanWebhookPayloads(tenantID string, match func(models.Contact) bool, redact bool) int {
+	deliveries.mu.Lock()
+	defer deliveries.mu.Unlock()
+
+	count := 0
+	for id, event := range deliveries.events {
+		if event.TenantID != tenantID || !match(event.Contact) {
+			continue
+		}
+		redacted := models.Contact{ID: event.Contact.ID, OwnerID: event.Contact.OwnerID}
+		if event.Contact == redacted {
+			continue
+		}
+		count++
+		if redact {
+			event.Contact = redacted
+			deliveries.events[id] = event
+		}
+	}
+	if redact && count > 0 {
+		deliveries.saveLocked()
+	}
+	return count
+}
+
+// RetryDeadLetter remove a entrega da fila de mensagens mortas e reinicia as
+// tentativas de envio com o mesmo evento.
+func RetryDeadLetter(ctx context.Context, tenantID string, deliveryID int) error {
+	ctx, span := tracing.Start(ctx, "services.RetryDeadLetter")
+	defer span.End()
+
+	webhooks, err := storage.LoadWebhooks(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	deliveries.mu.Lock()
+	defer deliveries.mu.Unlock()
+
+	index := -1
+	for i, delivery := range deliveries.deadLetters {
+		if delivery.ID == deliveryID && delivery.TenantID == tenantID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrDeliveryNotFound
+	}
+
+	delivery := deliveries.deadLetters[index]
+	for _, webhook := range webhooks {
+		if webhook.ID != delivery.WebhookID {
+			continue
+		}
+
+		deliveries.deadLetters = append(deliveries.deadLetters[:index], deliveries.deadLetters[index+1:]...)
+		delivery.Status = DeliveryPending
+		delivery.Attempts = 0
+		if !slices.Contains(deliveries.log, delivery) {
+			deliveries.log = append(deliveries.log, delivery)
+		}
+		deliveries.saveLocked()
+		startDelivery(webhook, deliveries.events[delivery.ID], delivery)
+		return nil
+	}
+
+	return ErrWebhookNotFound
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const apiKeysFile = "api_keys.json"
+
+func LoadAPIKeys(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	_, span := tracing.Start(ctx, "storage.LoadAPIKeys")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var keys []models.APIKey
+	path, err := tenantFile(tenantID, apiKeysFile)
+	if err != nil {
+		return keys, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return keys, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return keys, err
+	}
+	if len(byteValue) == 0 {
+		return keys, nil
+	}
+
+	err = json.Unmarshal(byteValue, &keys)
+	return keys, err
+}
+
+func SaveAPIKeys(ctx context.Context, tenantID string, keys []models.APIKey) error {
+	_, span := tracing.Start(ctx, "storage.SaveAPIKeys")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, apiKeysFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
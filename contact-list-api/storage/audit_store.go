@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// O log de auditoria é só de acréscimo: uma entrada JSON por linha, gravada
+// com O_APPEND, sem nunca reescrever o arquivo.
+const auditLogFile = "audit_log.jsonl"
+
+// O topo da cadeia fica fora do log, para que cortar as últimas entradas
+// não passe despercebido.
+const auditHeadFile = "audit_head.json"
+
+// AuditHead é o seq e o hash da última entrada gravada no log.
+type AuditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+func LoadAuditLog(ctx context.Context, tenantID string) ([]models.AuditEntry, error) {
+	_, span := tracing.Start(ctx, "storage.LoadAuditLog")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var entries []models.AuditEntry
+	path, err := tenantFile(tenantID, auditLogFile)
+	if err != nil {
+		return entries, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return entries, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func AppendAuditEntry(ctx context.Context, tenantID string, entry models.AuditEntry) error {
+	_, span := tracing.Start(ctx, "storage.AppendAuditEntry")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, auditLogFile)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadAuditHead devolve o topo gravado da cadeia. Sem arquivo, devolve o
+// topo vazio, de um log sem entradas.
+func LoadAuditHead(ctx context.Context, tenantID string) (AuditHead, error) {
+	_, span := tracing.Start(ctx, "storage.LoadAuditHead")
+	defer span.End()
+
+	var head AuditHead
+	if err := ctx.Err(); err != nil {
+		return head, err
+	}
+	path, err := tenantFile(tenantID, auditHeadFile)
+	if err != nil {
+		return head, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return head, nil
+	}
+	if err != nil {
+		return head, err
+	}
+	err = json.Unmarshal(data, &head)
+	return head, err
+}
+
+// SaveAuditHead troca o topo da cadeia, de forma atômica.
+func SaveAuditHead(ctx context.Context, tenantID string, head AuditHead) error {
+	_, span := tracing.Start(ctx, "storage.SaveAuditHead")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, auditHeadFile)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const changeLogFile = "changes.json"
+
+// maxTombstones limita quantas exclusões ficam registradas. Ao descartar as
+// mais antigas, PrunedSeq avança e tokens de sincronização anteriores a ele
+// deixam de ser aceitos.
+const maxTombstones = 1000
+
+// ChangeEntry guarda a sequência da última alteração de cada contato.
+type ChangeEntry struct {
+	ContactID int       `json:"contact_id"`
+	OwnerID   int       `json:"owner_id,omitempty"`
+	Seq       int64     `json:"seq"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ChangeLog guarda também LastID, o maior ID de contato que o tenant já
+// usou. Os IDs novos partem dele e nunca voltam a um ID excluído: a exclusão
+// continua no log com o dono de antes, e a sincronização dele a relata.
+type ChangeLog struct {
+	LastSeq   int64         `json:"last_seq"`
+	PrunedSeq int64         `json:"pruned_seq"`
+	LastID    int           `json:"last_id,omitempty"`
+	Entries   []ChangeEntry `json:"entries"`
+}
+
+// coverIDs avança LastID até o maior ID dos contatos e do log. Logs
+// gravados antes de LastID existir partem daí.
+func (l *ChangeLog) coverIDs(stored []storedContact) {
+	for _, s := range stored {
+		l.LastID = max(l.LastID, s.ID)
+	}
+	for _, entry := range l.Entries {
+		l.LastID = max(l.LastID, entry.ContactID)
+	}
+}
+
+// LoadChangeLog devolve uma cópia do log de alterações do tenant, que fica
+// em memória com os contatos.
+func LoadChangeLog(ctx context.Context, tenantID string) (ChangeLog, error) {
+	ctx, span := tracing.Start(ctx, "storage.LoadChangeLog")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return ChangeLog{}, err
+	}
+	repo, err := contactRepo(ctx, tenantID)
+	if err != nil {
+		return ChangeLog{}, err
+	}
+	changeLog := repo.current().changeLog
+	changeLog.Entries = append([]ChangeEntry(nil), changeLog.Entries...)
+	return changeLog, nil
+}
+
+func readChangeLog(ctx context.Context, tenantID string) (ChangeLog, error) {
+	if err := ctx.Err(); err != nil {
+		return ChangeLog{}, err
+	}
+
+	var changeLog ChangeLog
+	path, err := tenantFile(tenantID, changeLogFile)
+	if err != nil {
+		return changeLog, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return changeLog, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return changeLog, err
+	}
+	if len(byteValue) == 0 {
+		return changeLog, nil
+	}
+
+	err = json.Unmarshal(byteValue, &changeLog)
+	return changeLog, err
+}
+
+func saveChangeLog(tenantID string, changeLog ChangeLog) error {
+	data, err := json.MarshalIndent(changeLog, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, changeLogFile)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// recordChanges compara a lista anterior com a nova e atribui uma nova
+// sequência a cada contato criado, alterado ou removido. changeLog é
+// alterado no lugar; quem chama passa uma cópia.
+func recordChanges(changeLog *ChangeLog, previous, current []models.Contact) {
+	entries := make(map[int]ChangeEntry, len(changeLog.Entries))
+	for _, entry := range changeLog.Entries {
+		entries[entry.ContactID] = entry
+	}
+
+	before := make(map[int]models.Contact, len(previous))
+	for _, contact := range previous {
+		before[contact.ID] = contact
+	}
+
+	now := time.Now().UTC()
+	changed := false
+	mark := func(contact models.Contact, deleted bool) {
+		changeLog.LastSeq++
+		entries[contact.ID] = ChangeEntry{ContactID: contact.ID, OwnerID: contact.OwnerID, Seq: changeLog.LastSeq, Deleted: deleted, ChangedAt: now}
+		changed = true
+	}
+
+	seen := make(map[int]bool, len(current))
+	for _, contact := range current {
+		seen[contact.ID] = true
+		changeLog.LastID = max(changeLog.LastID, contact.ID)
+		if old, ok := before[contact.ID]; !ok || old != contact {
+			mark(contact, false)
+		}
+	}
+	for _, contact := range previous {
+		if !seen[contact.ID] {
+			mark(contact, true)
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	changeLog.Entries = changeLog.Entries[:0]
+	for _, entry := range entries {
+		changeLog.Entries = append(changeLog.Entries, entry)
+	}
+	sort.Slice(changeLog.Entries, func(i, j int) bool {
+		return changeLog.Entries[i].Seq < changeLog.Entries[j].Seq
+	})
+	pruneTombstones(changeLog)
+}
+
+func pruneTombstones(changeLog *ChangeLog) {
+	tombstones := 0
+	for _, entry := range changeLog.Entries {
+		if entry.Deleted {
+			tombstones++
+		}
+	}
+
+	kept := changeLog.Entries[:0]
+	for _, entry := range changeLog.Entries {
+		if entry.Deleted && tombstones > maxTombstones {
+			tombstones--
+			changeLog.PrunedSeq = entry.Seq
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	changeLog.Entries = kept
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const consentsFile = "consents.json"
+
+func LoadConsents(ctx context.Context, tenantID string) ([]models.ConsentRecord, error) {
+	_, span := tracing.Start(ctx, "storage.LoadConsents")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var records []models.ConsentRecord
+	path, err := tenantFile(tenantID, consentsFile)
+	if err != nil {
+		return records, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return records, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return records, err
+	}
+	if len(byteValue) == 0 {
+		return records, nil
+	}
+
+	err = json.Unmarshal(byteValue, &records)
+	return records, err
+}
+
+func SaveConsents(ctx context.Context, tenantID string, records []models.ConsentRecord) error {
+	_, span := tracing.Start(ctx, "storage.SaveConsents")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, consentsFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/fieldcrypt"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	emailIndexContext = "email"
+	phoneIndexContext = "phone"
+)
+
+var (
+	ErrEncryptionDisabled   = errors.New("field encryption is not configured")
+	ErrEncryptionKeyMissing = errors.New("contacts file has encrypted fields but no encryption key is configured")
+)
+
+var (
+	keyringMu sync.RWMutex
+	keyring   *fieldcrypt.Keyring
+)
+
+// ConfigureEncryption liga a cifra de e-mail e telefone em contacts.json.
+// Com nil, os contatos são gravados em texto puro, como antes. Os tenants
+// já em memória voltam a ser lidos do disco, com a nova chave.
+func ConfigureEncryption(k *fieldcrypt.Keyring) {
+	keyringMu.Lock()
+	keyring = k
+	keyringMu.Unlock()
+	dropCleanRepositories()
+}
+
+func currentKeyring() *fieldcrypt.Keyring {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	return keyring
+}
+
+// storedContact é o formato de contacts.json: e-mail e telefone cifrados,
+// acompanhados dos índices cegos usados nas buscas exatas. Sem cifra, é
+// idêntico a models.Contact.
+type storedContact struct {
+	ID         int    `json:"id"`
+	OwnerID    int    `json:"owner_id,omitempty"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Phone      string `json:"phone"`
+	EmailIndex string `json:"email_bidx,omitempty"`
+	PhoneIndex string `json:"phone_bidx,omitempty"`
+}
+
+// fileSum é o SHA-256 do conteúdo de um arquivo.
+type fileSum [sha256.Size]byte
+
+func loadStoredContacts(ctx context.Context, tenantID string) ([]storedContact, fileSum, error) {
+	ctx, span := tracing.Start(ctx, "storage.readContactsFile")
+	defer span.End()
+
+	var stored []storedContact
+	if err := ctx.Err(); err != nil {
+		return stored, fileSum{}, tracing.Fail(span, err)
+	}
+	path, err := tenantFile(tenantID, dataFile)
+	if err != nil {
+		return stored, fileSum{}, tracing.Fail(span, err)
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return stored, fileSum{}, tracing.Fail(span, err)
+	}
+	defer file.Close()
+
+	start := time.Now()
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		slog.ErrorContext(ctx, "contacts file unreadable", "tenant", tenantID, "file", path, "error", err)
+		return stored, fileSum{}, tracing.Fail(span, err)
+	}
+	sum := fileSum(sha256.Sum256(byteValue))
+	span.SetAttributes(attribute.Int("file.bytes", len(byteValue)))
+	if len(byteValue) == 0 {
+		metrics.ObserveStorage(metrics.OperationLoad, 0, time.Since(start))
+		recordContactTotals(tenantID, stored)
+		return stored, sum, nil
+	}
+
+	// A leitura pode ter demorado; se o cliente desistiu nesse meio tempo, a
+	// decodificação, que custa mais, não é feita.
+	if err := ctx.Err(); err != nil {
+		return stored, fileSum{}, tracing.Fail(span, err)
+	}
+
+	// A decodificação tem span próprio por ser, em arquivos grandes, a maior
+	// parte do tempo da leitura.
+	_, decode := tracing.Start(ctx, "storage.decodeContacts")
+	err = json.Unmarshal(byteValue, &stored)
+	decode.End()
+	if err != nil {
+		err = corruptJSON(path, byteValue, err)
+		slog.ErrorContext(ctx, "contacts file unreadable", "tenant", tenantID, "file", path, "error", err)
+		return stored, fileSum{}, tracing.Fail(span, err)
+	}
+	duration := time.Since(start)
+	span.SetAttributes(attribute.Int("contacts.count", len(stored)))
+	metrics.ObserveStorage(metrics.OperationLoad, len(byteValue), duration)
+	recordContactTotals(tenantID, stored)
+	slog.DebugContext(ctx, "contacts loaded", "tenant", tenantID, "count", len(stored), "bytes", len(byteValue), "duration", duration)
+	return stored, sum, nil
+}
+
+func writeStoredContacts(ctx context.Context, tenantID string, stored []storedContact) (fileSum, error) {
+	ctx, span := tracing.Start(ctx, "storage.writeContactsFile", attribute.Int("contacts.count", len(stored)))
+	defer span.End()
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	path, err := tenantFile(tenantID, dataFile)
+	if err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	span.SetAttributes(attribute.Int("file.bytes", len(data)))
+
+	// A verificação fica logo antes da escrita: depois dela, a gravação vai
+	// até o fim, para não deixar o arquivo pela metade.
+	if err := ctx.Err(); err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	start := time.Now()
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		slog.ErrorContext(ctx, "contacts not saved", "tenant", tenantID, "file", path, "error", err)
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	duration := time.Since(start)
+	metrics.ObserveStorage(metrics.OperationSave, len(data), duration)
+	recordContactTotals(tenantID, stored)
+	slog.DebugContext(ctx, "contacts saved", "tenant", tenantID, "count", len(stored), "bytes", len(data), "duration", duration)
+
+	// O snapshot já está gravado; sem o backup, só a recuperação fica sem
+	// essa cópia.
+	if err := writeBackup(tenantID, data); err != nil {
+		slog.WarnContext(ctx, "contacts backup not written", "tenant", tenantID, "error", err)
+	}
+	return sha256.Sum256(data), nil
+}
+
+// recordContactTotals atualiza as métricas do tenant a cada leitura e
+// gravação, que sempre passam pelo arquivo inteiro.
+func recordContactTotals(tenantID string, stored []storedContact) {
+	emailIndexed, phoneIndexed := 0, 0
+	for _, s := range stored {
+		if s.EmailIndex != "" {
+			emailIndexed++
+		}
+		if s.PhoneIndex != "" {
+			phoneIndexed++
+		}
+	}
+	metrics.SetContactTotals(tenantID, len(stored), emailIndexed, phoneIndexed)
+}
+
+// fieldAAD são os dados associados de um campo cifrado: o ID do contato e
+// o nome do campo. Um e-mail cifrado copiado para outro contato, ou para o
+// telefone, não se decifra.
+func fieldAAD(contactID int, field string) []byte {
+	return []byte(strconv.Itoa(contactID) + "\x00" + field)
+}
+
+func sealContact(k *fieldcrypt.Keyring, contact models.Contact) (storedContact, error) {
+	stored := storedContact{
+		ID:      contact.ID,
+		OwnerID: contact.OwnerID,
+		Name:    contact.Name,
+		Email:   contact.Email,
+		Phone:   contact.Phone,
+	}
+	if k == nil {
+		return stored, nil
+	}
+
+	var err error
+	if stored.Email, err = k.Encrypt(contact.Email, fieldAAD(contact.ID, "email")); err != nil {
+		return storedContact{}, err
+	}
+	if stored.Phone, err = k.Encrypt(contact.Phone, fieldAAD(contact.ID, "phone")); err != nil {
+		return storedContact{}, err
+	}
+	stored.EmailIndex = k.BlindIndex(emailIndexContext, NormalizeEmail(contact.Email))
+	stored.PhoneIndex = k.BlindIndex(phoneIndexContext, NormalizePhone(contact.Phone))
+	return stored, nil
+}
+
+func openContact(k *fieldcrypt.Keyring, stored storedContact) (models.Contact, error) {
+	contact := models.Contact{
+		ID:      stored.ID,
+		OwnerID: stored.OwnerID,
+		Name:    stored.Name,
+		Email:   stored.Email,
+		Phone:   stored.Phone,
+	}
+	if k == nil {
+		if fieldcrypt.IsEncrypted(stored.Email) || fieldcrypt.IsEncrypted(stored.Phone) {
+			return models.Contact{}, ErrEncryptionKeyMissing
+		}
+		return contact, nil
+	}
+
+	var err error
+	if contact.Email, err = k.Decrypt(stored.Email, fieldAAD(stored.ID, "email")); err != nil {
+		return models.Contact{}, err
+	}
+	if contact.Phone, err = k.Decrypt(stored.Phone, fieldAAD(stored.ID, "phone")); err != nil {
+		return models.Contact{}, err
+	}
+	return contact, nil
+}
+
+// FindContactsByEmail devolve os contatos do tenant com o e-mail informado,
+// sem diferenciar maiúsculas, pelo índice em memória.
+func FindContactsByEmail(ctx context.Context, tenantID, email string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "storage.FindContactsByEmail")
+	defer span.End()
+
+	return findContacts(ctx, tenantID, func(s *contactState) []int {
+		return s.byEmail[NormalizeEmail(email)]
+	})
+}
+
+// FindContactsByPhone é como FindContactsByEmail, comparando apenas os
+// dígitos do telefone.
+func FindContactsByPhone(ctx context.Context, tenantID, phone string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "storage.FindContactsByPhone")
+	defer span.End()
+
+	return findContacts(ctx, tenantID, func(s *contactState) []int {
+		return s.byPhone[NormalizePhone(phone)]
+	})
+}
+
+func findContacts(ctx context.Context, tenantID string, lookup func(*contactState) []int) ([]models.Contact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo, err := contactRepo(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	state := repo.current()
+	return state.at(lookup(state)), nil
+}
+
+// ReencryptContacts recifra com a chave ativa os contatos em texto puro ou
+// cifrados com chaves antigas, recalculando os índices cegos, e devolve
+// quantos foram regravados. Depois de rodar em todos os tenants, as chaves
+// antigas podem sair do arquivo de chaves. O log de alterações não muda,
+// pois os dados continuam os mesmos.
+func ReencryptContacts(ctx context.Context, tenantID string) (int, error) {
+	ctx, span := tracing.Start(ctx, "storage.ReencryptContacts")
+	defer span.End()
+
+	k := currentKeyring()
+	if k == nil {
+		return 0, ErrEncryptionDisabled
+	}
+
+	// A recifragem parte do snapshot com o journal aplicado: as alterações
+	// ainda na memória são gravadas antes, e nenhuma outra gravação do tenant
+	// corre junto. O resultado vai para um novo snapshot.
+	repo, err := contactRepo(ctx, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+	if err := repo.flushLocked(ctx); err != nil {
+		return 0, err
+	}
+
+	state, err := readStoredState(ctx, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	stored := state.contacts
+
+	count := 0
+	for i, s := range stored {
+		contact, err := openContact(k, s)
+		if err != nil {
+			return 0, err
+		}
+		if !k.NeedsRotation(s.Email) && !k.NeedsRotation(s.Phone) &&
+			s.EmailIndex == k.BlindIndex(emailIndexContext, NormalizeEmail(contact.Email)) &&
+			s.PhoneIndex == k.BlindIndex(phoneIndexContext, NormalizePhone(contact.Phone)) {
+			continue
+		}
+
+		if stored[i], err = sealContact(k, contact); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+	sum, err := writeSnapshot(ctx, tenantID, stored, state.changeLog)
+	if err != nil {
+		return 0, err
+	}
+	repo.snapshot, repo.snapshotSum = stored, sum
+	repo.journalRecords = 0
+	repo.indexes = newDiskIndexes(stored)
+	return count, nil
+}
+
+// NormalizeEmail é a forma do e-mail usada nos índices cegos e nas
+// comparações: sem espaços nas pontas e em minúsculas.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone mantém apenas os dígitos do telefone.
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// CheckHealth confere se o armazenamento pode atender requisições: lê e
+// decodifica os contatos do tenant padrão e cria, grava e apaga um arquivo
+// de teste em data/.
+func CheckHealth(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "storage.CheckHealth")
+	defer span.End()
+
+	if _, _, err := loadStoredContacts(ctx, models.DefaultTenantID); err != nil {
+		return tracing.Fail(span, fmt.Errorf("data store not readable: %w", err))
+	}
+
+	probe, err := os.CreateTemp(basePath, ".readyz-*")
+	if err != nil {
+		return tracing.Fail(span, fmt.Errorf("data store not writable: %w", err))
+	}
+	defer os.Remove(probe.Name())
+
+	if _, err := probe.WriteString("ok"); err != nil {
+		probe.Close()
+		return tracing.Fail(span, fmt.Errorf("data store not writable: %w", err))
+	}
+	if err := probe.Close(); err != nil {
+		return tracing.Fail(span, fmt.Errorf("data store not writable: %w", err))
+	}
+	return nil
+}
+
+// Flush grava os contatos que o write-behind ainda não gravou e força para o
+// disco os arquivos de data/, inclusive os dos tenants, e os diretórios que
+// os guardam. Os demais arquivos são gravados com os.WriteFile, que deixa os
+// dados no cache do sistema operacional; no desligamento, Flush garante que
+// eles sobrevivam a uma queda logo depois.
+func Flush(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "storage.Flush")
+	defer span.End()
+
+	if err := flushRepositories(ctx); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	err := filepath.WalkDir(basePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return file.Sync()
+	})
+	return tracing.Fail(span, err)
+}
@@ -0,0 +1,776 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	backupsDir    = "backups"
+	quarantineDir = "quarantine"
+	// maxBackups é quantas cópias de snapshots ficam em backups/ por tenant.
+	maxBackups = 5
+	// fileStampLayout ordena os nomes de backups e da quarentena pela data.
+	fileStampLayout = "20060102T150405.000000000Z"
+)
+
+var (
+	ErrCorruptFile         = errors.New("corrupt data file")
+	ErrNoGoodBackup        = errors.New("no readable backup of the contacts file")
+	ErrInvalidRecoveryMode = errors.New("invalid recovery mode, expected none, backup or salvage")
+)
+
+// CorruptFileError aponta onde um arquivo de dados deixou de ser lido.
+// Line e Column começam em 1; no journal, Line é o registro.
+type CorruptFileError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *CorruptFileError) Error() string {
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	default:
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+}
+
+func (e *CorruptFileError) Unwrap() []error {
+	return []error{ErrCorruptFile, e.Err}
+}
+
+// corruptJSON localiza no arquivo o erro de decodificação. Sem posição no
+// erro, como num arquivo cortado, aponta o fim.
+func corruptJSON(path string, data []byte, err error) error {
+	offset := int64(len(data))
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	line, column := position(data, offset)
+	return &CorruptFileError{File: path, Line: line, Column: column, Err: err}
+}
+
+// position devolve a linha e a coluna do último byte lido antes de offset,
+// onde o decodificador percebeu o erro.
+func position(data []byte, offset int64) (int, int) {
+	i := int(min(max(offset-1, 0), int64(len(data))))
+	before := data[:i]
+	return bytes.Count(before, []byte("\n")) + 1, i - bytes.LastIndexByte(before, '\n')
+}
+
+// decodeContactsFile decodifica um contacts.json e confere os IDs, que
+// precisam ser positivos e únicos para os índices.
+func decodeContactsFile(path string, data []byte) ([]storedContact, error) {
+	var stored []storedContact
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, corruptJSON(path, data, err)
+	}
+	if err := validateStoredContacts(stored); err != nil {
+		return nil, &CorruptFileError{File: path, Err: err}
+	}
+	return stored, nil
+}
+
+func validateStoredContacts(stored []storedContact) error {
+	seen := make(map[int]bool, len(stored))
+	for i, s := range stored {
+		switch {
+		case s.ID <= 0:
+			return fmt.Errorf("contact %d: invalid id %d", i+1, s.ID)
+		case seen[s.ID]:
+			return fmt.Errorf("contact %d: duplicate id %d", i+1, s.ID)
+		case s.OwnerID < 0:
+			return fmt.Errorf("contact %d: invalid owner_id %d", i+1, s.OwnerID)
+		}
+		seen[s.ID] = true
+	}
+	return nil
+}
+
+// Situação de cada arquivo no relatório de integridade. Um journal com a
+// última linha cortada é consertado na leitura e não torna o tenant
+// corrompido.
+const (
+	FileOK      = "ok"
+	FileTorn    = "torn"
+	FileCorrupt = "corrupt"
+)
+
+// FileReport é a situação de um arquivo de dados do tenant.
+type FileReport struct {
+	File    string `json:"file" example:"contacts.json"`
+	Status  string `json:"status" example:"corrupt"`
+	Records int    `json:"records" example:"0"`
+	Line    int    `json:"line,omitempty" example:"12"`
+	Column  int    `json:"column,omitempty" example:"5"`
+	Error   string `json:"error,omitempty" example:"invalid character '}' looking for beginning of object key string"`
+}
+
+// IntegrityReport reúne a situação dos arquivos de contatos de um tenant.
+type IntegrityReport struct {
+	TenantID string       `json:"tenant_id" example:"acme"`
+	Healthy  bool         `json:"healthy" example:"false"`
+	Files    []FileReport `json:"files"`
+}
+
+func (r IntegrityReport) corrupt(name string) bool {
+	for _, file := range r.Files {
+		if file.File == name {
+			return file.Status == FileCorrupt
+		}
+	}
+	return false
+}
+
+// CheckIntegrity lê, sem alterar nada, o contacts.json, o changes.json e o
+// journal do tenant e aponta o que não se lê, com linha e coluna.
+func CheckIntegrity(ctx context.Context, tenantID string) (IntegrityReport, error) {
+	ctx, span := tracing.Start(ctx, "storage.CheckIntegrity", attribute.String("tenant", tenantID))
+	defer span.End()
+
+	report := IntegrityReport{TenantID: tenantID, Healthy: true}
+	checks := []struct {
+		name  string
+		check func(path string, data []byte) FileReport
+	}{
+		{dataFile, checkContactsFile},
+		{changeLogFile, checkChangeLogFile},
+		{journalFile, checkJournalFile},
+	}
+	for _, c := range checks {
+		if err := ctx.Err(); err != nil {
+			return report, tracing.Fail(span, err)
+		}
+		path, err := tenantFile(tenantID, c.name)
+		if err != nil {
+			return report, tracing.Fail(span, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return report, tracing.Fail(span, err)
+		}
+
+		file := FileReport{File: c.name, Status: FileOK}
+		if len(data) > 0 {
+			file = c.check(path, data)
+			file.File = c.name
+		}
+		if file.Status == FileCorrupt {
+			report.Healthy = false
+		}
+		report.Files = append(report.Files, file)
+	}
+	span.SetAttributes(attribute.Bool("healthy", report.Healthy))
+	return report, nil
+}
+
+func corruptReport(err error) FileReport {
+	file := FileReport{Status: FileCorrupt, Error: err.Error()}
+	var corrupt *CorruptFileError
+	if errors.As(err, &corrupt) {
+		file.Line, file.Column, file.Error = corrupt.Line, corrupt.Column, corrupt.Err.Error()
+	}
+	return file
+}
+
+func checkContactsFile(path string, data []byte) FileReport {
+	stored, err := decodeContactsFile(path, data)
+	if err != nil {
+		return corruptReport(err)
+	}
+	return FileReport{Status: FileOK, Records: len(stored)}
+}
+
+func checkChangeLogFile(path string, data []byte) FileReport {
+	var changeLog ChangeLog
+	if err := json.Unmarshal(data, &changeLog); err != nil {
+		return corruptReport(corruptJSON(path, data, err))
+	}
+	return FileReport{Status: FileOK, Records: len(changeLog.Entries)}
+}
+
+func checkJournalFile(path string, data []byte) FileReport {
+	lines := splitJournal(data)
+	file := FileReport{Status: FileOK}
+	for i, line := range lines {
+		switch {
+		case line.err == nil:
+			file.Records++
+		case i < len(lines)-1:
+			return corruptReport(&CorruptFileError{File: path, Line: line.number, Err: line.err})
+		default:
+			file.Status, file.Line, file.Error = FileTorn, line.number, line.err.Error()
+		}
+	}
+	return file
+}
+
+// writeBackup guarda em backups/ uma cópia do snapshot recém-gravado e
+// apaga as mais antigas que maxBackups.
+func writeBackup(tenantID string, data []byte) error {
+	dir, err := tenantFile(tenantID, backupsDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	name := "contacts-" + time.Now().UTC().Format(fileStampLayout) + ".json"
+	if err := writeFileAtomic(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+
+	backups, err := listBackups(dir)
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(maxBackups, len(backups)):] {
+		if err := os.Remove(filepath.Join(dir, old)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listBackups devolve os backups do diretório, do mais novo ao mais antigo.
+func listBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name := entry.Name(); entry.Type().IsRegular() && strings.HasPrefix(name, "contacts-") && strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	slices.Reverse(names)
+	return names, nil
+}
+
+// latestGoodBackup devolve o backup mais novo que se lê inteiro, pulando os
+// que também estiverem corrompidos.
+func latestGoodBackup(ctx context.Context, tenantID string) (string, []storedContact, error) {
+	dir, err := tenantFile(tenantID, backupsDir)
+	if err != nil {
+		return "", nil, err
+	}
+	backups, err := listBackups(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, name := range backups {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		stored, err := decodeContactsFile(path, data)
+		if err != nil {
+			slog.WarnContext(ctx, "contacts backup skipped", "tenant", tenantID, "error", err)
+			continue
+		}
+		return filepath.Join(backupsDir, name), stored, nil
+	}
+	return "", nil, ErrNoGoodBackup
+}
+
+// PurgeContactHistory tira os contatos de ids de todas as cópias antigas do
+// tenant, para que um contato excluído não sobreviva nelas: grava um novo
+// snapshot, o que esvazia o journal, e regrava sem esses contatos os
+// backups e os arquivos em quarentena.
+func PurgeContactHistory(ctx context.Context, tenantID string, ids []int) error {
+	ctx, span := tracing.Start(ctx, "storage.PurgeContactHistory", attribute.String("tenant", tenantID), attribute.Int("contacts.count", len(ids)))
+	defer span.End()
+
+	repo, err := contactRepo(ctx, tenantID)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+	if err := repo.compactLocked(ctx); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	erased := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		erased[id] = true
+	}
+	return tracing.Fail(span, purgeOldCopies(ctx, tenantID, erased))
+}
+
+// purgeOldCopies regrava os backups e os arquivos em quarentena que guardam
+// algum contato de erased. Um contacts.json que não se lê inteiro fica só
+// com os contatos que ainda se aproveitam, e de um journal em quarentena
+// saem as linhas ilegíveis, já que não há como saber de quem são os dados
+// nelas. O changes.json guarda apenas IDs e não muda.
+func purgeOldCopies(ctx context.Context, tenantID string, erased map[int]bool) error {
+	return eachOldCopy(tenantID, func(path string, journal bool) error {
+		if journal {
+			return purgeJournalCopy(path, erased)
+		}
+		return purgeContactsCopy(ctx, tenantID, path, erased)
+	})
+}
+
+// eachOldCopy chama visit para cada cópia de contacts.json e do journal em
+// backups/ e quarantine/ do tenant.
+func eachOldCopy(tenantID string, visit func(path string, journal bool) error) error {
+	for _, name := range []string{backupsDir, quarantineDir} {
+		dir, err := tenantFile(tenantID, name)
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			file := entry.Name()
+			path := filepath.Join(dir, file)
+			switch {
+			case !entry.Type().IsRegular():
+			case strings.HasPrefix(file, "contacts-") || strings.HasPrefix(file, dataFile+"."):
+				err = visit(path, false)
+			case strings.HasPrefix(file, journalFile+"."):
+				err = visit(path, true)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CountContactHistory conta os contatos para os quais match é verdadeiro no
+// journal, nos backups e nos arquivos em quarentena do tenant, as cópias que
+// PurgeContactHistory regrava. Um contato ou uma linha do journal que não
+// se lê também conta, já que não há como saber de quem são os dados.
+func CountContactHistory(ctx context.Context, tenantID string, match func(models.Contact) bool) (int, error) {
+	ctx, span := tracing.Start(ctx, "storage.CountContactHistory", attribute.String("tenant", tenantID))
+	defer span.End()
+
+	k := currentKeyring()
+	count := 0
+	countStored := func(stored []storedContact) {
+		for _, s := range stored {
+			contacts, err := openContacts(k, []storedContact{s})
+			if err != nil || match(contacts[0]) {
+				count++
+			}
+		}
+	}
+	countJournal := func(path string) error {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, line := range splitJournal(data) {
+			if line.err != nil {
+				count++
+				continue
+			}
+			countStored(line.record.Puts)
+		}
+		return nil
+	}
+
+	journal, err := tenantFile(tenantID, journalFile)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	if err := countJournal(journal); err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	err = eachOldCopy(tenantID, func(path string, isJournal bool) error {
+		if isJournal {
+			return countJournal(path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		stored, err := decodeContactsFile(path, data)
+		if err != nil {
+			var dropped int
+			stored, dropped = salvageContacts(ctx, tenantID, path, data)
+			count += dropped
+		}
+		countStored(stored)
+		return nil
+	})
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return count, nil
+}
+
+func purgeContactsCopy(ctx context.Context, tenantID, path string, erased map[int]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	stored, err := decodeContactsFile(path, data)
+	if err != nil {
+		stored, _ = salvageContacts(ctx, tenantID, path, data)
+	}
+	kept := slices.DeleteFunc(slices.Clone(stored), func(s storedContact) bool { return erased[s.ID] })
+	if err == nil && len(kept) == len(stored) {
+		return nil
+	}
+	data, err = json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+func purgeJournalCopy(path string, erased map[int]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var purged []byte
+	changed := false
+	for _, line := range splitJournal(data) {
+		if line.err != nil {
+			changed = true
+			continue
+		}
+		record := line.record
+		puts := len(record.Puts)
+		record.Puts = slices.DeleteFunc(record.Puts, func(s storedContact) bool { return erased[s.ID] })
+		changed = changed || len(record.Puts) != puts
+		encoded, err := encodeJournalLine(record)
+		if err != nil {
+			return err
+		}
+		purged = append(purged, encoded...)
+	}
+	if !changed {
+		return nil
+	}
+	return writeFileAtomic(path, purged, 0644)
+}
+
+// quarantine move o arquivo do tenant para quarantine/, com a data no nome,
+// e devolve o novo caminho relativo ao diretório do tenant.
+func quarantine(tenantID, name string) (string, error) {
+	path, err := tenantFile(tenantID, name)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(filepath.Dir(path), quarantineDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	target := name + "." + time.Now().UTC().Format(fileStampLayout)
+	if err := os.Rename(path, filepath.Join(dir, target)); err != nil {
+		return "", err
+	}
+	return filepath.Join(quarantineDir, target), nil
+}
+
+// salvageContacts recupera de um contacts.json corrompido cada contato que
+// ainda se lê inteiro. Como um contato não tem objetos nem listas dentro,
+// um '{' ou '[' fora de texto no meio de um contato, ou uma quebra de linha
+// dentro de um texto, encerram o contato quebrado e a leitura recomeça
+// dali. Devolve os contatos, sem repetir IDs, e quantos foram descartados.
+func salvageContacts(ctx context.Context, tenantID, path string, data []byte) ([]storedContact, int) {
+	var stored []storedContact
+	seen := map[int]bool{}
+	dropped := 0
+	try := func(start, end int) {
+		var s storedContact
+		err := json.Unmarshal(data[start:end], &s)
+		if err == nil {
+			err = validateStoredContacts([]storedContact{s})
+		}
+		if err == nil && seen[s.ID] {
+			err = fmt.Errorf("duplicate id %d", s.ID)
+		}
+		if err != nil {
+			dropped++
+			line, column := position(data, int64(start+1))
+			slog.WarnContext(ctx, "contact not salvaged", "tenant", tenantID, "file", path, "line", line, "column", column, "error", err)
+			return
+		}
+		seen[s.ID] = true
+		stored = append(stored, s)
+	}
+
+	start := -1
+	inString, escaped := false, false
+	for i, c := range data {
+		switch {
+		case inString && escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case inString && c == '"':
+			inString = false
+		case inString && c == '\n':
+			inString = false
+			if start >= 0 {
+				try(start, i)
+				start = -1
+			}
+		case inString:
+		case c == '"':
+			inString = start >= 0
+		case c == '{':
+			if start >= 0 {
+				try(start, i)
+			}
+			start = i
+		case c == '[' && start >= 0:
+			try(start, i)
+			start = -1
+		case c == '}' && start >= 0:
+			try(start, i+1)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		try(start, len(data))
+	}
+	return stored, dropped
+}
+
+// RecoveryMode define como recuperar um contacts.json corrompido.
+type RecoveryMode string
+
+const (
+	// RecoveryNone só relata os arquivos corrompidos.
+	RecoveryNone RecoveryMode = "none"
+	// RecoveryBackup volta ao backup mais novo que se lê inteiro e reaplica
+	// o journal sobre ele.
+	RecoveryBackup RecoveryMode = "backup"
+	// RecoverySalvage aproveita, do próprio arquivo corrompido, cada
+	// contato que ainda se lê.
+	RecoverySalvage RecoveryMode = "salvage"
+)
+
+// RecoveryModeFromEnv lê STORAGE_RECOVERY, o modo usado na partida. O
+// padrão é RecoveryBackup.
+func RecoveryModeFromEnv() (RecoveryMode, error) {
+	value := os.Getenv("STORAGE_RECOVERY")
+	if value == "" {
+		return RecoveryBackup, nil
+	}
+	mode := RecoveryMode(value)
+	if mode != RecoveryNone && mode != RecoveryBackup && mode != RecoverySalvage {
+		return "", fmt.Errorf("STORAGE_RECOVERY: %w: %q", ErrInvalidRecoveryMode, value)
+	}
+	return mode, nil
+}
+
+// RecoveryResult descreve a recuperação de um tenant. Source é de onde
+// vieram os contatos: "memory", quando o tenant já estava carregado, um
+// arquivo de backups/, "salvage", ou vazio quando contacts.json estava
+// íntegro e só o log ou o journal foram refeitos.
+type RecoveryResult struct {
+	TenantID    string       `json:"tenant_id" example:"acme"`
+	Mode        RecoveryMode `json:"mode" example:"backup"`
+	Source      string       `json:"source,omitempty" example:"backups/contacts-20250101T120000.000000000Z.json"`
+	Recovered   int          `json:"recovered" example:"41"`
+	Dropped     int          `json:"dropped" example:"0"`
+	Quarantined []string     `json:"quarantined" example:"quarantine/contacts.json.20250101T120500.000000000Z"`
+}
+
+// RecoverContacts refaz os arquivos de contatos corrompidos do tenant. Os
+// arquivos ruins vão para quarantine/ e o estado recuperado é gravado como
+// um novo snapshot. Se o tenant já está em memória, os contatos vêm dela,
+// sem perda. Senão, contacts.json vem do backup mais novo ou do próprio
+// arquivo, conforme mode; os registros ilegíveis do journal são
+// descartados, e um changes.json ilegível é refeito vazio, com os tokens de
+// sincronização anteriores expirados. Sem backup legível no modo
+// RecoveryBackup, devolve ErrNoGoodBackup sem mexer em nada.
+func RecoverContacts(ctx context.Context, tenantID string, mode RecoveryMode) (RecoveryResult, error) {
+	ctx, span := tracing.Start(ctx, "storage.RecoverContacts", attribute.String("tenant", tenantID), attribute.String("mode", string(mode)))
+	defer span.End()
+
+	result := RecoveryResult{TenantID: tenantID, Mode: mode, Quarantined: []string{}}
+	if mode != RecoveryBackup && mode != RecoverySalvage {
+		return result, tracing.Fail(span, ErrInvalidRecoveryMode)
+	}
+	report, err := CheckIntegrity(ctx, tenantID)
+	if err != nil || report.Healthy {
+		return result, tracing.Fail(span, err)
+	}
+	// Depois de começar, a recuperação vai até o fim.
+	ctx = context.WithoutCancel(ctx)
+
+	repositoriesMu.Lock()
+	repo := repositories[tenantID]
+	if repo != nil {
+		repositoriesMu.Unlock()
+		result, err = recoverFromMemory(ctx, repo, report, result)
+	} else {
+		// O registro fica travado para que nenhuma requisição leia o tenant
+		// no meio da recuperação.
+		result, err = recoverFromDisk(ctx, tenantID, report, result)
+		repositoriesMu.Unlock()
+	}
+	if err != nil {
+		return result, tracing.Fail(span, err)
+	}
+
+	slog.WarnContext(ctx, "contacts recovered", "tenant", tenantID, "mode", mode, "source", result.Source,
+		"recovered", result.Recovered, "dropped", result.Dropped, "quarantined", result.Quarantined)
+	return result, nil
+}
+
+func quarantineCorrupt(tenantID string, report IntegrityReport, result *RecoveryResult) error {
+	for _, file := range report.Files {
+		if file.Status != FileCorrupt {
+			continue
+		}
+		moved, err := quarantine(tenantID, file.File)
+		if err != nil {
+			return err
+		}
+		result.Quarantined = append(result.Quarantined, moved)
+	}
+	return nil
+}
+
+func recoverFromMemory(ctx context.Context, repo *contactRepository, report IntegrityReport, result RecoveryResult) (RecoveryResult, error) {
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+	if repo.deleted {
+		return result, nil
+	}
+
+	repo.mu.RLock()
+	state, version := repo.state, repo.version
+	repo.mu.RUnlock()
+	stored, err := sealContacts(currentKeyring(), state.contacts)
+	if err != nil {
+		return result, err
+	}
+	if err := quarantineCorrupt(repo.tenantID, report, &result); err != nil {
+		return result, err
+	}
+	sum, err := writeSnapshot(ctx, repo.tenantID, stored, state.changeLog)
+	if err != nil {
+		return result, err
+	}
+
+	repo.onDisk = state
+	repo.journalRecords = 0
+	repo.indexes = newDiskIndexes(stored)
+	repo.snapshot, repo.snapshotSum = stored, sum
+	repo.mu.Lock()
+	repo.persisted = max(repo.persisted, version)
+	repo.mu.Unlock()
+
+	result.Source = "memory"
+	result.Recovered = len(stored)
+	return result, nil
+}
+
+func recoverFromDisk(ctx context.Context, tenantID string, report IntegrityReport, result RecoveryResult) (RecoveryResult, error) {
+	path, err := tenantFile(tenantID, dataFile)
+	if err != nil {
+		return result, err
+	}
+
+	var stored []storedContact
+	switch {
+	case !report.corrupt(dataFile):
+		if stored, _, err = loadStoredContacts(ctx, tenantID); err != nil {
+			return result, err
+		}
+	case result.Mode == RecoveryBackup:
+		if result.Source, stored, err = latestGoodBackup(ctx, tenantID); err != nil {
+			return result, err
+		}
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return result, err
+		}
+		stored, result.Dropped = salvageContacts(ctx, tenantID, path, data)
+		result.Source = string(RecoverySalvage)
+	}
+
+	var changeLog ChangeLog
+	rebuilt := report.corrupt(changeLogFile)
+	if !rebuilt {
+		if changeLog, err = readChangeLog(ctx, tenantID); err != nil {
+			return result, err
+		}
+	}
+
+	journal, err := tenantFile(tenantID, journalFile)
+	if err != nil {
+		return result, err
+	}
+	data, err := os.ReadFile(journal)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+	snapshotSeq := changeLog.LastSeq
+	for _, line := range splitJournal(data) {
+		switch {
+		case line.err != nil:
+			result.Dropped++
+			slog.WarnContext(ctx, "journal record not recovered", "tenant", tenantID, "file", journal, "record", line.number, "error", line.err)
+		case line.record.LastSeq > snapshotSeq:
+			stored = line.record.apply(stored, &changeLog)
+		}
+	}
+	if rebuilt {
+		// Sem o log antigo, nenhuma sincronização incremental é confiável:
+		// os tokens emitidos até aqui expiram.
+		changeLog.Entries = nil
+		changeLog.PrunedSeq = changeLog.LastSeq
+	}
+
+	if err := quarantineCorrupt(tenantID, report, &result); err != nil {
+		return result, err
+	}
+	if _, err := writeSnapshot(ctx, tenantID, stored, changeLog); err != nil {
+		return result, err
+	}
+	result.Recovered = len(stored)
+	return result, nil
+}
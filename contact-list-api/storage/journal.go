@@ -0,0 +1,458 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/fieldcrypt"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// journalFile recebe, uma linha por gravação, as alterações feitas desde o
+// último snapshot, que é o par contacts.json e changes.json.
+const journalFile = "contacts.journal"
+
+var ErrJournalCorrupt = errors.New("contacts journal is corrupt")
+
+// errNeedsSnapshot indica uma alteração que o journal não representa, como
+// uma troca de ordem dos contatos; ela vai para um snapshot.
+var errNeedsSnapshot = errors.New("change needs a snapshot")
+
+// journalFrame é uma linha do journal. O CRC cobre os bytes exatos de
+// Record, para que uma linha cortada ou alterada não seja aplicada.
+type journalFrame struct {
+	CRC    uint32          `json:"crc"`
+	Record json.RawMessage `json:"record"`
+}
+
+// journalRecord leva os arquivos de um estado ao seguinte: os contatos
+// criados ou alterados, já cifrados, os removidos e as entradas novas do log
+// de alterações. LastSeq identifica o estado resultante; na leitura, os
+// registros já cobertos pelo snapshot são ignorados.
+type journalRecord struct {
+	LastSeq   int64           `json:"last_seq"`
+	PrunedSeq int64           `json:"pruned_seq,omitempty"`
+	LastID    int             `json:"last_id,omitempty"`
+	Puts      []storedContact `json:"puts,omitempty"`
+	Deletes   []int           `json:"deletes,omitempty"`
+	Changes   []ChangeEntry   `json:"changes"`
+}
+
+// newJournalRecord compara o estado gravado com o novo. Devolve nil quando
+// não há o que gravar e errNeedsSnapshot quando a ordem dos contatos mudou
+// de um jeito que acrescentar e remover não reproduz.
+func newJournalRecord(k *fieldcrypt.Keyring, from, to *contactState) (*journalRecord, error) {
+	record := &journalRecord{LastSeq: to.changeLog.LastSeq, PrunedSeq: to.changeLog.PrunedSeq, LastID: to.changeLog.LastID}
+
+	deleted := map[int]bool{}
+	for _, contact := range from.contacts {
+		if _, ok := to.byID[contact.ID]; !ok {
+			record.Deletes = append(record.Deletes, contact.ID)
+			deleted[contact.ID] = true
+		}
+	}
+
+	var order []int
+	for _, contact := range from.contacts {
+		if !deleted[contact.ID] {
+			order = append(order, contact.ID)
+		}
+	}
+	for _, contact := range to.contacts {
+		i, existed := from.byID[contact.ID]
+		if existed && from.contacts[i] == contact {
+			continue
+		}
+		if !existed {
+			order = append(order, contact.ID)
+		}
+		sealed, err := sealContact(k, contact)
+		if err != nil {
+			return nil, err
+		}
+		record.Puts = append(record.Puts, sealed)
+	}
+
+	if len(order) != len(to.contacts) {
+		return nil, errNeedsSnapshot
+	}
+	for i, id := range order {
+		if to.contacts[i].ID != id {
+			return nil, errNeedsSnapshot
+		}
+	}
+
+	for _, entry := range to.changeLog.Entries {
+		if entry.Seq > from.changeLog.LastSeq {
+			record.Changes = append(record.Changes, entry)
+		}
+	}
+
+	if len(record.Puts) == 0 && len(record.Deletes) == 0 && len(record.Changes) == 0 &&
+		record.PrunedSeq == from.changeLog.PrunedSeq {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// apply reproduz o registro sobre os contatos e o log lidos do disco.
+func (r journalRecord) apply(stored []storedContact, changeLog *ChangeLog) []storedContact {
+	deleted := make(map[int]bool, len(r.Deletes))
+	for _, id := range r.Deletes {
+		deleted[id] = true
+	}
+	puts := make(map[int]storedContact, len(r.Puts))
+	for _, s := range r.Puts {
+		puts[s.ID] = s
+	}
+
+	kept := make([]storedContact, 0, len(stored)+len(r.Puts))
+	for _, s := range stored {
+		if deleted[s.ID] {
+			continue
+		}
+		if put, ok := puts[s.ID]; ok {
+			s = put
+			delete(puts, s.ID)
+		}
+		kept = append(kept, s)
+	}
+	for _, s := range r.Puts {
+		if _, pending := puts[s.ID]; pending {
+			kept = append(kept, s)
+		}
+	}
+
+	if len(r.Changes) > 0 {
+		entries := make(map[int]ChangeEntry, len(changeLog.Entries)+len(r.Changes))
+		for _, entry := range changeLog.Entries {
+			entries[entry.ContactID] = entry
+		}
+		for _, entry := range r.Changes {
+			entries[entry.ContactID] = entry
+		}
+		changeLog.Entries = changeLog.Entries[:0]
+		for _, entry := range entries {
+			changeLog.Entries = append(changeLog.Entries, entry)
+		}
+		sort.Slice(changeLog.Entries, func(i, j int) bool {
+			return changeLog.Entries[i].Seq < changeLog.Entries[j].Seq
+		})
+		pruneTombstones(changeLog)
+	}
+	changeLog.LastSeq = max(changeLog.LastSeq, r.LastSeq)
+	changeLog.PrunedSeq = max(changeLog.PrunedSeq, r.PrunedSeq)
+	changeLog.LastID = max(changeLog.LastID, r.LastID)
+	return kept
+}
+
+// appendJournal acrescenta o registro ao journal do tenant e espera o fsync.
+// Se a escrita ou o fsync falham, o arquivo volta ao tamanho que tinha: um
+// pedaço de linha no fim seria tomado como corrupção quando o próximo
+// registro fosse acrescentado depois dele.
+func appendJournal(ctx context.Context, tenantID string, record journalRecord) error {
+	ctx, span := tracing.Start(ctx, "storage.appendJournal", attribute.Int("contacts.puts", len(record.Puts)), attribute.Int("contacts.deletes", len(record.Deletes)))
+	defer span.End()
+
+	line, err := encodeJournalLine(record)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	path, err := tenantFile(tenantID, journalFile)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	span.SetAttributes(attribute.Int("file.bytes", len(line)))
+
+	start := time.Now()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return tracing.Fail(span, err)
+	}
+	offset := info.Size()
+	_, err = file.Write(line)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "journal record not written", "tenant", tenantID, "file", path, "offset", offset, "error", err)
+		if truncErr := file.Truncate(offset); truncErr != nil {
+			slog.ErrorContext(ctx, "journal not truncated after failed write", "tenant", tenantID, "file", path, "offset", offset, "error", truncErr)
+			err = errors.Join(err, truncErr)
+		} else {
+			file.Sync()
+		}
+		file.Close()
+		return tracing.Fail(span, err)
+	}
+	if err := file.Close(); err != nil {
+		return tracing.Fail(span, err)
+	}
+	metrics.ObserveStorage(metrics.OperationAppend, len(line), time.Since(start))
+	return nil
+}
+
+// journalLine é uma linha do journal já conferida. number conta as linhas a
+// partir de 1 e offset é onde a linha começa no arquivo.
+type journalLine struct {
+	record journalRecord
+	number int
+	offset int
+	err    error
+}
+
+// splitJournal confere cada linha do journal. Uma linha sem quebra no fim
+// só pode ser a última, cortada por uma queda.
+func splitJournal(data []byte) []journalLine {
+	var lines []journalLine
+	for offset := 0; offset < len(data); {
+		line := journalLine{number: len(lines) + 1, offset: offset}
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			line.err = errors.New("record without line end")
+			lines = append(lines, line)
+			break
+		}
+		line.record, line.err = decodeJournalLine(data[offset : offset+end])
+		lines = append(lines, line)
+		offset += end + 1
+	}
+	return lines
+}
+
+// replayJournal aplica ao snapshot os registros do journal mais novos que
+// ele e devolve quantos registros o journal tem. Uma última linha
+// incompleta ou com CRC errado é o que sobra de uma gravação interrompida
+// por uma queda: é descartada e o arquivo, truncado antes dela. Uma linha
+// inválida seguida de outras é corrupção e devolve ErrJournalCorrupt.
+func replayJournal(ctx context.Context, tenantID string, stored []storedContact, changeLog *ChangeLog) ([]storedContact, int, error) {
+	ctx, span := tracing.Start(ctx, "storage.replayJournal")
+	defer span.End()
+
+	path, err := tenantFile(tenantID, journalFile)
+	if err != nil {
+		return nil, 0, tracing.Fail(span, err)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return stored, 0, nil
+	}
+	if err != nil {
+		return nil, 0, tracing.Fail(span, err)
+	}
+
+	snapshotSeq := changeLog.LastSeq
+	lines := splitJournal(data)
+	records, applied := 0, 0
+	for i, line := range lines {
+		if line.err != nil {
+			if i < len(lines)-1 {
+				err := &CorruptFileError{File: path, Line: line.number, Err: fmt.Errorf("%w: record %d: %v", ErrJournalCorrupt, line.number, line.err)}
+				return nil, 0, tracing.Fail(span, err)
+			}
+			slog.WarnContext(ctx, "journal torn record truncated", "tenant", tenantID, "file", path, "offset", line.offset, "dropped_bytes", len(data)-line.offset, "error", line.err)
+			if err := os.Truncate(path, int64(line.offset)); err != nil {
+				return nil, 0, tracing.Fail(span, err)
+			}
+			break
+		}
+
+		records++
+		if line.record.LastSeq > snapshotSeq {
+			stored = line.record.apply(stored, changeLog)
+			applied++
+		}
+	}
+
+	span.SetAttributes(attribute.Int("journal.records", records), attribute.Int("journal.applied", applied))
+	return stored, records, nil
+}
+
+// encodeJournalLine monta a linha do journal com o registro, o CRC e a
+// quebra de linha no fim.
+func encodeJournalLine(record journalRecord) ([]byte, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(journalFrame{CRC: crc32.ChecksumIEEE(body), Record: body})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func decodeJournalLine(line []byte) (journalRecord, error) {
+	var frame journalFrame
+	if err := json.Unmarshal(line, &frame); err != nil {
+		return journalRecord{}, err
+	}
+	if crc32.ChecksumIEEE(frame.Record) != frame.CRC {
+		return journalRecord{}, errors.New("checksum mismatch")
+	}
+	var record journalRecord
+	err := json.Unmarshal(frame.Record, &record)
+	return record, err
+}
+
+// storedState é o estado do tenant no disco: o snapshot com o journal
+// aplicado, os contatos ainda cifrados.
+type storedState struct {
+	contacts       []storedContact
+	changeLog      ChangeLog
+	journalRecords int
+	// snapshot e snapshotSum são o contacts.json lido, antes do journal,
+	// para reconhecer e aplicar as edições feitas fora do servidor.
+	snapshot    []storedContact
+	snapshotSum fileSum
+}
+
+// readStoredState lê o snapshot e aplica o journal.
+func readStoredState(ctx context.Context, tenantID string) (storedState, error) {
+	snapshot, sum, err := loadStoredContacts(ctx, tenantID)
+	if err != nil {
+		return storedState{}, err
+	}
+	changeLog, err := readChangeLog(ctx, tenantID)
+	if err != nil {
+		return storedState{}, err
+	}
+	stored, records, err := replayJournal(ctx, tenantID, snapshot, &changeLog)
+	if err != nil {
+		return storedState{}, err
+	}
+	changeLog.coverIDs(stored)
+	if records > 0 {
+		recordContactTotals(tenantID, stored)
+	}
+	return storedState{
+		contacts:       stored,
+		changeLog:      changeLog,
+		journalRecords: records,
+		snapshot:       snapshot,
+		snapshotSum:    sum,
+	}, nil
+}
+
+// writeSnapshot grava o estado completo e esvazia o journal. contacts.json
+// vem antes de changes.json: se o processo cair entre os dois, o journal
+// ainda não foi esvaziado e reaplica as alterações sobre os contatos novos,
+// o que devolve o log de alterações sem perder nada.
+func writeSnapshot(ctx context.Context, tenantID string, stored []storedContact, changeLog ChangeLog) (fileSum, error) {
+	ctx, span := tracing.Start(ctx, "storage.writeSnapshot")
+	defer span.End()
+
+	sum, err := writeStoredContacts(ctx, tenantID, stored)
+	if err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	if err := saveChangeLog(tenantID, changeLog); err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+
+	path, err := tenantFile(tenantID, journalFile)
+	if err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	if err := emptyJournal(path); err != nil {
+		return fileSum{}, tracing.Fail(span, err)
+	}
+	return sum, nil
+}
+
+// emptyJournal trunca o journal e faz o fsync, para que os registros antigos,
+// que podem ser de contatos já excluídos, não voltem depois de uma queda.
+func emptyJournal(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeFileAtomic grava em um arquivo temporário no mesmo diretório, faz o
+// fsync e o renomeia sobre path: uma queda deixa o arquivo antigo ou o novo,
+// nunca um pela metade.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func sealContacts(k *fieldcrypt.Keyring, contacts []models.Contact) ([]storedContact, error) {
+	stored := make([]storedContact, 0, len(contacts))
+	for _, contact := range contacts {
+		s, err := sealContact(k, contact)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, s)
+	}
+	return stored, nil
+}
+
+func openContacts(k *fieldcrypt.Keyring, stored []storedContact) ([]models.Contact, error) {
+	var contacts []models.Contact
+	for _, s := range stored {
+		contact, err := openContact(k, s)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
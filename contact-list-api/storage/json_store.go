@@ -1,45 +1,244 @@
 package storage
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
 )
 
 var (
 	_, b, _, _ = runtime.Caller(0)
 	basePath   = filepath.Join(filepath.Dir(b), "..", "data")
-	dataFile   = filepath.Join(basePath, "contacts.json")
+	tenantsDir = filepath.Join(basePath, "tenants")
 )
 
-var ErrFileNotFound = errors.New("file not found")
+const dataFile = "contacts.json"
 
-func LoadContacts() ([]models.Contact, error) {
-	var contacts []models.Contact
-	file, err := os.OpenFile(dataFile, os.O_RDONLY|os.O_CREATE, 0644)
+var (
+	ErrFileNotFound    = errors.New("file not found")
+	ErrContactNotFound = errors.New("contact not found")
+)
+
+// UseDataDir troca o diretório de dados e devolve o anterior, para que
+// testes gravem num diretório temporário em vez de data/. Os tenants em
+// memória são descartados sem gravar o que estiver pendente. Não deve ser
+// chamada com requisições em andamento.
+func UseDataDir(dir string) string {
+	repositoriesMu.RLock()
+	tenantIDs := make([]string, 0, len(repositories))
+	for tenantID := range repositories {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	repositoriesMu.RUnlock()
+	for _, tenantID := range tenantIDs {
+		dropRepository(tenantID)
+	}
+
+	previous := basePath
+	basePath = dir
+	tenantsDir = filepath.Join(dir, "tenants")
+	return previous
+}
+
+// tenantFile devolve o caminho de um arquivo do tenant, criando o diretório
+// dele se preciso. O tenant padrão usa data/ diretamente, como antes de
+// existirem tenants; os demais ficam em data/tenants/<id>/.
+func tenantFile(tenantID, name string) (string, error) {
+	if tenantID == models.DefaultTenantID {
+		return filepath.Join(basePath, name), nil
+	}
+
+	dir := filepath.Join(tenantsDir, tenantID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// DeleteTenantData apaga todos os arquivos de um tenant.
+func DeleteTenantData(ctx context.Context, tenantID string) error {
+	_, span := tracing.Start(ctx, "storage.DeleteTenantData")
+	defer span.End()
+
+	if tenantID == models.DefaultTenantID {
+		return errors.New("the default tenant cannot be deleted")
+	}
+	dropRepository(tenantID)
+	metrics.DeleteTenant(tenantID)
+	return os.RemoveAll(filepath.Join(tenantsDir, tenantID))
+}
+
+// LoadContacts devolve os contatos do tenant já decifrados. Só o primeiro
+// acesso ao tenant lê o arquivo; os demais vêm da memória.
+func LoadContacts(ctx context.Context, tenantID string) ([]models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "storage.LoadContacts")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo, err := contactRepo(ctx, tenantID)
 	if err != nil {
-		return contacts, err
+		return nil, err
+	}
+	return append([]models.Contact(nil), repo.current().contacts...), nil
+}
+
+// GetContact devolve o contato com o ID, pelo índice, sem percorrer a
+// lista.
+func GetContact(ctx context.Context, tenantID string, id int) (models.Contact, bool, error) {
+	ctx, span := tracing.Start(ctx, "storage.GetContact")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return models.Contact{}, false, err
+	}
+	repo, err := contactRepo(ctx, tenantID)
+	if err != nil {
+		return models.Contact{}, false, err
+	}
+	state := repo.current()
+	i, ok := state.byID[id]
+	if !ok {
+		return models.Contact{}, false, nil
 	}
-	defer file.Close()
+	return state.contacts[i], true, nil
+}
+
+// SaveContacts troca os contatos do tenant, registrando as diferenças no log
+// de alterações. Com DurabilitySync, grava as diferenças no journal antes de
+// retornar, cifrando e-mail e telefone quando a cifra está configurada; com
+// DurabilityAsync, a gravação fica para o laço de write-behind. Para alterar
+// um contato, use PutContact e DeleteContact: SaveContacts troca a lista
+// inteira e desfaz o que outra requisição gravou depois da leitura.
+func SaveContacts(ctx context.Context, tenantID string, contacts []models.Contact) error {
+	ctx, span := tracing.Start(ctx, "storage.SaveContacts")
+	defer span.End()
+
+	return updateContacts(ctx, tenantID, func(*contactState) ([]models.Contact, error) {
+		return append([]models.Contact(nil), contacts...), nil
+	})
+}
+
+// PutContact grava um contato partindo do estado atual, com as alterações
+// do tenant travadas, para que gravações simultâneas não se desfaçam. Com
+// id zero, cria um contato com o próximo ID do tenant; senão troca o
+// contato com esse ID, ou devolve ErrContactNotFound se ele não existe.
+// build recebe o contato atual, vazio na criação, e o total de contatos do
+// tenant, e devolve o contato a gravar ou um erro que cancela a gravação.
+func PutContact(ctx context.Context, tenantID string, id int, build func(existing models.Contact, total int) (models.Contact, error)) (models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "storage.PutContact")
+	defer span.End()
+
+	var put models.Contact
+	err := updateContacts(ctx, tenantID, func(state *contactState) ([]models.Contact, error) {
+		i, exists := state.byID[id]
+		if id != 0 && !exists {
+			return nil, ErrContactNotFound
+		}
+
+		var existing models.Contact
+		if exists {
+			existing = state.contacts[i]
+		}
+		contact, err := build(existing, len(state.contacts))
+		if err != nil {
+			return nil, err
+		}
 
-	byteValue, _ := io.ReadAll(file)
-	if len(byteValue) == 0 {
+		contacts := append([]models.Contact(nil), state.contacts...)
+		if exists {
+			contact.ID = id
+			contacts[i] = contact
+		} else {
+			contact.ID = nextContactID(state)
+			contacts = append(contacts, contact)
+		}
+		put = contact
 		return contacts, nil
+	})
+	if err != nil {
+		return models.Contact{}, err
+	}
+	return put, nil
+}
+
+// DeleteContact remove o contato com o ID partindo do estado atual, com as
+// alterações do tenant travadas, e devolve o contato removido. check recebe
+// o contato e pode recusar a remoção; sem o contato, devolve
+// ErrContactNotFound.
+func DeleteContact(ctx context.Context, tenantID string, id int, check func(existing models.Contact) error) (models.Contact, error) {
+	ctx, span := tracing.Start(ctx, "storage.DeleteContact")
+	defer span.End()
+
+	var deleted models.Contact
+	err := updateContacts(ctx, tenantID, func(state *contactState) ([]models.Contact, error) {
+		i, ok := state.byID[id]
+		if !ok {
+			return nil, ErrContactNotFound
+		}
+		deleted = state.contacts[i]
+		if check != nil {
+			if err := check(deleted); err != nil {
+				return nil, err
+			}
+		}
+
+		contacts := make([]models.Contact, 0, len(state.contacts)-1)
+		contacts = append(contacts, state.contacts[:i]...)
+		return append(contacts, state.contacts[i+1:]...), nil
+	})
+	if err != nil {
+		return models.Contact{}, err
 	}
+	return deleted, nil
+}
 
-	err = json.Unmarshal(byteValue, &contacts)
-	return contacts, err
+// nextContactID devolve o ID seguinte ao maior que o tenant já usou,
+// inclusive os de contatos excluídos.
+func nextContactID(state *contactState) int {
+	return state.changeLog.LastID + 1
 }
 
-func SaveContacts(contacts []models.Contact) error {
-	data, err := json.MarshalIndent(contacts, "", "  ")
+// updateContacts troca os contatos do tenant pelo que change devolve a
+// partir do estado atual. writeMu fica travado da leitura à troca, e um
+// erro de change cancela a alteração.
+func updateContacts(ctx context.Context, tenantID string, change func(state *contactState) ([]models.Contact, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo, err := lockRepo(ctx, tenantID)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(dataFile, data, 0644)
+	defer repo.writeMu.Unlock()
+
+	previous := repo.current()
+	contacts, err := change(previous)
+	if err != nil {
+		return err
+	}
+	changeLog := previous.changeLog
+	changeLog.Entries = append([]ChangeEntry(nil), changeLog.Entries...)
+	recordChanges(&changeLog, previous.contacts, contacts)
+	next := newContactState(contacts, changeLog)
+
+	if currentPersistence().Durability == DurabilityAsync {
+		repo.replace(next, false)
+		return nil
+	}
+
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+	if err := repo.persistLocked(ctx, next); err != nil {
+		return err
+	}
+	repo.replace(next, true)
+	return nil
 }
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const privacyRequestsFile = "privacy_requests.json"
+
+func LoadPrivacyRequests(ctx context.Context, tenantID string) ([]models.PrivacyRequest, error) {
+	_, span := tracing.Start(ctx, "storage.LoadPrivacyRequests")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var requests []models.PrivacyRequest
+	path, err := tenantFile(tenantID, privacyRequestsFile)
+	if err != nil {
+		return requests, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return requests, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return requests, err
+	}
+	if len(byteValue) == 0 {
+		return requests, nil
+	}
+
+	err = json.Unmarshal(byteValue, &requests)
+	return requests, err
+}
+
+func SavePrivacyRequests(ctx context.Context, tenantID string, requests []models.PrivacyRequest) error {
+	_, span := tracing.Start(ctx, "storage.SavePrivacyRequests")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(requests, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, privacyRequestsFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
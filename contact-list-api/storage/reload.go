@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidContactsFile = errors.New("contacts file edit rejected")
+	ErrWatchUnsupported    = errors.New("watching the contacts file is not supported on this platform")
+)
+
+// ContactChanges lista os contatos criados, alterados e removidos por uma
+// edição de contacts.json feita fora do servidor.
+type ContactChanges struct {
+	Created []models.Contact
+	Updated []models.Contact
+	Deleted []models.Contact
+}
+
+func (c ContactChanges) Empty() bool {
+	return len(c.Created) == 0 && len(c.Updated) == 0 && len(c.Deleted) == 0
+}
+
+// ReloadFunc recebe as alterações de cada edição aplicada.
+type ReloadFunc func(tenantID string, changes ContactChanges)
+
+// ReloadContacts aplica uma edição de contacts.json feita fora do servidor.
+// O arquivo guarda o último snapshot, e as alterações mais novas estão no
+// journal: o que entra nos contatos em memória é a diferença entre o
+// snapshot e o arquivo editado, para que uma edição não desfaça as
+// alterações que quem editou não via. Um arquivo inválido é rejeitado com
+// ErrInvalidContactsFile e os contatos em memória ficam como estavam. Um
+// arquivo igual ao último gravado pelo servidor, ou de um tenant que ainda
+// não foi lido, não muda nada.
+func ReloadContacts(ctx context.Context, tenantID string) (ContactChanges, error) {
+	ctx, span := tracing.Start(ctx, "storage.ReloadContacts", attribute.String("tenant", tenantID))
+	defer span.End()
+
+	repositoriesMu.Lock()
+	repo := repositories[tenantID]
+	repositoriesMu.Unlock()
+	if repo == nil {
+		return ContactChanges{}, nil
+	}
+
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+	if repo.deleted || repo.dropped {
+		return ContactChanges{}, nil
+	}
+	if err := repo.flushLocked(ctx); err != nil {
+		return ContactChanges{}, tracing.Fail(span, err)
+	}
+
+	path, err := tenantFile(tenantID, dataFile)
+	if err != nil {
+		return ContactChanges{}, tracing.Fail(span, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContactChanges{}, tracing.Fail(span, err)
+	}
+	sum := fileSum(sha256.Sum256(data))
+	if sum == repo.snapshotSum {
+		return ContactChanges{}, nil
+	}
+
+	previous := repo.current()
+	edited, next, err := mergeEdit(repo, data, previous)
+	if err != nil {
+		// A soma é guardada mesmo assim, para que o mesmo conteúdo não seja
+		// examinado e relatado de novo.
+		repo.snapshotSum = sum
+		metrics.ObserveReload(metrics.ReloadRejected)
+		slog.ErrorContext(ctx, "contacts file edit rejected", "tenant", tenantID, "file", path, "error", err)
+		return ContactChanges{}, tracing.Fail(span, fmt.Errorf("%w: %v", ErrInvalidContactsFile, err))
+	}
+
+	changes := diffContacts(previous.contacts, next)
+	if changes.Empty() {
+		repo.snapshot, repo.snapshotSum = edited, sum
+		metrics.ObserveReload(metrics.ReloadUnchanged)
+		return changes, nil
+	}
+
+	changeLog := previous.changeLog
+	changeLog.Entries = slices.Clone(changeLog.Entries)
+	recordChanges(&changeLog, previous.contacts, next)
+	state := newContactState(next, changeLog)
+	snapshotBefore := repo.snapshotSum
+	if err := repo.persistLocked(ctx, state); err != nil {
+		return ContactChanges{}, tracing.Fail(span, err)
+	}
+	// Se a gravação não trocou o snapshot, contacts.json continua com a
+	// edição, que passa a ser a base das próximas.
+	if repo.snapshotSum == snapshotBefore {
+		repo.snapshot, repo.snapshotSum = edited, sum
+	}
+	repo.replace(state, true)
+
+	metrics.ObserveReload(metrics.ReloadApplied)
+	slog.InfoContext(ctx, "contacts file reloaded", "tenant", tenantID, "file", path,
+		"created", len(changes.Created), "updated", len(changes.Updated), "deleted", len(changes.Deleted))
+	return changes, nil
+}
+
+// mergeEdit valida o arquivo editado e aplica aos contatos atuais o que
+// mudou em relação ao último snapshot. Devolve o arquivo decodificado e a
+// nova lista. Contatos criados não podem usar um ID que o tenant já usou.
+// Quem chama segura flushMu.
+func mergeEdit(repo *contactRepository, data []byte, current *contactState) ([]storedContact, []models.Contact, error) {
+	path, err := tenantFile(repo.tenantID, dataFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	edited, err := decodeEditedContacts(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	k := currentKeyring()
+	base, err := openContacts(k, repo.snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	opened, err := openContacts(k, edited)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	edit := diffContacts(base, opened)
+	next := slices.Clone(current.contacts)
+	positions := make(map[int]int, len(next))
+	for i, contact := range next {
+		positions[contact.ID] = i
+	}
+	for _, contact := range edit.Created {
+		if i, ok := positions[contact.ID]; ok {
+			if next[i] != contact {
+				return nil, nil, fmt.Errorf("contact id %d already exists", contact.ID)
+			}
+			continue
+		}
+		if contact.ID <= current.changeLog.LastID {
+			return nil, nil, fmt.Errorf("contact id %d was already used", contact.ID)
+		}
+		positions[contact.ID] = len(next)
+		next = append(next, contact)
+	}
+	for _, contact := range edit.Updated {
+		if i, ok := positions[contact.ID]; ok {
+			next[i] = contact
+			continue
+		}
+		positions[contact.ID] = len(next)
+		next = append(next, contact)
+	}
+	deleted := make(map[int]bool, len(edit.Deleted))
+	for _, contact := range edit.Deleted {
+		deleted[contact.ID] = true
+	}
+	next = slices.DeleteFunc(next, func(contact models.Contact) bool {
+		return deleted[contact.ID]
+	})
+	return edited, next, nil
+}
+
+// decodeEditedContacts é mais rigorosa que a leitura na partida: recusa
+// arquivo vazio, campos desconhecidos, dados depois da lista e IDs
+// inválidos ou repetidos, erros comuns numa edição manual.
+func decodeEditedContacts(path string, data []byte) ([]storedContact, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var stored []storedContact
+	if err := decoder.Decode(&stored); err != nil {
+		return nil, corruptJSON(path, data, err)
+	}
+	end := decoder.InputOffset()
+	if err := decoder.Decode(&json.RawMessage{}); err != io.EOF {
+		extra := end + int64(len(data[end:])-len(bytes.TrimLeft(data[end:], " \t\r\n")))
+		line, column := position(data, extra+1)
+		return nil, &CorruptFileError{File: path, Line: line, Column: column, Err: errors.New("unexpected data after the contact list")}
+	}
+	if err := validateStoredContacts(stored); err != nil {
+		return nil, &CorruptFileError{File: path, Err: err}
+	}
+	return stored, nil
+}
+
+// diffContacts compara duas listas pelo ID.
+func diffContacts(previous, current []models.Contact) ContactChanges {
+	before := make(map[int]models.Contact, len(previous))
+	for _, contact := range previous {
+		before[contact.ID] = contact
+	}
+
+	var changes ContactChanges
+	seen := make(map[int]bool, len(current))
+	for _, contact := range current {
+		seen[contact.ID] = true
+		old, ok := before[contact.ID]
+		switch {
+		case !ok:
+			changes.Created = append(changes.Created, contact)
+		case old != contact:
+			changes.Updated = append(changes.Updated, contact)
+		}
+	}
+	for _, contact := range previous {
+		if !seen[contact.ID] {
+			changes.Deleted = append(changes.Deleted, contact)
+		}
+	}
+	return changes
+}
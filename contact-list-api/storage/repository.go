@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Durability define quando uma alteração de contatos chega ao disco.
+type Durability string
+
+const (
+	// DurabilitySync acrescenta a alteração ao journal, com fsync, antes de
+	// SaveContacts retornar.
+	DurabilitySync Durability = "sync"
+	// DurabilityAsync devolve assim que a alteração está na memória. O laço
+	// de write-behind grava os tenants alterados a cada FlushInterval, numa
+	// escrita por tenant para todas as alterações do intervalo; uma queda
+	// perde no máximo esse intervalo.
+	DurabilityAsync Durability = "async"
+)
+
+var ErrInvalidDurability = errors.New("invalid storage durability, expected sync or async")
+
+// PersistenceConfig define como o repositório de contatos grava no disco.
+// Cada gravação acrescenta um registro ao journal; quando ele chega a
+// SnapshotEvery registros, a gravação seguinte escreve um snapshot e o
+// esvazia.
+type PersistenceConfig struct {
+	Durability    Durability
+	FlushInterval time.Duration
+	SnapshotEvery int
+}
+
+// DefaultPersistenceConfig é usada quando as variáveis de ambiente não são
+// definidas. O padrão é DurabilitySync: uma alteração confirmada já está no
+// disco; DurabilityAsync troca isso por velocidade e precisa ser pedido.
+func DefaultPersistenceConfig() PersistenceConfig {
+	return PersistenceConfig{Durability: DurabilitySync, FlushInterval: time.Second, SnapshotEvery: 1000}
+}
+
+// PersistenceConfigFromEnv parte de DefaultPersistenceConfig e aplica
+// STORAGE_DURABILITY ("sync" ou "async"), STORAGE_FLUSH_INTERVAL ("1s") e
+// STORAGE_SNAPSHOT_EVERY.
+func PersistenceConfigFromEnv() (PersistenceConfig, error) {
+	c := DefaultPersistenceConfig()
+
+	if value := os.Getenv("STORAGE_DURABILITY"); value != "" {
+		durability := Durability(value)
+		if durability != DurabilitySync && durability != DurabilityAsync {
+			return PersistenceConfig{}, fmt.Errorf("STORAGE_DURABILITY: %w: %q", ErrInvalidDurability, value)
+		}
+		c.Durability = durability
+	}
+
+	if value := os.Getenv("STORAGE_FLUSH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return PersistenceConfig{}, fmt.Errorf("STORAGE_FLUSH_INTERVAL: invalid duration %q", value)
+		}
+		c.FlushInterval = interval
+	}
+
+	if value := os.Getenv("STORAGE_SNAPSHOT_EVERY"); value != "" {
+		every, err := strconv.Atoi(value)
+		if err != nil || every < 1 {
+			return PersistenceConfig{}, fmt.Errorf("STORAGE_SNAPSHOT_EVERY: invalid value %q", value)
+		}
+		c.SnapshotEvery = every
+	}
+
+	return c, nil
+}
+
+var (
+	persistenceMu sync.Mutex
+	persistence   = DefaultPersistenceConfig()
+	stopFlusher   chan struct{}
+)
+
+// ConfigurePersistence troca o modo de gravação. Sem configuração, o que
+// acontece até main configurar um, vale DefaultPersistenceConfig. No modo
+// assíncrono, inicia o laço de write-behind; ao sair dele, grava o que
+// estava pendente.
+func ConfigurePersistence(c PersistenceConfig) {
+	defaults := DefaultPersistenceConfig()
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaults.FlushInterval
+	}
+	if c.SnapshotEvery <= 0 {
+		c.SnapshotEvery = defaults.SnapshotEvery
+	}
+
+	persistenceMu.Lock()
+	wasAsync := stopFlusher != nil
+	if wasAsync {
+		close(stopFlusher)
+		stopFlusher = nil
+	}
+	persistence = c
+	if c.Durability == DurabilityAsync {
+		stopFlusher = make(chan struct{})
+		go runFlusher(c.FlushInterval, stopFlusher)
+	}
+	persistenceMu.Unlock()
+
+	if wasAsync && c.Durability == DurabilitySync {
+		if err := flushRepositories(context.Background()); err != nil {
+			slog.Error("contacts not flushed", "error", err)
+		}
+	}
+}
+
+func currentPersistence() PersistenceConfig {
+	persistenceMu.Lock()
+	defer persistenceMu.Unlock()
+	return persistence
+}
+
+func runFlusher(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := flushRepositories(context.Background()); err != nil {
+				slog.Error("contacts not flushed", "error", err)
+			}
+		}
+	}
+}
+
+// contactState é uma versão imutável dos contatos de um tenant, com os
+// índices e o log de alterações. Cada alteração cria uma nova; quem leu a
+// anterior continua com ela, sem travar as gravações.
+type contactState struct {
+	contacts []models.Contact
+	// Os índices guardam posições em contacts, na ordem do arquivo.
+	byID      map[int]int
+	byEmail   map[string][]int
+	byPhone   map[string][]int
+	changeLog ChangeLog
+}
+
+func newContactState(contacts []models.Contact, changeLog ChangeLog) *contactState {
+	s := &contactState{
+		contacts:  contacts,
+		byID:      make(map[int]int, len(contacts)),
+		byEmail:   make(map[string][]int, len(contacts)),
+		byPhone:   make(map[string][]int, len(contacts)),
+		changeLog: changeLog,
+	}
+	for i, contact := range contacts {
+		s.byID[contact.ID] = i
+		if email := NormalizeEmail(contact.Email); email != "" {
+			s.byEmail[email] = append(s.byEmail[email], i)
+		}
+		if phone := NormalizePhone(contact.Phone); phone != "" {
+			s.byPhone[phone] = append(s.byPhone[phone], i)
+		}
+	}
+	return s
+}
+
+func (s *contactState) at(positions []int) []models.Contact {
+	result := make([]models.Contact, 0, len(positions))
+	for _, i := range positions {
+		result = append(result, s.contacts[i])
+	}
+	return result
+}
+
+// contactRepository guarda em memória os contatos de um tenant. As leituras
+// não tocam o disco; as alterações trocam o estado e são gravadas conforme a
+// Durability configurada.
+type contactRepository struct {
+	tenantID string
+
+	// mu protege state e as versões; as leituras só o seguram para pegar o
+	// estado atual.
+	mu        sync.RWMutex
+	state     *contactState
+	version   uint64
+	persisted uint64
+
+	// writeMu serializa as alterações, que partem sempre do estado atual.
+	// dropped, protegido por ele, marca o repositório que saiu da memória
+	// com dropCleanRepositories; quem o travou depois pega o novo.
+	writeMu sync.Mutex
+	dropped bool
+	// flushMu serializa as gravações no disco e protege os campos abaixo:
+	// onDisk é o estado que snapshot e journal representam, indexes diz
+	// quais contatos gravados têm índice cego, para as métricas, e snapshot
+	// e snapshotSum guardam o último contacts.json gravado ou examinado.
+	flushMu        sync.Mutex
+	deleted        bool
+	onDisk         *contactState
+	journalRecords int
+	indexes        diskIndexes
+	snapshot       []storedContact
+	snapshotSum    fileSum
+}
+
+func (r *contactRepository) current() *contactState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+func (r *contactRepository) replace(state *contactState, persisted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+	r.version++
+	if persisted {
+		r.persisted = r.version
+	}
+}
+
+// flushLocked grava o estado atual se ele ainda não está no disco. Quem
+// chama segura flushMu.
+func (r *contactRepository) flushLocked(ctx context.Context) error {
+	r.mu.RLock()
+	state, version, persisted := r.state, r.version, r.persisted
+	r.mu.RUnlock()
+	if version == persisted {
+		return nil
+	}
+
+	if err := r.persistLocked(ctx, state); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.persisted = max(r.persisted, version)
+	r.mu.Unlock()
+	return nil
+}
+
+// persistLocked leva os arquivos do tenant de onDisk a state: acrescenta um
+// registro ao journal ou, quando ele já tem SnapshotEvery registros ou a
+// alteração não cabe num registro, grava um snapshot. Depois de começar, vai
+// até o fim mesmo que ctx acabe. Quem chama segura flushMu.
+func (r *contactRepository) persistLocked(ctx context.Context, state *contactState) error {
+	if r.deleted {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "storage.flushContacts", attribute.String("tenant", r.tenantID))
+	defer span.End()
+
+	k := currentKeyring()
+	record, err := newJournalRecord(k, r.onDisk, state)
+	switch {
+	case errors.Is(err, errNeedsSnapshot) || (err == nil && record != nil && r.journalRecords >= currentPersistence().SnapshotEvery):
+		stored, err := sealContacts(k, state.contacts)
+		if err != nil {
+			return tracing.Fail(span, err)
+		}
+		sum, err := writeSnapshot(ctx, r.tenantID, stored, state.changeLog)
+		if err != nil {
+			return tracing.Fail(span, fmt.Errorf("tenant %s: %w", r.tenantID, err))
+		}
+		r.snapshot, r.snapshotSum = stored, sum
+		r.journalRecords = 0
+		r.indexes = newDiskIndexes(stored)
+	case err != nil:
+		return tracing.Fail(span, err)
+	case record == nil:
+	default:
+		if err := appendJournal(ctx, r.tenantID, *record); err != nil {
+			// Se o journal não voltou ao tamanho anterior, a próxima gravação
+			// é um snapshot, que o esvazia.
+			r.journalRecords = currentPersistence().SnapshotEvery
+			return tracing.Fail(span, fmt.Errorf("tenant %s: %w", r.tenantID, err))
+		}
+		r.journalRecords++
+		r.indexes.apply(*record)
+		r.indexes.report(r.tenantID)
+	}
+
+	r.onDisk = state
+	return nil
+}
+
+// compactLocked grava os contatos pendentes e um snapshot do estado em
+// disco, o que esvazia o journal. Depois de uma exclusão, tira do journal os
+// registros antigos do contato. Quem chama segura flushMu.
+func (r *contactRepository) compactLocked(ctx context.Context) error {
+	if err := r.flushLocked(ctx); err != nil {
+		return err
+	}
+	if r.deleted {
+		return nil
+	}
+	stored, err := sealContacts(currentKeyring(), r.onDisk.contacts)
+	if err != nil {
+		return err
+	}
+	sum, err := writeSnapshot(ctx, r.tenantID, stored, r.onDisk.changeLog)
+	if err != nil {
+		return fmt.Errorf("tenant %s: %w", r.tenantID, err)
+	}
+	r.snapshot, r.snapshotSum = stored, sum
+	r.journalRecords = 0
+	r.indexes = newDiskIndexes(stored)
+	return nil
+}
+
+// diskIndexes diz, para cada contato gravado, se ele tem índice cego de
+// e-mail e de telefone. Mantém as métricas em dia a cada registro do
+// journal sem reler o snapshot.
+type diskIndexes map[int]uint8
+
+const (
+	hasEmailIndex uint8 = 1 << iota
+	hasPhoneIndex
+)
+
+func newDiskIndexes(stored []storedContact) diskIndexes {
+	d := make(diskIndexes, len(stored))
+	for _, s := range stored {
+		d.set(s)
+	}
+	return d
+}
+
+func (d diskIndexes) set(s storedContact) {
+	var flags uint8
+	if s.EmailIndex != "" {
+		flags |= hasEmailIndex
+	}
+	if s.PhoneIndex != "" {
+		flags |= hasPhoneIndex
+	}
+	d[s.ID] = flags
+}
+
+func (d diskIndexes) apply(record journalRecord) {
+	for _, id := range record.Deletes {
+		delete(d, id)
+	}
+	for _, s := range record.Puts {
+		d.set(s)
+	}
+}
+
+func (d diskIndexes) report(tenantID string) {
+	emailIndexed, phoneIndexed := 0, 0
+	for _, flags := range d {
+		if flags&hasEmailIndex != 0 {
+			emailIndexed++
+		}
+		if flags&hasPhoneIndex != 0 {
+			phoneIndexed++
+		}
+	}
+	metrics.SetContactTotals(tenantID, len(d), emailIndexed, phoneIndexed)
+}
+
+var (
+	repositoriesMu sync.RWMutex
+	repositories   = map[string]*contactRepository{}
+)
+
+// contactRepo devolve o repositório do tenant, lendo os arquivos na primeira
+// vez. Uma leitura que falha não fica guardada: a próxima tenta de novo.
+func contactRepo(ctx context.Context, tenantID string) (*contactRepository, error) {
+	repositoriesMu.RLock()
+	repo, ok := repositories[tenantID]
+	repositoriesMu.RUnlock()
+	if ok {
+		return repo, nil
+	}
+
+	repositoriesMu.Lock()
+	defer repositoriesMu.Unlock()
+	if repo, ok := repositories[tenantID]; ok {
+		return repo, nil
+	}
+
+	stored, err := readStoredState(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := openContacts(currentKeyring(), stored.contacts)
+	if err != nil {
+		return nil, err
+	}
+
+	state := newContactState(contacts, stored.changeLog)
+	repo = &contactRepository{
+		tenantID:       tenantID,
+		state:          state,
+		onDisk:         state,
+		journalRecords: stored.journalRecords,
+		indexes:        newDiskIndexes(stored.contacts),
+		snapshot:       stored.snapshot,
+		snapshotSum:    stored.snapshotSum,
+	}
+	repositories[tenantID] = repo
+	return repo, nil
+}
+
+// lockRepo devolve o repositório do tenant com writeMu travado. Se ele saiu
+// da memória enquanto se esperava a trava, a alteração iria para um
+// repositório que ninguém mais lê nem grava: a busca recomeça.
+func lockRepo(ctx context.Context, tenantID string) (*contactRepository, error) {
+	for {
+		repo, err := contactRepo(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		repo.writeMu.Lock()
+		if !repo.dropped {
+			return repo, nil
+		}
+		repo.writeMu.Unlock()
+	}
+}
+
+// ReopenTenant grava o que estiver pendente no tenant e o tira da memória: o
+// próximo acesso lê snapshot e journal do disco, como depois de um
+// reinício.
+func ReopenTenant(ctx context.Context, tenantID string) error {
+	repositoriesMu.RLock()
+	repo, ok := repositories[tenantID]
+	repositoriesMu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	repo.flushMu.Lock()
+	defer repo.flushMu.Unlock()
+	if err := repo.flushLocked(ctx); err != nil {
+		return err
+	}
+
+	repositoriesMu.Lock()
+	if repositories[tenantID] == repo {
+		delete(repositories, tenantID)
+	}
+	repositoriesMu.Unlock()
+	repo.dropped = true
+	return nil
+}
+
+// flushRepositories grava os tenants com alterações pendentes.
+func flushRepositories(ctx context.Context) error {
+	repositoriesMu.RLock()
+	repos := make([]*contactRepository, 0, len(repositories))
+	for _, repo := range repositories {
+		repos = append(repos, repo)
+	}
+	repositoriesMu.RUnlock()
+
+	var errs []error
+	for _, repo := range repos {
+		repo.flushMu.Lock()
+		errs = append(errs, repo.flushLocked(ctx))
+		repo.flushMu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// dropRepository tira o tenant da memória sem gravar o que estiver
+// pendente, para quando os arquivos dele vão ser apagados.
+func dropRepository(tenantID string) {
+	repositoriesMu.Lock()
+	repo, ok := repositories[tenantID]
+	delete(repositories, tenantID)
+	repositoriesMu.Unlock()
+
+	if ok {
+		repo.flushMu.Lock()
+		repo.deleted = true
+		repo.flushMu.Unlock()
+	}
+}
+
+// dropCleanRepositories tira da memória os tenants sem alterações
+// pendentes, que voltam a ser lidos do disco no próximo acesso. Os
+// pendentes continuam e são gravados com a chave atual. Cada repositório é
+// conferido com writeMu e flushMu travados, para que nenhuma alteração
+// chegue entre a conferência e a saída.
+func dropCleanRepositories() {
+	repositoriesMu.RLock()
+	repos := make([]*contactRepository, 0, len(repositories))
+	for _, repo := range repositories {
+		repos = append(repos, repo)
+	}
+	repositoriesMu.RUnlock()
+
+	for _, repo := range repos {
+		repo.writeMu.Lock()
+		repo.flushMu.Lock()
+		repo.mu.RLock()
+		clean := repo.version == repo.persisted
+		repo.mu.RUnlock()
+		if clean {
+			repositoriesMu.Lock()
+			if repositories[repo.tenantID] == repo {
+				delete(repositories, repo.tenantID)
+			}
+			repositoriesMu.Unlock()
+			repo.dropped = true
+		}
+		repo.flushMu.Unlock()
+		repo.writeMu.Unlock()
+	}
+}
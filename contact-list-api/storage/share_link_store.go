@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const shareLinksFile = "share_links.json"
+
+func LoadShareLinks(ctx context.Context, tenantID string) ([]models.ShareLink, error) {
+	_, span := tracing.Start(ctx, "storage.LoadShareLinks")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var links []models.ShareLink
+	path, err := tenantFile(tenantID, shareLinksFile)
+	if err != nil {
+		return links, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return links, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return links, err
+	}
+	if len(byteValue) == 0 {
+		return links, nil
+	}
+
+	err = json.Unmarshal(byteValue, &links)
+	return links, err
+}
+
+func SaveShareLinks(ctx context.Context, tenantID string, links []models.ShareLink) error {
+	_, span := tracing.Start(ctx, "storage.SaveShareLinks")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, shareLinksFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// shareLinkCounterFile guarda o último ID de link emitido no tenant. Os
+// links revogados saem de share_links.json, e sem o contador o ID do último
+// voltaria a ser usado.
+const shareLinkCounterFile = "share_link_seq.json"
+
+type shareLinkCounter struct {
+	LastID int `json:"last_id"`
+}
+
+// LoadShareLinkCounter devolve o último ID de link emitido no tenant, zero
+// se nenhum foi emitido.
+func LoadShareLinkCounter(ctx context.Context, tenantID string) (int, error) {
+	_, span := tracing.Start(ctx, "storage.LoadShareLinkCounter")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	path, err := tenantFile(tenantID, shareLinkCounterFile)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var counter shareLinkCounter
+	err = json.Unmarshal(data, &counter)
+	return counter.LastID, err
+}
+
+// SaveShareLinkCounter grava o último ID de link emitido, de forma atômica.
+func SaveShareLinkCounter(ctx context.Context, tenantID string, lastID int) error {
+	_, span := tracing.Start(ctx, "storage.SaveShareLinkCounter")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(shareLinkCounter{LastID: lastID})
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, shareLinkCounterFile)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const sharesFile = "shares.json"
+
+func LoadShares(ctx context.Context, tenantID string) ([]models.Share, error) {
+	_, span := tracing.Start(ctx, "storage.LoadShares")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var shares []models.Share
+	path, err := tenantFile(tenantID, sharesFile)
+	if err != nil {
+		return shares, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return shares, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return shares, err
+	}
+	if len(byteValue) == 0 {
+		return shares, nil
+	}
+
+	err = json.Unmarshal(byteValue, &shares)
+	return shares, err
+}
+
+func SaveShares(ctx context.Context, tenantID string, shares []models.Share) error {
+	_, span := tracing.Start(ctx, "storage.SaveShares")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, sharesFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
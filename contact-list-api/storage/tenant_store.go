@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// O cadastro de tenants é global e fica fora dos diretórios dos tenants.
+const tenantsFile = "tenants.json"
+
+func LoadTenants(ctx context.Context) ([]models.Tenant, error) {
+	_, span := tracing.Start(ctx, "storage.LoadTenants")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var tenants []models.Tenant
+	file, err := os.OpenFile(filepath.Join(basePath, tenantsFile), os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return tenants, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return tenants, err
+	}
+	if len(byteValue) == 0 {
+		return tenants, nil
+	}
+
+	err = json.Unmarshal(byteValue, &tenants)
+	return tenants, err
+}
+
+func SaveTenants(ctx context.Context, tenants []models.Tenant) error {
+	_, span := tracing.Start(ctx, "storage.SaveTenants")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(tenants, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(basePath, tenantsFile), data, 0644)
+}
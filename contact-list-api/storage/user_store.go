@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const (
+	usersFile    = "users.json"
+	sessionsFile = "sessions.json"
+)
+
+func LoadUsers(ctx context.Context, tenantID string) ([]models.User, error) {
+	_, span := tracing.Start(ctx, "storage.LoadUsers")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	path, err := tenantFile(tenantID, usersFile)
+	if err != nil {
+		return users, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return users, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return users, err
+	}
+	if len(byteValue) == 0 {
+		return users, nil
+	}
+
+	err = json.Unmarshal(byteValue, &users)
+	return users, err
+}
+
+func SaveUsers(ctx context.Context, tenantID string, users []models.User) error {
+	_, span := tracing.Start(ctx, "storage.SaveUsers")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, usersFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func LoadSessions(ctx context.Context, tenantID string) ([]models.Session, error) {
+	_, span := tracing.Start(ctx, "storage.LoadSessions")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	path, err := tenantFile(tenantID, sessionsFile)
+	if err != nil {
+		return sessions, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return sessions, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return sessions, err
+	}
+	if len(byteValue) == 0 {
+		return sessions, nil
+	}
+
+	err = json.Unmarshal(byteValue, &sessions)
+	return sessions, err
+}
+
+func SaveSessions(ctx context.Context, tenantID string, sessions []models.Session) error {
+	_, span := tracing.Start(ctx, "storage.SaveSessions")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := tenantFile(tenantID, sessionsFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
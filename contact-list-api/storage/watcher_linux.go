@@ -0,0 +1,161 @@
+//go:build linux
+
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// watchDebounce junta os eventos de uma mesma gravação: editores costumam
+// truncar, escrever e renomear em sequência.
+const watchDebounce = 100 * time.Millisecond
+
+// ContactWatcher observa, com inotify, o contacts.json dos tenants e aplica
+// as edições com ReloadContacts.
+type ContactWatcher struct {
+	file     *os.File
+	tenants  map[int32]string
+	onReload ReloadFunc
+	done     chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	timers   map[string]*time.Timer
+	inFlight sync.WaitGroup
+}
+
+// WatchContacts passa a observar o diretório de cada tenant. Cada edição
+// aplicada que muda contatos chega a onReload; as gravações do próprio
+// servidor são reconhecidas e ignoradas.
+func WatchContacts(onReload ReloadFunc, tenantIDs ...string) (*ContactWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	// Com o descritor não bloqueante, a leitura usa o poller do runtime e
+	// Close a interrompe.
+	w := &ContactWatcher{
+		file:     os.NewFile(uintptr(fd), "inotify"),
+		tenants:  make(map[int32]string, len(tenantIDs)),
+		onReload: onReload,
+		done:     make(chan struct{}),
+		timers:   make(map[string]*time.Timer),
+	}
+
+	for _, tenantID := range tenantIDs {
+		path, err := tenantFile(tenantID, dataFile)
+		if err != nil {
+			w.file.Close()
+			return nil, err
+		}
+		// O diretório, e não o arquivo, porque editores costumam gravar numa
+		// cópia e renomeá-la sobre o original.
+		dir := filepath.Dir(path)
+		wd, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO)
+		if err != nil {
+			w.file.Close()
+			return nil, fmt.Errorf("inotify %s: %w", dir, err)
+		}
+		w.tenants[int32(wd)] = tenantID
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *ContactWatcher) run() {
+	defer close(w.done)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				slog.Error("contacts file watch stopped", "error", err)
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			length := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+length]), "\x00")
+			offset = nameStart + length
+
+			switch {
+			case mask&syscall.IN_Q_OVERFLOW != 0:
+				// Eventos perdidos: todos os tenants são conferidos.
+				for _, tenantID := range w.tenants {
+					w.schedule(tenantID)
+				}
+			case name == dataFile:
+				if tenantID, ok := w.tenants[wd]; ok {
+					w.schedule(tenantID)
+				}
+			}
+		}
+	}
+}
+
+func (w *ContactWatcher) schedule(tenantID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if timer, ok := w.timers[tenantID]; ok {
+		timer.Reset(watchDebounce)
+		return
+	}
+	w.timers[tenantID] = time.AfterFunc(watchDebounce, func() {
+		w.reload(tenantID)
+	})
+}
+
+func (w *ContactWatcher) reload(tenantID string) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.inFlight.Add(1)
+	w.mu.Unlock()
+	defer w.inFlight.Done()
+
+	changes, err := ReloadContacts(context.Background(), tenantID)
+	switch {
+	case errors.Is(err, ErrInvalidContactsFile):
+		// Já relatado por ReloadContacts.
+	case err != nil:
+		slog.Error("contacts file not reloaded", "tenant", tenantID, "error", err)
+	case !changes.Empty() && w.onReload != nil:
+		w.onReload(tenantID, changes)
+	}
+}
+
+// Close para de observar e espera as releituras em andamento.
+func (w *ContactWatcher) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	for _, timer := range w.timers {
+		timer.Stop()
+	}
+	w.mu.Unlock()
+
+	err := w.file.Close()
+	<-w.done
+	w.inFlight.Wait()
+	return err
+}
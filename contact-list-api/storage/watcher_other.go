@@ -0,0 +1,16 @@
+//go:build !linux
+
+package storage
+
+// ContactWatcher só existe no Linux, onde há inotify.
+type ContactWatcher struct{}
+
+// WatchContacts devolve ErrWatchUnsupported fora do Linux; as edições de
+// contacts.json só são lidas na próxima partida.
+func WatchContacts(onReload ReloadFunc, tenantIDs ...string) (*ContactWatcher, error) {
+	return nil, ErrWatchUnsupported
+}
+
+func (w *ContactWatcher) Close() error {
+	return nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+// A fila de entregas é global, como o dispatcher, e cada entrega leva o
+// tenant.
+const webhookDeliveriesFile = "webhook_deliveries.json"
+
+// WebhookPayload é o evento de uma entrega ainda não concluída, guardado
+// para as novas tentativas e para o reenvio das mensagens mortas.
+type WebhookPayload struct {
+	EventID   int64
+	TenantID  string
+	Type      string
+	Contact   models.Contact
+	Timestamp time.Time
+}
+
+// WebhookQueue é o estado das entregas de webhooks: o log das mais
+// recentes, as mensagens mortas e os payloads das que ainda podem ser
+// enviadas, indexados pelo ID da entrega.
+type WebhookQueue struct {
+	NextID      int
+	Deliveries  []models.WebhookDelivery
+	DeadLetters []models.WebhookDelivery
+	Payloads    map[int]WebhookPayload
+}
+
+// Em disco, o contato do payload é cifrado como em contacts.json.
+type storedWebhookPayload struct {
+	EventID   int64         `json:"event_id"`
+	TenantID  string        `json:"tenant_id,omitempty"`
+	Type      string        `json:"type"`
+	Contact   storedContact `json:"contact"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+type storedWebhookQueue struct {
+	NextID      int                          `json:"next_id"`
+	Deliveries  []models.WebhookDelivery     `json:"deliveries"`
+	DeadLetters []models.WebhookDelivery     `json:"dead_letters"`
+	Payloads    map[int]storedWebhookPayload `json:"payloads"`
+}
+
+// LoadWebhookQueue lê a fila de entregas gravada. Sem arquivo, devolve a
+// fila vazia.
+func LoadWebhookQueue(ctx context.Context) (WebhookQueue, error) {
+	_, span := tracing.Start(ctx, "storage.LoadWebhookQueue")
+	defer span.End()
+
+	queue := WebhookQueue{NextID: 1, Payloads: map[int]WebhookPayload{}}
+	if err := ctx.Err(); err != nil {
+		return queue, tracing.Fail(span, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(basePath, webhookDeliveriesFile))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return queue, nil
+	}
+	if err != nil {
+		return queue, tracing.Fail(span, err)
+	}
+
+	var stored storedWebhookQueue
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return queue, tracing.Fail(span, err)
+	}
+	queue.NextID = max(stored.NextID, 1)
+	queue.Deliveries = stored.Deliveries
+	queue.DeadLetters = stored.DeadLetters
+
+	k := currentKeyring()
+	for id, payload := range stored.Payloads {
+		contact, err := openContact(k, payload.Contact)
+		if err != nil {
+			return queue, tracing.Fail(span, err)
+		}
+		queue.Payloads[id] = WebhookPayload{
+			EventID:   payload.EventID,
+			TenantID:  payload.TenantID,
+			Type:      payload.Type,
+			Contact:   contact,
+			Timestamp: payload.Timestamp,
+		}
+	}
+	return queue, nil
+}
+
+// SaveWebhookQueue grava a fila de entregas por inteiro, de forma atômica.
+func SaveWebhookQueue(ctx context.Context, queue WebhookQueue) error {
+	_, span := tracing.Start(ctx, "storage.SaveWebhookQueue")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	stored := storedWebhookQueue{
+		NextID:      queue.NextID,
+		Deliveries:  queue.Deliveries,
+		DeadLetters: queue.DeadLetters,
+		Payloads:    make(map[int]storedWebhookPayload, len(queue.Payloads)),
+	}
+	k := currentKeyring()
+	for id, payload := range queue.Payloads {
+		contact, err := sealContact(k, payload.Contact)
+		if err != nil {
+			return tracing.Fail(span, err)
+		}
+		stored.Payloads[id] = storedWebhookPayload{
+			EventID:   payload.EventID,
+			TenantID:  payload.TenantID,
+			Type:      payload.Type,
+			Contact:   contact,
+			Timestamp: payload.Timestamp,
+		}
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	return tracing.Fail(span, writeFileAtomic(filepath.Join(basePath, webhookDeliveriesFile), data, 0600))
+}
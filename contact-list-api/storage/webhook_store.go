@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+)
+
+const webhooksFile = "webhooks.json"
+
+func LoadWebhooks(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+	_, span := tracing.Start(ctx, "storage.LoadWebhooks")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var webhooks []models.Webhook
+	path, err := tenantFile(tenantID, webhooksFile)
+	if err != nil {
+		return webhooks, err
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return webhooks, err
+	}
+	defer file.Close()
+
+	byteValue, err := io.ReadAll(file)
+	if err != nil {
+		return webhooks, err
+	}
+	if len(byteValue) == 0 {
+		return webhooks, nil
+	}
+
+	err = json.Unmarshal(byteValue, &webhooks)
+	return webhooks, err
+}
+
+func SaveWebhooks(ctx context.Context, tenantID string, webhooks []models.Webhook) error {
+	_, span := tracing.Start(ctx, "storage.SaveWebhooks")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(webhooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	// O arquivo guarda os segredos de assinatura, por isso fica restrito ao dono.
+	path, err := tenantFile(tenantID, webhooksFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
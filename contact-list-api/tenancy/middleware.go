@@ -0,0 +1,84 @@
+package tenancy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+)
+
+// HeaderName é o cabeçalho que seleciona o tenant explicitamente.
+const HeaderName = "X-Tenant-ID"
+
+const tenantKey = "tenancy.tenant"
+
+var (
+	configMu   sync.RWMutex
+	baseDomain string
+)
+
+// Configure define o domínio base usado para resolver o tenant pelo
+// subdomínio: com "api.example.com", o host "acme.api.example.com" seleciona o
+// tenant "acme". Vazio desativa a resolução por subdomínio.
+func Configure(domain string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	baseDomain = strings.ToLower(strings.Trim(domain, "."))
+}
+
+// Resolve identifica o tenant da requisição pelo cabeçalho X-Tenant-ID ou,
+// na falta dele, pelo subdomínio. Sem nenhum dos dois, usa o tenant padrão.
+// Tenants não cadastrados resultam em 404.
+func Resolve() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := c.GetHeader(HeaderName)
+		if tenantID == "" {
+			tenantID = fromHost(c.Request.Host)
+		}
+
+		if tenantID != models.DefaultTenantID {
+			if _, err := services.GetTenant(c.Request.Context(), tenantID); err != nil {
+				if errors.Is(err, services.ErrTenantNotFound) {
+					c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.Set(tenantKey, tenantID)
+		c.Next()
+	}
+}
+
+// TenantID devolve o tenant resolvido para a requisição.
+func TenantID(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+func fromHost(host string) string {
+	configMu.RLock()
+	domain := baseDomain
+	configMu.RUnlock()
+	if domain == "" {
+		return models.DefaultTenantID
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	label, ok := strings.CutSuffix(host, "."+domain)
+	if !ok || strings.Contains(label, ".") {
+		return models.DefaultTenantID
+	}
+	return label
+}
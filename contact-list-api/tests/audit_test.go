@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/gorilla/websocket"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuditKey = "chave-de-auditoria-dos-testes"
+
+// patchAuditStorage liga a auditoria com o log e o topo da cadeia em memória
+// e devolve o slice gravado.
+func patchAuditStorage() (*[]models.AuditEntry, func()) {
+	services.ConfigureAudit(true, []byte(testAuditKey))
+
+	var entries []models.AuditEntry
+	var head storage.AuditHead
+	patchLoadHead := monkey.Patch(storage.LoadAuditHead, func(ctx context.Context, tenantID string) (storage.AuditHead, error) {
+		return head, nil
+	})
+	patchSaveHead := monkey.Patch(storage.SaveAuditHead, func(ctx context.Context, tenantID string, saved storage.AuditHead) error {
+		head = saved
+		return nil
+	})
+	patchLoad := monkey.Patch(storage.LoadAuditLog, func(ctx context.Context, tenantID string) ([]models.AuditEntry, error) {
+		return append([]models.AuditEntry(nil), entries...), nil
+	})
+	patchAppend := monkey.Patch(storage.AppendAuditEntry, func(ctx context.Context, tenantID string, entry models.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return &entries, func() {
+		patchLoad.Unpatch()
+		patchAppend.Unpatch()
+		patchLoadHead.Unpatch()
+		patchSaveHead.Unpatch()
+		services.ConfigureAudit(false, nil)
+	}
+}
+
+func TestRecordAccess_ThreeEntries_ExpectedHashChainVerified(t *testing.T) {
+	// Fixture
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	for _, action := range []string{models.AuditList, models.AuditRead, models.AuditDelete} {
+		_, err := services.RecordAccess(context.Background(), models.DefaultTenantID, models.AuditEntry{Actor: "api-key:1", Action: action, ContactIDs: []int{1}})
+		require.NoError(t, err)
+	}
+
+	// Exercise
+	result, err := services.VerifyAuditLog(context.Background(), models.DefaultTenantID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, services.AuditVerification{Valid: true, Entries: 3}, result)
+	require.Len(t, *entries, 3)
+	assert.Empty(t, (*entries)[0].PrevHash)
+	assert.Equal(t, (*entries)[1].Hash, (*entries)[2].PrevHash)
+	assert.Equal(t, int64(3), (*entries)[2].Seq)
+}
+
+func TestVerifyAuditLog_TamperedEntry_ExpectedBrokenAtTamperedSeq(t *testing.T) {
+	// Fixture
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	for _, actor := range []string{"joao", "maria", "joao"} {
+		_, err := services.RecordAccess(context.Background(), models.DefaultTenantID, models.AuditEntry{Actor: actor, Action: models.AuditRead, ContactIDs: []int{2}})
+		require.NoError(t, err)
+	}
+	(*entries)[1].Actor = "joao"
+
+	// Exercise
+	result, err := services.VerifyAuditLog(context.Background(), models.DefaultTenantID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(2), result.BrokenAt)
+}
+
+func TestVerifyAuditLog_LastEntriesCut_ExpectedBrokenAtFirstMissingSeq(t *testing.T) {
+	// Fixture
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	for range 3 {
+		_, err := services.RecordAccess(context.Background(), models.DefaultTenantID, models.AuditEntry{Actor: "joao", Action: models.AuditRead, ContactIDs: []int{2}})
+		require.NoError(t, err)
+	}
+	*entries = (*entries)[:1]
+
+	// Exercise
+	result, err := services.VerifyAuditLog(context.Background(), models.DefaultTenantID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, services.AuditVerification{Valid: false, Entries: 1, BrokenAt: 2}, result)
+}
+
+func TestVerifyAuditLog_EntryRehashedWithoutKey_ExpectedBrokenAtRehashedSeq(t *testing.T) {
+	// Fixture
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	for _, actor := range []string{"joao", "maria"} {
+		_, err := services.RecordAccess(context.Background(), models.DefaultTenantID, models.AuditEntry{Actor: actor, Action: models.AuditRead, ContactIDs: []int{2}})
+		require.NoError(t, err)
+	}
+	forged := (*entries)[1]
+	forged.Actor, forged.Hash = "joao", ""
+	data, _ := json.Marshal(forged)
+	sum := sha256.Sum256(data)
+	forged.Hash = hex.EncodeToString(sum[:])
+	(*entries)[1] = forged
+
+	// Exercise
+	result, err := services.VerifyAuditLog(context.Background(), models.DefaultTenantID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(2), result.BrokenAt)
+}
+
+func TestQueryAuditLog_ActorActionAndContactFilters_ExpectedNewestFirst(t *testing.T) {
+	// Fixture
+	_, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	for _, entry := range []models.AuditEntry{
+		{Actor: "joao", Action: models.AuditRead, ContactIDs: []int{1}},
+		{Actor: "maria", Action: models.AuditRead, ContactIDs: []int{1}},
+		{Actor: "joao", Action: models.AuditList, ContactIDs: []int{1, 2, 3}},
+		{Actor: "joao", Action: models.AuditRead, ContactIDs: []int{2}},
+	} {
+		_, err := services.RecordAccess(context.Background(), models.DefaultTenantID, entry)
+		require.NoError(t, err)
+	}
+
+	// Exercise
+	byActor, err := services.QueryAuditLog(context.Background(), models.DefaultTenantID, services.AuditFilter{Actor: "joao", ContactID: 1})
+	limited, limitedErr := services.QueryAuditLog(context.Background(), models.DefaultTenantID, services.AuditFilter{Action: models.AuditRead, Limit: 1})
+	_, invalidErr := services.QueryAuditLog(context.Background(), models.DefaultTenantID, services.AuditFilter{Action: "view"})
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, limitedErr)
+	require.Len(t, byActor, 2)
+	assert.Equal(t, int64(3), byActor[0].Seq)
+	assert.Equal(t, int64(1), byActor[1].Seq)
+	require.Len(t, limited, 1)
+	assert.Equal(t, int64(4), limited[0].Seq)
+	assert.ErrorIs(t, invalidErr, services.ErrInvalidAuditAction)
+}
+
+func TestGetContactByID_AuditEnabled_ExpectedReadEntryWithActorIPAndRequestID(t *testing.T) {
+	// Fixture
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+	defer patchShareStorage(t, []models.Contact{{ID: 5, OwnerID: models.SharedOwnerID, Name: "Fernanda Lima"}})()
+
+	plaintext := "clk_0a1b2c3d_" + strings.Repeat("ab", 32)
+	defer patchAPIKeyStorage([]models.APIKey{
+		{ID: 3, Name: "painel", Prefix: "clk_0a1b2c3d", Hash: services.HashAPIKey(plaintext), Role: models.RoleReader},
+	})()
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/5", nil)
+	req.Header.Set("X-API-Key", plaintext)
+	req.Header.Set("X-Request-ID", "req-123")
+	req.RemoteAddr = "203.0.113.7:51000"
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, *entries, 1)
+	entry := (*entries)[0]
+	assert.Equal(t, "api-key:3", entry.Actor)
+	assert.Equal(t, models.AuditRead, entry.Action)
+	assert.Equal(t, []int{5}, entry.ContactIDs)
+	assert.Equal(t, "203.0.113.7", entry.IP)
+	assert.Equal(t, "req-123", entry.RequestID)
+}
+
+func TestGetContactByID_OtherOwner_ExpectedNotFoundAndNoAuditEntry(t *testing.T) {
+	// Fixture
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+	defer patchShareStorage(t, []models.Contact{{ID: 5, OwnerID: 1, Name: "Fernanda Lima"}})()
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/5", nil)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotContains(t, w.Body.String(), "Fernanda")
+	assert.Empty(t, *entries)
+}
+
+func TestGetPublicContact_AuditEnabled_ExpectedReadEntryWithLinkActor(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{{ID: 1, OwnerID: 1, Name: "Fernanda Lima"}})()
+	token, link, err := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 1, 0, 0)
+	require.NoError(t, err)
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	req := httptest.NewRequest(http.MethodGet, "/public/contacts/"+token, nil)
+	req.RemoteAddr = "203.0.113.7:51000"
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, *entries, 1)
+	entry := (*entries)[0]
+	assert.Equal(t, "share-link:"+strconv.Itoa(link.ID), entry.Actor)
+	assert.Equal(t, models.AuditRead, entry.Action)
+	assert.Equal(t, []int{1}, entry.ContactIDs)
+	assert.Equal(t, "203.0.113.7", entry.IP)
+}
+
+func TestExportSubjectData_AuditEnabled_ExpectedExportEntryWithSubjectContacts(t *testing.T) {
+	// Fixture
+	_, unpatchPrivacy := patchPrivacyStorage(t, []models.Contact{
+		{ID: 901, OwnerID: 1, Name: "Fernanda Lima", Email: "titular.auditoria@yahoo.com"},
+		{ID: 902, OwnerID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+	})
+	defer unpatchPrivacy()
+	entries, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	req := httptest.NewRequest(http.MethodPost, "/privacy/export", strings.NewReader(`{"email":"titular.auditoria@yahoo.com"}`))
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, *entries, 1)
+	assert.Equal(t, "bootstrap", (*entries)[0].Actor)
+	assert.Equal(t, models.AuditExport, (*entries)[0].Action)
+	assert.Equal(t, []int{901}, (*entries)[0].ContactIDs)
+}
+
+func TestContactEventsWebSocket_AuditEnabled_ExpectedReadEntryPerEvent(t *testing.T) {
+	// Fixture
+	_, unpatch := patchAuditStorage()
+	defer unpatch()
+	recorded := make(chan models.AuditEntry, 4)
+	patchAppend := monkey.Patch(storage.AppendAuditEntry, func(ctx context.Context, tenantID string, entry models.AuditEntry) error {
+		select {
+		case recorded <- entry:
+		default:
+		}
+		return nil
+	})
+	defer patchAppend.Unpatch()
+
+	server := httptest.NewServer(newAuthRouter())
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/contacts/events/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"X-API-Key": {testBootstrapKey}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Exercise
+	require.Eventually(t, func() bool {
+		services.Events.Publish(models.DefaultTenantID, services.EventContactUpdated, models.Contact{ID: 77, OwnerID: models.SharedOwnerID})
+		select {
+		case entry := <-recorded:
+			return assert.Equal(t, []int{77}, entry.ContactIDs)
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, time.Millisecond)
+	var event services.ContactEvent
+	require.NoError(t, conn.ReadJSON(&event))
+
+	// Assert
+	assert.Equal(t, 77, event.Contact.ID)
+}
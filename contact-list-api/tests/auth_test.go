@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/routes"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var testJWTSecret = []byte("segredo-de-teste")
+
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	auth.Configure(auth.Config{BootstrapAPIKey: testBootstrapKey, JWTSecret: testJWTSecret, JWTIssuer: "contact-list-tests"})
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.Use(logging.RequestID())
+	routes.SetupRoutes(router)
+	return router
+}
+
+func patchAPIKeyStorage(keys []models.APIKey) func() {
+	patch := monkey.Patch(storage.LoadAPIKeys, func(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+		return keys, nil
+	})
+	return patch.Unpatch
+}
+
+func signTestJWT(claims jwt.MapClaims) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testJWTSecret)
+	return token
+}
+
+func TestAuthenticate_NoCredentials_ExpectedUnauthorized(t *testing.T) {
+	// Fixture
+	req := httptest.NewRequest(http.MethodGet, "/contacts/", nil)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Values("WWW-Authenticate"), `Basic realm="contact-list-api"`)
+}
+
+func TestVerifyAPIKey_ValidKey_ExpectedStoredKey(t *testing.T) {
+	// Fixture
+	plaintext := "clk_0a1b2c3d_" + strings.Repeat("ab", 32)
+	defer patchAPIKeyStorage([]models.APIKey{
+		{ID: 7, Name: "dashboard", Prefix: "clk_0a1b2c3d", Hash: services.HashAPIKey(plaintext), Role: models.RoleReader},
+	})()
+
+	// Exercise
+	key, err := services.VerifyAPIKey(context.Background(), models.DefaultTenantID, plaintext)
+	_, wrongErr := services.VerifyAPIKey(context.Background(), models.DefaultTenantID, "clk_0a1b2c3d_"+strings.Repeat("cd", 32))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 7, key.ID)
+	assert.Empty(t, key.Hash)
+	assert.ErrorIs(t, wrongErr, services.ErrInvalidAPIKey)
+}
+
+func TestRequireRole_ReaderCreatingContact_ExpectedForbidden(t *testing.T) {
+	// Fixture
+	plaintext := "clk_0a1b2c3d_" + strings.Repeat("ab", 32)
+	defer patchAPIKeyStorage([]models.APIKey{
+		{ID: 1, Name: "leitura", Prefix: "clk_0a1b2c3d", Hash: services.HashAPIKey(plaintext), Role: models.RoleReader},
+	})()
+
+	req := httptest.NewRequest(http.MethodPost, "/contacts/", strings.NewReader(`{"name":"Fernanda Lima"}`))
+	req.Header.Set("X-API-Key", plaintext)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAuthenticate_ValidJWT_ExpectedPrincipalFromClaims(t *testing.T) {
+	// Fixture
+	token := signTestJWT(jwt.MapClaims{
+		"sub":  "fernanda",
+		"role": models.RoleEditor,
+		"iss":  "contact-list-tests",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"subject":"fernanda","role":"editor","method":"jwt"}`, w.Body.String())
+}
+
+func TestAuthenticate_JWTWithoutExpiration_ExpectedUnauthorized(t *testing.T) {
+	// Fixture
+	token := signTestJWT(jwt.MapClaims{
+		"sub":  "fernanda",
+		"role": models.RoleAdmin,
+		"iss":  "contact-list-tests",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/auth"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/routes"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBootstrapKey = "chave-de-teste"
+
+func newCardDAVRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	auth.Configure(auth.Config{BootstrapAPIKey: testBootstrapKey})
+	router := gin.New()
+	routes.SetupCardDAVRoutes(router)
+	return router
+}
+
+func patchCardDAVStorage(t *testing.T, contacts []models.Contact) func() {
+	seedContacts(t, models.DefaultTenantID, contacts)
+	patchChanges := monkey.Patch(storage.LoadChangeLog, func(ctx context.Context, tenantID string) (storage.ChangeLog, error) {
+		return storage.ChangeLog{LastSeq: 3}, nil
+	})
+	return patchChanges.Unpatch
+}
+
+func TestContactToVCard_Success_ExpectedVCard30(t *testing.T) {
+	// Fixture
+	contact := models.Contact{ID: 3, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"}
+
+	expected := "BEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"UID:contact-3\r\n" +
+		"FN:Carlos Eduardo\r\n" +
+		"N:Eduardo;Carlos;;;\r\n" +
+		"EMAIL;TYPE=INTERNET:carlos.eduardo@gmail.com\r\n" +
+		"TEL;TYPE=CELL:551199998877\r\n" +
+		"END:VCARD\r\n"
+
+	// Exercise
+	result := services.ContactToVCard(contact)
+
+	// Assert
+	assert.Equal(t, expected, result)
+}
+
+func TestContactToVCard_LongName_ExpectedFoldedLines(t *testing.T) {
+	// Fixture
+	contact := models.Contact{ID: 1, Name: strings.Repeat("João ", 30)}
+
+	// Exercise
+	result := services.ContactToVCard(contact)
+
+	// Assert
+	for _, line := range strings.Split(strings.TrimSuffix(result, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+}
+
+func TestCardDAV_Multiget_ExpectedAddressDataAndNotFound(t *testing.T) {
+	// Fixture
+	defer patchCardDAVStorage(t, []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"},
+	})()
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<card:addressbook-multiget xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
+  <d:prop><d:getetag/><card:address-data/></d:prop>
+  <d:href>/carddav/contacts/1.vcf</d:href>
+  <d:href>/carddav/contacts/9.vcf</d:href>
+</card:addressbook-multiget>`
+
+	req := httptest.NewRequest("REPORT", "/carddav/contacts/", strings.NewReader(body))
+	req.SetBasicAuth("agenda", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newCardDAVRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "FN:Fernanda Lima")
+	assert.Contains(t, w.Body.String(), "<d:href>/carddav/contacts/9.vcf</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+}
+
+func TestCardDAV_AddressBookQuery_ExpectedFilteredContacts(t *testing.T) {
+	// Fixture
+	defer patchCardDAVStorage(t, []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+	})()
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<card:addressbook-query xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
+  <d:prop><d:getetag/></d:prop>
+  <card:filter>
+    <card:prop-filter name="EMAIL">
+      <card:text-match match-type="ends-with">@gmail.com</card:text-match>
+    </card:prop-filter>
+  </card:filter>
+</card:addressbook-query>`
+
+	req := httptest.NewRequest("REPORT", "/carddav/contacts/", strings.NewReader(body))
+	req.SetBasicAuth("agenda", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newCardDAVRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "/carddav/contacts/2.vcf")
+	assert.NotContains(t, w.Body.String(), "/carddav/contacts/1.vcf")
+}
+
+func TestCardDAV_PropfindAddressBook_ExpectedCardsAtDepthOne(t *testing.T) {
+	// Fixture
+	defer patchCardDAVStorage(t, []models.Contact{
+		{ID: 1, Name: "Fernanda Lima"},
+	})()
+
+	req := httptest.NewRequest("PROPFIND", "/carddav/contacts/", nil)
+	req.Header.Set("Depth", "1")
+	req.SetBasicAuth("agenda", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newCardDAVRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "<card:addressbook/>")
+	assert.Contains(t, w.Body.String(), "<d:sync-token>urn:contact-list-api:sync:")
+	assert.Contains(t, w.Body.String(), "/carddav/contacts/1.vcf")
+}
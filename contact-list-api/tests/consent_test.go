@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchConsentStorage guarda os consentimentos em memória e devolve o slice
+// gravado.
+func patchConsentStorage() (*[]models.ConsentRecord, func()) {
+	var records []models.ConsentRecord
+	patchLoad := monkey.Patch(storage.LoadConsents, func(ctx context.Context, tenantID string) ([]models.ConsentRecord, error) {
+		return append([]models.ConsentRecord(nil), records...), nil
+	})
+	patchSave := monkey.Patch(storage.SaveConsents, func(ctx context.Context, tenantID string, r []models.ConsentRecord) error {
+		records = r
+		return nil
+	})
+	return &records, func() {
+		patchLoad.Unpatch()
+		patchSave.Unpatch()
+	}
+}
+
+func marketingConsent(channel, status string) models.ConsentRecord {
+	return models.ConsentRecord{Channel: channel, Purpose: models.PurposeMarketing, Status: status, Source: "formulario-site"}
+}
+
+func TestRecordConsent_GrantedThenRevoked_ExpectedRevokedCurrentAndFullHistory(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"}})()
+	_, unpatch := patchConsentStorage()
+	defer unpatch()
+
+	_, err := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent(models.ChannelEmail, models.ConsentGranted))
+	require.NoError(t, err)
+
+	// Exercise
+	revoked, err := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent(models.ChannelEmail, models.ConsentRevoked))
+	consents, getErr := services.GetContactConsents(context.Background(), models.DefaultTenantID, 1, 1)
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, getErr)
+	assert.Equal(t, []models.ConsentRecord{revoked}, consents.Current)
+	require.Len(t, consents.History, 2)
+	assert.Equal(t, models.ConsentGranted, consents.History[0].Status)
+	assert.Equal(t, "joao", revoked.RecordedBy)
+	assert.False(t, revoked.RecordedAt.IsZero())
+}
+
+func TestRecordConsent_InvalidInputOrReadOnlyShare_ExpectedErrors(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"}})()
+	records, unpatch := patchConsentStorage()
+	defer unpatch()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 1, "maria", models.PermissionRead)
+	require.NoError(t, err)
+
+	missingSource := marketingConsent(models.ChannelEmail, models.ConsentGranted)
+	missingSource.Source = " "
+
+	// Exercise
+	_, channelErr := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent("fax", models.ConsentGranted))
+	_, statusErr := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent(models.ChannelEmail, "talvez"))
+	_, sourceErr := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", missingSource)
+	_, readerErr := services.RecordConsent(context.Background(), models.DefaultTenantID, 2, 1, "maria", marketingConsent(models.ChannelEmail, models.ConsentGranted))
+
+	// Assert
+	assert.ErrorIs(t, channelErr, services.ErrInvalidChannel)
+	assert.ErrorIs(t, statusErr, services.ErrInvalidConsentStatus)
+	assert.ErrorIs(t, sourceErr, services.ErrConsentSourceMissing)
+	assert.ErrorIs(t, readerErr, services.ErrPermissionDenied)
+	assert.Empty(t, *records)
+}
+
+func TestExportContacts_MarketingPurpose_ExpectedOnlyConsentedFields(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "+55 11 98765-4321"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "+55 21 99876-5432"},
+		{ID: 3, OwnerID: 1, Name: "Ana Souza", Email: "ana.souza@outlook.com"},
+	})()
+	_, unpatch := patchConsentStorage()
+	defer unpatch()
+
+	for _, r := range []struct {
+		contactID int
+		consent   models.ConsentRecord
+	}{
+		{1, marketingConsent(models.ChannelEmail, models.ConsentGranted)},
+		{2, marketingConsent(models.ChannelSMS, models.ConsentGranted)},
+		{3, marketingConsent(models.ChannelEmail, models.ConsentGranted)},
+		{3, marketingConsent(models.ChannelEmail, models.ConsentRevoked)},
+	} {
+		_, err := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, r.contactID, "joao", r.consent)
+		require.NoError(t, err)
+	}
+
+	// Exercise
+	all, err := services.ExportContacts(context.Background(), models.DefaultTenantID, 1, "", "")
+	marketing, marketingErr := services.ExportContacts(context.Background(), models.DefaultTenantID, 1, models.PurposeMarketing, "")
+	emailOnly, emailErr := services.ExportContacts(context.Background(), models.DefaultTenantID, 1, models.PurposeMarketing, models.ChannelEmail)
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, marketingErr)
+	require.NoError(t, emailErr)
+	assert.Len(t, all, 3)
+	assert.Equal(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo", Phone: "+55 21 99876-5432"},
+	}, marketing)
+	assert.Equal(t, []models.Contact{{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"}}, emailOnly)
+}
+
+func TestGetContactsWithoutConsent_EmailChannel_ExpectedContactsWithEmailAndNoValidConsent(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+		{ID: 3, OwnerID: 1, Name: "Ana Souza", Phone: "+55 31 97654-3210"},
+		{ID: 4, OwnerID: 1, Name: "Bruno Alves", Email: "bruno.alves@gmail.com"},
+	})()
+	_, unpatch := patchConsentStorage()
+	defer unpatch()
+
+	_, err := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent(models.ChannelEmail, models.ConsentGranted))
+	require.NoError(t, err)
+	_, err = services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 4, "joao", marketingConsent(models.ChannelEmail, models.ConsentRevoked))
+	require.NoError(t, err)
+
+	// Exercise
+	missing, err := services.GetContactsWithoutConsent(context.Background(), models.DefaultTenantID, 1, models.ChannelEmail, models.PurposeMarketing)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, missing, 2)
+	assert.Equal(t, 2, missing[0].ID)
+	assert.Equal(t, 4, missing[1].ID)
+}
+
+func TestExportContacts_EmailChangedAfterConsent_ExpectedConsentNoLongerValid(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "+55 11 98765-4321"},
+	})()
+	records, unpatch := patchConsentStorage()
+	defer unpatch()
+
+	granted, err := services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent(models.ChannelEmail, models.ConsentGranted))
+	require.NoError(t, err)
+	_, err = services.RecordConsent(context.Background(), models.DefaultTenantID, 1, 1, "joao", marketingConsent(models.ChannelSMS, models.ConsentGranted))
+	require.NoError(t, err)
+	_, err = services.UpdateContactById(context.Background(), models.DefaultTenantID, 1, 1, models.Contact{
+		Name: "Fernanda Lima", Email: "fernanda@empresa.com.br", Phone: "+55 11 98765-4321",
+	})
+	require.NoError(t, err)
+
+	// Exercise
+	marketing, err := services.ExportContacts(context.Background(), models.DefaultTenantID, 1, models.PurposeMarketing, "")
+	missing, missingErr := services.GetContactsWithoutConsent(context.Background(), models.DefaultTenantID, 1, models.ChannelEmail, models.PurposeMarketing)
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, missingErr)
+	assert.Equal(t, []models.Contact{{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Phone: "+55 11 98765-4321"}}, marketing)
+	require.Len(t, missing, 1)
+	assert.Equal(t, 1, missing[0].ID)
+	assert.Empty(t, granted.AddressHash)
+	assert.NotEmpty(t, (*records)[0].AddressHash)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedReloadTenant deixa os contatos 1 e 2 no snapshot e o 3 só no journal,
+// como fica um tenant entre duas compactações.
+func seedReloadTenant(t *testing.T) []models.Contact {
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync, SnapshotEvery: 1})
+	defer storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+
+	contacts := saveJournalVersions(t, 2)
+	contacts = append(contacts, models.Contact{ID: 3, Name: "Contato 3"})
+	require.NoError(t, storage.SaveContacts(context.Background(), repositoryTestTenant, contacts))
+	return contacts
+}
+
+// editContactsFile grava o arquivo como um editor: numa cópia renomeada
+// sobre o original.
+func editContactsFile(t *testing.T, content string) {
+	tmp := filepath.Join(filepath.Dir(repositoryTestFile()), "contacts.json~")
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0644))
+	require.NoError(t, os.Rename(tmp, repositoryTestFile()))
+}
+
+func TestReloadContacts_ExternalEdit_ExpectedMergedWithJournalAndPersisted(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := seedReloadTenant(t)
+	editContactsFile(t, `[
+  {"id": 1, "name": "Contato 1 corrigido", "email": "contato1@exemplo.com.br", "phone": ""},
+  {"id": 4, "name": "Contato 4", "email": "", "phone": "11999998888"}
+]`)
+	logBefore, _ := storage.LoadChangeLog(ctx, repositoryTestTenant)
+
+	// Exercise
+	changes, err := storage.ReloadContacts(ctx, repositoryTestTenant)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+	byEmail, _ := storage.FindContactsByEmail(ctx, repositoryTestTenant, "contato1@exemplo.com.br")
+	logAfter, _ := storage.LoadChangeLog(ctx, repositoryTestTenant)
+	again, againErr := storage.ReloadContacts(ctx, repositoryTestTenant)
+	reopenRepositoryTenant(t)
+	reopened, reopenErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	updated := models.Contact{ID: 1, Name: "Contato 1 corrigido", Email: "contato1@exemplo.com.br"}
+	created := models.Contact{ID: 4, Name: "Contato 4", Phone: "11999998888"}
+	expected := []models.Contact{updated, contacts[2], created}
+	require.NoError(t, err)
+	assert.Equal(t, storage.ContactChanges{Created: []models.Contact{created}, Updated: []models.Contact{updated}, Deleted: []models.Contact{contacts[1]}}, changes)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, expected, loaded)
+	assert.Equal(t, []models.Contact{updated}, byEmail)
+	assert.Equal(t, logBefore.LastSeq+3, logAfter.LastSeq)
+	assert.NoError(t, againErr)
+	assert.True(t, again.Empty())
+	assert.NoError(t, reopenErr)
+	assert.ElementsMatch(t, expected, reopened)
+}
+
+func TestReloadContacts_InvalidEdit_ExpectedRejectedAndStateKept(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := seedReloadTenant(t)
+	out, restore := captureLogs(t, "info")
+	defer restore()
+	rejected := `contacts_file_reloads_total{result="rejected"}`
+	before := metricValue(t, scrapeMetrics(t), rejected)
+
+	edits := map[string]string{
+		"syntax":    `[{"id": 1, "name": "Contato 1",}]`,
+		"duplicate": `[{"id": 1, "name": "Contato 1"}, {"id": 1, "name": "Outro"}]`,
+		"unknown":   `[{"id": 1, "nome": "Contato 1"}]`,
+		"collision": `[{"id": 1, "name": "Contato 1"}, {"id": 2, "name": "Contato 2"}, {"id": 3, "name": "Outro 3"}]`,
+	}
+
+	for name, content := range edits {
+		// Exercise
+		editContactsFile(t, content)
+		_, err := storage.ReloadContacts(ctx, repositoryTestTenant)
+		loaded, _ := storage.LoadContacts(ctx, repositoryTestTenant)
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrInvalidContactsFile, name)
+		assert.Equal(t, contacts, loaded, name)
+	}
+	assert.Contains(t, out.String(), "contacts file edit rejected")
+	assert.Contains(t, out.String(), "duplicate id 1")
+	assert.Contains(t, out.String(), "contact id 3 already exists")
+	assert.Equal(t, before+float64(len(edits)), metricValue(t, scrapeMetrics(t), rejected))
+}
+
+func TestReloadContacts_EditReusingDeletedID_ExpectedRejected(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := seedReloadTenant(t)
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync, SnapshotEvery: 1})
+	defer storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+	_, err := storage.DeleteContact(ctx, repositoryTestTenant, 3, nil)
+	require.NoError(t, err)
+	editContactsFile(t, `[{"id": 1, "name": "Contato 1"}, {"id": 2, "name": "Contato 2"}, {"id": 3, "name": "Outro 3"}]`)
+
+	// Exercise
+	_, err = storage.ReloadContacts(ctx, repositoryTestTenant)
+	loaded, _ := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	assert.ErrorIs(t, err, storage.ErrInvalidContactsFile)
+	assert.ErrorContains(t, err, "contact id 3 was already used")
+	assert.Equal(t, contacts[:2], loaded)
+}
+
+func TestWatchContacts_ExternalEdit_ExpectedEventsPublished(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	contacts := seedReloadTenant(t)
+	_, _, events, cancel := services.Events.Subscribe(0)
+	defer cancel()
+
+	var mu sync.Mutex
+	var reloads []storage.ContactChanges
+	watcher, err := storage.WatchContacts(func(tenantID string, changes storage.ContactChanges) {
+		mu.Lock()
+		reloads = append(reloads, changes)
+		mu.Unlock()
+		services.PublishContactChanges(tenantID, changes)
+	}, repositoryTestTenant)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	// Exercise
+	require.NoError(t, storage.SaveContacts(context.Background(), repositoryTestTenant, contacts[:2]))
+	editContactsFile(t, `[{"id": 1, "name": "Contato 1"}, {"id": 2, "name": "Contato 2 corrigido"}]`)
+
+	// Assert
+	var received []services.ContactEvent
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case event := <-events:
+				if event.TenantID == repositoryTestTenant {
+					received = append(received, event)
+				}
+			default:
+				return len(received) == 1
+			}
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, services.EventContactUpdated, received[0].Type)
+	assert.Equal(t, models.Contact{ID: 2, Name: "Contato 2 corrigido"}, received[0].Contact)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, reloads, 1)
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repositoryTestTenant é o tenant em que estes testes e benchmarks gravam,
+// num diretório de dados temporário.
+const repositoryTestTenant = "teste-repositorio"
+
+var repositoryTestDir string
+
+// useRepositoryTenant aponta o armazenamento para um diretório temporário,
+// vazio, até o fim do teste.
+func useRepositoryTenant(t testing.TB) {
+	repositoryTestDir = t.TempDir()
+	previous := storage.UseDataDir(repositoryTestDir)
+	t.Cleanup(func() { storage.UseDataDir(previous) })
+}
+
+// repositoryTestPath devolve o caminho de um arquivo do tenant de teste.
+func repositoryTestPath(elem ...string) string {
+	return filepath.Join(append([]string{repositoryTestDir, "tenants", repositoryTestTenant}, elem...)...)
+}
+
+func repositoryTestFile() string {
+	return repositoryTestPath("contacts.json")
+}
+
+func repositoryTestJournal() string {
+	return repositoryTestPath("contacts.journal")
+}
+
+func useAsyncPersistence(interval time.Duration) func() {
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilityAsync, FlushInterval: interval})
+	return func() {
+		storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+	}
+}
+
+func TestContactRepository_Lookups_ExpectedIndexesFollowChangesWithoutDiskReads(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "+55 11 98765-4321"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"},
+	}
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts))
+	loads := `contacts_storage_duration_seconds_count{operation="load"}`
+	before := metricValue(t, scrapeMetrics(t), loads)
+
+	// Exercise
+	updated := append([]models.Contact(nil), contacts...)
+	updated[0].Email = "fernanda@empresa.com.br"
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, updated))
+
+	byID, found, idErr := storage.GetContact(ctx, repositoryTestTenant, 2)
+	_, missing, _ := storage.GetContact(ctx, repositoryTestTenant, 9)
+	oldEmail, _ := storage.FindContactsByEmail(ctx, repositoryTestTenant, "fernanda.lima@yahoo.com")
+	newEmail, _ := storage.FindContactsByEmail(ctx, repositoryTestTenant, " FERNANDA@empresa.com.br")
+	byPhone, _ := storage.FindContactsByPhone(ctx, repositoryTestTenant, "5511987654321")
+	changeLog, logErr := storage.LoadChangeLog(ctx, repositoryTestTenant)
+	after := metricValue(t, scrapeMetrics(t), loads)
+
+	// Assert
+	assert.NoError(t, idErr)
+	assert.True(t, found)
+	assert.Equal(t, contacts[1], byID)
+	assert.False(t, missing)
+	assert.Empty(t, oldEmail)
+	assert.Equal(t, updated[:1], newEmail)
+	assert.Equal(t, updated[:1], byPhone)
+	assert.NoError(t, logErr)
+	assert.Equal(t, int64(3), changeLog.LastSeq)
+	assert.Equal(t, before, after)
+}
+
+func TestSaveContacts_AsyncDurability_ExpectedChangesBatchedUntilFlush(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	defer useAsyncPersistence(time.Hour)()
+	ctx := context.Background()
+	appends := `contacts_storage_duration_seconds_count{operation="append"}`
+	before := metricValue(t, scrapeMetrics(t), appends)
+
+	// Exercise
+	var contacts []models.Contact
+	for i := 1; i <= 3; i++ {
+		contacts = append(contacts, models.Contact{ID: i, Name: fmt.Sprintf("Contato %d", i)})
+		require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts))
+	}
+	pending, _ := os.ReadFile(repositoryTestJournal())
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+	beforeFlush := metricValue(t, scrapeMetrics(t), appends)
+
+	flushErr := storage.Flush(ctx)
+	flushed, _ := os.ReadFile(repositoryTestJournal())
+	afterFlush := metricValue(t, scrapeMetrics(t), appends)
+
+	// Assert
+	assert.NotContains(t, string(pending), "Contato 1")
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+	assert.Equal(t, before, beforeFlush)
+	assert.NoError(t, flushErr)
+	assert.Contains(t, string(flushed), "Contato 3")
+	assert.Equal(t, 1, strings.Count(string(flushed), "\n"))
+	assert.Equal(t, before+1, afterFlush)
+}
+
+func TestSaveContacts_AsyncDurability_ExpectedWrittenByFlushLoop(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	defer useAsyncPersistence(20 * time.Millisecond)()
+
+	// Exercise
+	err := storage.SaveContacts(context.Background(), repositoryTestTenant, []models.Contact{{ID: 1, Name: "Fernanda Lima"}})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(repositoryTestJournal())
+		return strings.Contains(string(data), "Fernanda Lima")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPutContact_ConcurrentCreates_ExpectedNoWriteLost(t *testing.T) {
+	for _, durability := range []storage.Durability{storage.DurabilitySync, storage.DurabilityAsync} {
+		// Fixture
+		useRepositoryTenant(t)
+		storage.ConfigurePersistence(storage.PersistenceConfig{Durability: durability, FlushInterval: time.Hour})
+		const writers = 50
+
+		// Exercise
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := storage.PutContact(context.Background(), repositoryTestTenant, 0, func(_ models.Contact, _ int) (models.Contact, error) {
+					return models.Contact{Name: fmt.Sprintf("Contato %d", i)}, nil
+				})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+		reopenRepositoryTenant(t)
+		loaded, err := storage.LoadContacts(context.Background(), repositoryTestTenant)
+
+		// Assert
+		for err := range errs {
+			assert.NoError(t, err, durability)
+		}
+		require.NoError(t, err, durability)
+		ids := map[int]bool{}
+		for _, contact := range loaded {
+			ids[contact.ID] = true
+		}
+		assert.Len(t, loaded, writers, durability)
+		assert.Len(t, ids, writers, durability)
+	}
+}
+
+func TestPutContact_AfterDeletingHighestID_ExpectedIDNotReusedAndTombstoneKept(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	owned := func(ownerID int) func(models.Contact, int) (models.Contact, error) {
+		return func(_ models.Contact, _ int) (models.Contact, error) {
+			return models.Contact{Name: fmt.Sprintf("Contato de %d", ownerID), OwnerID: ownerID}, nil
+		}
+	}
+	_, err := storage.PutContact(ctx, repositoryTestTenant, 0, owned(1))
+	require.NoError(t, err)
+	last, err := storage.PutContact(ctx, repositoryTestTenant, 0, owned(1))
+	require.NoError(t, err)
+	_, err = storage.DeleteContact(ctx, repositoryTestTenant, last.ID, nil)
+	require.NoError(t, err)
+
+	// Exercise
+	created, err := storage.PutContact(ctx, repositoryTestTenant, 0, owned(2))
+	require.NoError(t, err)
+	reopenRepositoryTenant(t)
+	reopened, reopenErr := storage.PutContact(ctx, repositoryTestTenant, 0, owned(2))
+	changeLog, _ := storage.LoadChangeLog(ctx, repositoryTestTenant)
+
+	// Assert
+	assert.Equal(t, last.ID+1, created.ID)
+	assert.NoError(t, reopenErr)
+	assert.Equal(t, last.ID+2, reopened.ID)
+	tombstone := changeLog.Entries[slices.IndexFunc(changeLog.Entries, func(entry storage.ChangeEntry) bool { return entry.ContactID == last.ID })]
+	assert.True(t, tombstone.Deleted)
+	assert.Equal(t, 1, tombstone.OwnerID)
+}
+
+func TestSaveContacts_AsyncWhileDroppingCleanRepositories_ExpectedNoWriteLost(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	defer useAsyncPersistence(time.Hour)()
+	const writers = 400
+
+	// Exercise
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := storage.PutContact(context.Background(), repositoryTestTenant, 0, func(_ models.Contact, _ int) (models.Contact, error) {
+				return models.Contact{Name: fmt.Sprintf("Contato %d", i)}, nil
+			})
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, storage.Flush(context.Background()))
+			reopenRepositoryTenant(t)
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, storage.Flush(context.Background()))
+	reopenRepositoryTenant(t)
+	loaded, err := storage.LoadContacts(context.Background(), repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, loaded, writers)
+}
+
+func TestPersistenceConfigFromEnv_Unset_ExpectedSyncDurability(t *testing.T) {
+	// Fixture
+	t.Setenv("STORAGE_DURABILITY", "")
+
+	// Exercise
+	config, err := storage.PersistenceConfigFromEnv()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, storage.DurabilitySync, config.Durability)
+}
+
+func TestPersistenceConfigFromEnv_Values_ExpectedParsedOrError(t *testing.T) {
+	// Fixture
+	t.Setenv("STORAGE_DURABILITY", "sync")
+	t.Setenv("STORAGE_FLUSH_INTERVAL", "250ms")
+	t.Setenv("STORAGE_SNAPSHOT_EVERY", "50")
+
+	// Exercise
+	config, err := storage.PersistenceConfigFromEnv()
+	t.Setenv("STORAGE_SNAPSHOT_EVERY", "0")
+	_, snapshotErr := storage.PersistenceConfigFromEnv()
+	t.Setenv("STORAGE_DURABILITY", "eventual")
+	_, invalidErr := storage.PersistenceConfigFromEnv()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, storage.PersistenceConfig{Durability: storage.DurabilitySync, FlushInterval: 250 * time.Millisecond, SnapshotEvery: 50}, config)
+	assert.Error(t, snapshotErr)
+	assert.ErrorIs(t, invalidErr, storage.ErrInvalidDurability)
+}
+
+// seedBenchmarkTenant grava n contatos no tenant de teste e devolve a lista.
+func seedBenchmarkTenant(b *testing.B, n int) []models.Contact {
+	contacts := make([]models.Contact, n)
+	for i := range contacts {
+		contacts[i] = models.Contact{
+			ID:    i + 1,
+			Name:  fmt.Sprintf("Contato %05d", i+1),
+			Email: fmt.Sprintf("contato%05d@exemplo.com.br", i+1),
+			Phone: fmt.Sprintf("55119%08d", i+1),
+		}
+	}
+	require.NoError(b, storage.SaveContacts(context.Background(), repositoryTestTenant, contacts))
+
+	// Com o journal, contacts.json pode não ter a lista inteira; a leitura
+	// antiga usa uma cópia completa.
+	data, err := json.Marshal(contacts)
+	require.NoError(b, err)
+	require.NoError(b, os.WriteFile(repositoryTestPath("file_scan.json"), data, 0644))
+	return contacts
+}
+
+// readContactsFile é o caminho de leitura anterior ao repositório em
+// memória: ler e decodificar o arquivo inteiro a cada requisição.
+func readContactsFile(b *testing.B) []models.Contact {
+	data, err := os.ReadFile(repositoryTestPath("file_scan.json"))
+	require.NoError(b, err)
+	var contacts []models.Contact
+	require.NoError(b, json.Unmarshal(data, &contacts))
+	return contacts
+}
+
+func BenchmarkGetContactByID(b *testing.B) {
+	useRepositoryTenant(b)
+	contacts := seedBenchmarkTenant(b, 5000)
+	ctx := context.Background()
+
+	b.Run("file_scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			id := contacts[i%len(contacts)].ID
+			for _, contact := range readContactsFile(b) {
+				if contact.ID == id {
+					break
+				}
+			}
+		}
+	})
+	b.Run("repository", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok, _ := storage.GetContact(ctx, repositoryTestTenant, contacts[i%len(contacts)].ID); !ok {
+				b.Fatal("contact not found")
+			}
+		}
+	})
+}
+
+func BenchmarkFindContactsByEmail(b *testing.B) {
+	useRepositoryTenant(b)
+	contacts := seedBenchmarkTenant(b, 5000)
+	ctx := context.Background()
+
+	b.Run("file_scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			email := storage.NormalizeEmail(contacts[i%len(contacts)].Email)
+			for _, contact := range readContactsFile(b) {
+				if storage.NormalizeEmail(contact.Email) == email {
+					break
+				}
+			}
+		}
+	})
+	b.Run("repository", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if found, _ := storage.FindContactsByEmail(ctx, repositoryTestTenant, contacts[i%len(contacts)].Email); len(found) != 1 {
+				b.Fatal("contact not found")
+			}
+		}
+	})
+}
+
+func BenchmarkSaveContacts(b *testing.B) {
+	useRepositoryTenant(b)
+	contacts := seedBenchmarkTenant(b, 5000)
+	ctx := context.Background()
+
+	save := func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			contacts[i%len(contacts)].Name = fmt.Sprintf("Contato alterado %d", i)
+			if err := storage.SaveContacts(ctx, repositoryTestTenant, contacts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("sync", save)
+	b.Run("async", func(b *testing.B) {
+		defer useAsyncPersistence(time.Second)()
+		save(b)
+	})
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -9,8 +10,18 @@ import (
 	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
 	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// seedContacts aponta o armazenamento para um diretório temporário e grava
+// contacts no tenant, para que o teste passe pelo índice e pelo journal do
+// repositório em vez de substituir as leituras e gravações.
+func seedContacts(t *testing.T, tenantID string, contacts []models.Contact) {
+	t.Helper()
+	useRepositoryTenant(t)
+	require.NoError(t, storage.SaveContacts(context.Background(), tenantID, contacts))
+}
+
 func TestGetContactByID_Success_ExpectedValidContact(t *testing.T) {
 	// Fixture
 	expectedContact := models.Contact{
@@ -27,20 +38,17 @@ func TestGetContactByID_Success_ExpectedValidContact(t *testing.T) {
 		{ID: 5, Name: "Juliana Souza", Email: "", Phone: "551197654321"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patch.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
 	// Exercise
-	result, err := services.GetContactByID(3)
+	result, err := services.GetContactByID(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 3)
 
 	// Assert
 	assert.Equal(t, result, expectedContact)
 	assert.NoError(t, err, "Expected no error when contact is found")
 }
 
-func TestGetContactByID_NotFound_ExpectedEmpty(t *testing.T) {
+func TestGetContactByID_NotFound_ExpectedNotFoundError(t *testing.T) {
 	// Fixture
 
 	mockContacts := []models.Contact{
@@ -50,17 +58,14 @@ func TestGetContactByID_NotFound_ExpectedEmpty(t *testing.T) {
 		{ID: 5, Name: "Juliana Souza", Email: "", Phone: "551197654321"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patch.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
 	// Exercise
-	result, err := services.GetContactByID(2)
+	result, err := services.GetContactByID(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 2)
 
 	// Assert
 	assert.Equal(t, models.Contact{}, result)
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, services.ErrContactNotFound)
 }
 
 func TestUpdateContactById_Success_ExpectedUpdatedContact(t *testing.T) {
@@ -85,25 +90,17 @@ func TestUpdateContactById_Success_ExpectedUpdatedContact(t *testing.T) {
 		{ID: 5, Name: "Juliana Souza", Email: "", Phone: "551197654321"},
 	}
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patchLoad.Unpatch()
-
-	patchSave := monkey.Patch(storage.SaveContacts, func(contacts []models.Contact) error {
-		return nil
-	})
-	defer patchSave.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
 	// Exercise
-	result, err := services.UpdateContactById(3, updatedContact)
+	result, err := services.UpdateContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 3, updatedContact)
 
 	// Assert
 	assert.Equal(t, expectedContact, result)
 	assert.NoError(t, err)
 }
 
-func TestUpdateContactById_NotFound_ExpectedEmpty(t *testing.T) {
+func TestUpdateContactById_NotFound_ExpectedNotFoundError(t *testing.T) {
 	// Fixture
 	updatedContact := models.Contact{
 		Name:  "Contato Inexistente",
@@ -118,22 +115,14 @@ func TestUpdateContactById_NotFound_ExpectedEmpty(t *testing.T) {
 		{ID: 5, Name: "Juliana Souza", Email: "", Phone: "551197654321"},
 	}
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patchLoad.Unpatch()
-
-	patchSave := monkey.Patch(storage.SaveContacts, func(contacts []models.Contact) error {
-		return nil
-	})
-	defer patchSave.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
 	// Exercise
-	result, err := services.UpdateContactById(2, updatedContact)
+	result, err := services.UpdateContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 2, updatedContact)
 
 	// Assert
 	assert.Equal(t, models.Contact{}, result)
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, services.ErrContactNotFound)
 }
 
 func TestUpdateContactById_LoadError_ExpectedError(t *testing.T) {
@@ -146,13 +135,13 @@ func TestUpdateContactById_LoadError_ExpectedError(t *testing.T) {
 
 	expectedError := errors.New("failed to load contacts")
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return nil, expectedError
+	patchPut := monkey.Patch(storage.PutContact, func(ctx context.Context, tenantID string, id int, build func(models.Contact, int) (models.Contact, error)) (models.Contact, error) {
+		return models.Contact{}, expectedError
 	})
-	defer patchLoad.Unpatch()
+	defer patchPut.Unpatch()
 
 	// Exercise
-	result, err := services.UpdateContactById(1, updatedContact)
+	result, err := services.UpdateContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 1, updatedContact)
 
 	// Assert
 	assert.Equal(t, models.Contact{}, result)
@@ -177,18 +166,15 @@ func TestUpdateContactById_SaveError_ExpectedError(t *testing.T) {
 
 	expectedError := errors.New("failed to save contacts")
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patchLoad.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
-	patchSave := monkey.Patch(storage.SaveContacts, func(contacts []models.Contact) error {
-		return expectedError
+	patchPut := monkey.Patch(storage.PutContact, func(ctx context.Context, tenantID string, id int, build func(models.Contact, int) (models.Contact, error)) (models.Contact, error) {
+		return models.Contact{}, expectedError
 	})
-	defer patchSave.Unpatch()
+	defer patchPut.Unpatch()
 
 	// Exercise
-	result, err := services.UpdateContactById(3, updatedContact)
+	result, err := services.UpdateContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 3, updatedContact)
 
 	// Assert
 	assert.Equal(t, models.Contact{}, result)
@@ -214,13 +200,13 @@ func TestGetContactsSummary_Success_ExpectedCompleteStatistics(t *testing.T) {
 		DuplicatedNames: []string{"fernanda lima"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	result, err := services.GetContactsSummary()
+	result, err := services.GetContactsSummary(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.Equal(t, result, expectedSummary)
@@ -239,13 +225,13 @@ func TestGetContactsSummary_EmptyList_ExpectedZeroStatistics(t *testing.T) {
 		DuplicatedNames: nil,
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	result, err := services.GetContactsSummary()
+	result, err := services.GetContactsSummary(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.Equal(t, result, expectedSummary)
@@ -256,13 +242,13 @@ func TestGetContactsSummary_LoadError_ExpectedError(t *testing.T) {
 	// Fixture
 	expectedError := errors.New("failed to load contacts from storage")
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return nil, expectedError
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	result, err := services.GetContactsSummary()
+	result, err := services.GetContactsSummary(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.Equal(t, services.ContactSummary{}, result)
@@ -283,13 +269,13 @@ func TestSearchContactsByName_Success(t *testing.T) {
 		{ID: 3, Name: "Fernando Souza", Email: "fernando.souza@hotmail.com", Phone: "551197654321"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	results, err := services.SearchContactsByName("Fern")
+	results, err := services.SearchContactsByName(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "Fern")
 
 	// Assert
 	assert.NoError(t, err)
@@ -303,13 +289,13 @@ func TestSearchContactsByName_NoMatches(t *testing.T) {
 		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	results, err := services.SearchContactsByName("Marcos")
+	results, err := services.SearchContactsByName(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "Marcos")
 
 	// Assert
 	assert.NoError(t, err)
@@ -323,13 +309,13 @@ func TestSearchContactsByName_EmptyName(t *testing.T) {
 		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	results, err := services.SearchContactsByName("")
+	results, err := services.SearchContactsByName(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "")
 
 	// Assert
 	assert.NoError(t, err)
@@ -352,13 +338,13 @@ func TestGetEmailProviders_Success(t *testing.T) {
 		"hotmail.com": 1,
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	providers, err := services.GetEmailProviders()
+	providers, err := services.GetEmailProviders(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.NoError(t, err)
@@ -372,13 +358,13 @@ func TestGetEmailProviders_EmptyEmails(t *testing.T) {
 		{ID: 2, Name: "Carlos Eduardo", Email: "", Phone: "551199998877"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return mockContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	providers, err := services.GetEmailProviders()
+	providers, err := services.GetEmailProviders(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.NoError(t, err)
@@ -389,13 +375,13 @@ func TestGetEmailProviders_StorageError(t *testing.T) {
 	// Fixture
 	expectedError := errors.New("storage error")
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return nil, expectedError
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	providers, err := services.GetEmailProviders()
+	providers, err := services.GetEmailProviders(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.Error(t, err)
@@ -410,22 +396,14 @@ func TestDeleteContactById_Success(t *testing.T) {
 		{ID: 3, Name: "Marcos Vinícius", Email: "marcos@example.com", Phone: "333333333"},
 	}
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patchLoad.Unpatch()
-
-	var savedContacts []models.Contact
-	patchSave := monkey.Patch(storage.SaveContacts, func(contacts []models.Contact) error {
-		savedContacts = contacts
-		return nil
-	})
-	defer patchSave.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
 	// Exercise
-	err := services.DeleteContactById(2)
+	err := services.DeleteContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 2)
+	savedContacts, loadErr := storage.LoadContacts(context.Background(), models.DefaultTenantID)
 
 	// Assert
+	require.NoError(t, loadErr)
 	assert.NoError(t, err)
 	assert.Len(t, savedContacts, 2)
 	assert.Equal(t, 1, savedContacts[0].ID)
@@ -440,18 +418,15 @@ func TestDeleteContactById_SaveError_ExpectedError(t *testing.T) {
 
 	expectedError := errors.New("failed to delete contact")
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patchLoad.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
-	patchSave := monkey.Patch(storage.SaveContacts, func(contacts []models.Contact) error {
-		return expectedError
+	patchDelete := monkey.Patch(storage.DeleteContact, func(ctx context.Context, tenantID string, id int, check func(models.Contact) error) (models.Contact, error) {
+		return models.Contact{}, expectedError
 	})
-	defer patchSave.Unpatch()
+	defer patchDelete.Unpatch()
 
 	// Exercise
-	err := services.DeleteContactById(3)
+	err := services.DeleteContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 3)
 
 	// Assert
 	assert.Error(t, err)
@@ -465,15 +440,12 @@ func TestDeleteContactById_NotFound_ExpectedError(t *testing.T) {
 		{ID: 2, Name: "Carlos Eduardo", Email: "carlos@example.com", Phone: "222222222"},
 	}
 
-	patchLoad := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
-		return mockContacts, nil
-	})
-	defer patchLoad.Unpatch()
+	seedContacts(t, models.DefaultTenantID, mockContacts)
 
 	expectedError := errors.New("contact not found")
 
 	// Act (Exercise)
-	err := services.DeleteContactById(3)
+	err := services.DeleteContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 3)
 
 	// Assert
 	assert.Error(t, err)
@@ -481,6 +453,40 @@ func TestDeleteContactById_NotFound_ExpectedError(t *testing.T) {
 
 }
 
+func TestDeleteContactById_CleanupFails_ExpectedDeletedPublishedAndLogged(t *testing.T) {
+	// Fixture
+	mockContacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda@example.com", Phone: "111111111"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos@example.com", Phone: "222222222"},
+	}
+
+	seedContacts(t, models.DefaultTenantID, mockContacts)
+
+	patchConsents := monkey.Patch(storage.LoadConsents, func(ctx context.Context, tenantID string) ([]models.ConsentRecord, error) {
+		return nil, errors.New("consents unavailable")
+	})
+	defer patchConsents.Unpatch()
+
+	logs, restore := captureLogs(t, "info")
+	defer restore()
+
+	_, _, events, cancel := services.Events.Subscribe(0)
+	defer cancel()
+
+	// Exercise
+	err := services.DeleteContactById(context.Background(), models.DefaultTenantID, models.SharedOwnerID, 2)
+	_, found, getErr := storage.GetContact(context.Background(), models.DefaultTenantID, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	require.NoError(t, getErr)
+	assert.False(t, found)
+	event := receiveEvent(t, events)
+	assert.Equal(t, services.EventContactDeleted, event.Type)
+	assert.Equal(t, 2, event.Contact.ID)
+	assert.Contains(t, logs.String(), "contact consents not removed")
+}
+
 func TestGetAllContacts_Success(t *testing.T) {
 	// Fixture
 	expectedContacts := []models.Contact{
@@ -488,13 +494,13 @@ func TestGetAllContacts_Success(t *testing.T) {
 		{ID: 2, Name: "Bob", Email: "bob@example.com"},
 	}
 
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return expectedContacts, nil
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	contacts, err := services.GetAllContacts()
+	contacts, err := services.GetAllContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.NoError(t, err)
@@ -503,13 +509,13 @@ func TestGetAllContacts_Success(t *testing.T) {
 
 func TestGetContactsSummary_FileNotFound(t *testing.T) {
 	// Fixture
-	patch := monkey.Patch(storage.LoadContacts, func() ([]models.Contact, error) {
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
 		return nil, storage.ErrFileNotFound
 	})
 	defer patch.Unpatch()
 
 	// Exercise
-	summary, err := services.GetContactsSummary()
+	summary, err := services.GetContactsSummary(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
 
 	// Assert
 	assert.Error(t, err)
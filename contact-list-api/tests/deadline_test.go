@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/deadline"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/routes"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/server"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeadlineRouter(config deadline.Config) *gin.Engine {
+	newAuthRouter()
+	deadline.Configure(config)
+	router := gin.New()
+	router.Use(logging.RequestID(), deadline.Requests())
+	routes.SetupRoutes(router)
+	return router
+}
+
+func TestDeadline_SlowStorage_ExpectedGatewayTimeoutAtRouteDeadline(t *testing.T) {
+	// Fixture
+	defer deadline.Configure(deadline.Config{})
+	router := newDeadlineRouter(deadline.Config{
+		Default: time.Minute,
+		Routes:  map[string]time.Duration{"GET /contacts/": 50 * time.Millisecond},
+	})
+
+	var remaining time.Duration
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		if d, ok := ctx.Deadline(); ok {
+			remaining = time.Until(d)
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	defer patch.Unpatch()
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/", nil)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	start := time.Now()
+	router.ServeHTTP(w, req)
+	elapsed := time.Since(start)
+
+	// Assert
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "request timed out")
+	assert.LessOrEqual(t, remaining, 50*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestDeadline_ClientGone_ExpectedClientClosedRequest(t *testing.T) {
+	// Fixture
+	router := newAuthRouter()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/summary", nil).WithContext(ctx)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, 499, w.Code)
+}
+
+func TestSaveContacts_CanceledContext_ExpectedNoWrite(t *testing.T) {
+	// Fixture
+	path := filepath.Join("..", "data", "contacts.json")
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Exercise
+	_, loadErr := storage.LoadContacts(ctx, models.DefaultTenantID)
+	saveErr := storage.SaveContacts(ctx, models.DefaultTenantID, []models.Contact{{ID: 1, Name: "Não gravado"}})
+	after, _ := os.ReadFile(path)
+
+	// Assert
+	assert.ErrorIs(t, loadErr, context.Canceled)
+	assert.ErrorIs(t, saveErr, context.Canceled)
+	assert.Equal(t, before, after)
+}
+
+func TestDeadlineConfigFromEnv_RouteTimeouts_ExpectedOverridesAndStreamsWithout(t *testing.T) {
+	// Fixture
+	t.Setenv("REQUEST_TIMEOUT", "5s")
+	t.Setenv("REQUEST_TIMEOUT_ROUTES", "GET  /contacts/search=2s;GET /contacts/export=0")
+
+	// Exercise
+	config, err := deadline.ConfigFromEnv()
+	t.Setenv("REQUEST_TIMEOUT_ROUTES", "GET /contacts/search=rápido")
+	_, invalidErr := deadline.ConfigFromEnv()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, config.For("GET /contacts/"))
+	assert.Equal(t, 2*time.Second, config.For("GET /contacts/search"))
+	assert.Zero(t, config.For("GET /contacts/export"))
+	assert.Zero(t, config.For("GET /contacts/events"))
+	assert.ErrorIs(t, invalidErr, deadline.ErrInvalidTimeout)
+}
+
+func TestDeadline_SlowContactLookup_ExpectedGatewayTimeoutInsteadOfNotFound(t *testing.T) {
+	// Fixture
+	defer deadline.Configure(deadline.Config{})
+	router := newDeadlineRouter(deadline.Config{
+		Default: time.Minute,
+		Routes:  map[string]time.Duration{"GET /contacts/:id": 50 * time.Millisecond},
+	})
+
+	patch := monkey.Patch(storage.GetContact, func(ctx context.Context, tenantID string, id int) (models.Contact, bool, error) {
+		<-ctx.Done()
+		return models.Contact{}, false, ctx.Err()
+	})
+	defer patch.Unpatch()
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/5", nil)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestDeadline_RouteLongerThanWriteTimeout_ExpectedFullResponseThroughServer(t *testing.T) {
+	// Fixture
+	defer deadline.Configure(deadline.Config{})
+	config := server.DefaultConfig()
+	config.WriteTimeout = 200 * time.Millisecond
+	router := newDeadlineRouter(deadline.Config{
+		Default:      time.Minute,
+		Routes:       map[string]time.Duration{"GET /contacts/export": 5 * time.Second},
+		WriteTimeout: config.WriteTimeout,
+	})
+
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		time.Sleep(3 * config.WriteTimeout)
+		return []models.Contact{{ID: 1, Name: "Fernanda Lima"}}, ctx.Err()
+	})
+	defer patch.Unpatch()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx, listener, router, config, func() {})
+	}()
+	defer func() {
+		cancel()
+		<-served
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+"/contacts/export", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+
+	// Exercise
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, readErr := io.ReadAll(resp.Body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NoError(t, readErr)
+	assert.Contains(t, string(body), "Fernanda Lima")
+}
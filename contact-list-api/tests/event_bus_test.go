@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/handlers"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiveEvent espera o próximo evento por até um segundo, para que um
+// evento que não chega falhe o teste em vez de travá-lo.
+func receiveEvent(t *testing.T, events <-chan services.ContactEvent) services.ContactEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("event not received")
+		return services.ContactEvent{}
+	}
+}
+
+func TestEventBus_Subscribe_ReceivesPublishedEvent(t *testing.T) {
+	// Fixture
+	bus := services.NewEventBus(10)
+	_, _, events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	// Exercise
+	bus.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: 1, Name: "Fernanda Lima"})
+
+	// Assert
+	event := receiveEvent(t, events)
+	assert.Equal(t, int64(1), event.ID)
+	assert.Equal(t, services.EventContactCreated, event.Type)
+	assert.Equal(t, "Fernanda Lima", event.Contact.Name)
+}
+
+func TestEventBus_Subscribe_ResumesFromLastEventID(t *testing.T) {
+	// Fixture
+	bus := services.NewEventBus(10)
+	bus.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: 1})
+	bus.Publish(models.DefaultTenantID, services.EventContactUpdated, models.Contact{ID: 1})
+	bus.Publish(models.DefaultTenantID, services.EventContactDeleted, models.Contact{ID: 1})
+
+	// Exercise
+	backlog, complete, _, cancel := bus.Subscribe(1)
+	defer cancel()
+
+	// Assert
+	assert.True(t, complete)
+	assert.Len(t, backlog, 2)
+	assert.Equal(t, int64(2), backlog[0].ID)
+	assert.Equal(t, int64(3), backlog[1].ID)
+}
+
+func TestEventBus_Since_EvictedEvents_ExpectedIncomplete(t *testing.T) {
+	// Fixture
+	bus := services.NewEventBus(2)
+	for i := 1; i <= 5; i++ {
+		bus.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: i})
+	}
+
+	// Exercise
+	events, complete := bus.Since(1)
+
+	// Assert
+	assert.False(t, complete)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(4), events[0].ID)
+}
+
+func TestEventBus_SlowSubscriber_ExpectedClosedAndResumable(t *testing.T) {
+	// Fixture
+	bus := services.NewEventBus(100)
+	_, _, events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	// Exercise
+	for i := 1; i <= 65; i++ {
+		bus.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: i})
+	}
+	var received []services.ContactEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	backlog, complete, _, resumeCancel := bus.Subscribe(received[len(received)-1].ID)
+	defer resumeCancel()
+
+	// Assert
+	assert.Len(t, received, 64)
+	assert.True(t, complete)
+	require.Len(t, backlog, 1)
+	assert.Equal(t, int64(65), backlog[0].ID)
+}
+
+func TestEventBus_SubscribeDurable_ExpectedNoEventLostWhileBehind(t *testing.T) {
+	// Fixture
+	bus := services.NewEventBus(10)
+	events, cancel := bus.SubscribeDurable()
+	defer cancel()
+
+	// Exercise
+	for i := 1; i <= 200; i++ {
+		bus.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: i})
+	}
+
+	// Assert
+	for i := 1; i <= 200; i++ {
+		assert.Equal(t, int64(i), receiveEvent(t, events).ID)
+	}
+}
+
+func TestContactEventsWebSocket_Origin_ExpectedForeignOriginRejected(t *testing.T) {
+	// Fixture
+	handlers.ConfigureEventsOrigins("https://app.example.com")
+	defer handlers.ConfigureEventsOrigins("")
+	server := httptest.NewServer(newAuthRouter())
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/contacts/events/ws"
+	dial := func(origin string) (*http.Response, error) {
+		header := http.Header{"X-API-Key": {testBootstrapKey}, "Origin": {origin}}
+		conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if err == nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	// Exercise
+	foreign, foreignErr := dial("https://evil.example.net")
+	sameHost, sameHostErr := dial(server.URL)
+	allowed, allowedErr := dial("https://app.example.com")
+
+	// Assert
+	assert.ErrorIs(t, foreignErr, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusForbidden, foreign.StatusCode)
+	assert.NoError(t, sameHostErr)
+	assert.Equal(t, http.StatusSwitchingProtocols, sameHost.StatusCode)
+	assert.NoError(t, allowedErr)
+	assert.Equal(t, http.StatusSwitchingProtocols, allowed.StatusCode)
+}
+
+func TestAddContact_Success_PublishesCreatedEvent(t *testing.T) {
+	// Fixture
+	seedContacts(t, models.DefaultTenantID, []models.Contact{{ID: 1, Name: "Fernanda Lima"}})
+
+	_, _, events, cancel := services.Events.Subscribe(0)
+	defer cancel()
+
+	// Exercise
+	_, err := services.AddContact(context.Background(), models.DefaultTenantID, models.SharedOwnerID, models.Contact{Name: "Carlos Eduardo"})
+
+	// Assert
+	assert.NoError(t, err)
+	event := receiveEvent(t, events)
+	assert.Equal(t, services.EventContactCreated, event.Type)
+	assert.Equal(t, 2, event.Contact.ID)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/fieldcrypt"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encryptionTestTenant isola em data/tenants/ os arquivos gravados por estes
+// testes, que exercitam a cifra no disco de verdade.
+const encryptionTestTenant = "teste-cifra"
+
+// cleanupEncryptionTenant apaga os arquivos do tenant de teste e, se ficar
+// vazio, o diretório data/tenants/.
+func cleanupEncryptionTenant() {
+	storage.DeleteTenantData(context.Background(), encryptionTestTenant)
+	os.Remove(filepath.Join("..", "data", "tenants"))
+}
+
+func testKey(id string, fill byte) fieldcrypt.Key {
+	return fieldcrypt.Key{ID: id, Secret: bytes.Repeat([]byte{fill}, fieldcrypt.KeySize)}
+}
+
+func testKeyring(t *testing.T, keys ...fieldcrypt.Key) *fieldcrypt.Keyring {
+	keyring, err := fieldcrypt.NewKeyring(keys, bytes.Repeat([]byte{0x42}, fieldcrypt.KeySize))
+	require.NoError(t, err)
+	return keyring
+}
+
+func TestKeyring_RotatedKey_ExpectedOldValuesStillReadable(t *testing.T) {
+	// Fixture
+	oldKeyring := testKeyring(t, testKey("2025", 1))
+	rotated := testKeyring(t, testKey("2026", 2), testKey("2025", 1))
+	newOnly := testKeyring(t, testKey("2026", 2))
+
+	aad := []byte("1\x00email")
+	encrypted, err := oldKeyring.Encrypt("fernanda.lima@yahoo.com", aad)
+	require.NoError(t, err)
+
+	// Exercise
+	decrypted, decryptErr := rotated.Decrypt(encrypted, aad)
+	_, unknownErr := newOnly.Decrypt(encrypted, aad)
+
+	// Assert
+	assert.NotContains(t, encrypted, "fernanda")
+	assert.NoError(t, decryptErr)
+	assert.Equal(t, "fernanda.lima@yahoo.com", decrypted)
+	assert.True(t, rotated.NeedsRotation(encrypted))
+	assert.False(t, oldKeyring.NeedsRotation(encrypted))
+	assert.ErrorIs(t, unknownErr, fieldcrypt.ErrUnknownKey)
+	assert.Equal(t, oldKeyring.BlindIndex("email", "fernanda.lima@yahoo.com"), rotated.BlindIndex("email", "fernanda.lima@yahoo.com"))
+}
+
+func TestKeyring_AssociatedData_ExpectedOnlySameDataDecrypts(t *testing.T) {
+	// Fixture
+	keyring := testKeyring(t, testKey("2026", 2))
+
+	encrypted, err := keyring.Encrypt("fernanda.lima@yahoo.com", []byte("1\x00email"))
+	require.NoError(t, err)
+
+	// Exercise
+	decrypted, decryptErr := keyring.Decrypt(encrypted, []byte("1\x00email"))
+	_, otherContactErr := keyring.Decrypt(encrypted, []byte("2\x00email"))
+	_, otherFieldErr := keyring.Decrypt(encrypted, []byte("1\x00phone"))
+	_, withoutDataErr := keyring.Decrypt(encrypted, nil)
+
+	// Assert
+	assert.NoError(t, decryptErr)
+	assert.Equal(t, "fernanda.lima@yahoo.com", decrypted)
+	assert.Error(t, otherContactErr)
+	assert.Error(t, otherFieldErr)
+	assert.Error(t, withoutDataErr)
+	assert.False(t, keyring.NeedsRotation(encrypted))
+}
+
+func TestParseKeyFile_CommentsAndKeys_ExpectedActiveKeyFirst(t *testing.T) {
+	// Fixture
+	data := []byte("# chave atual primeiro\n2026:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=\n\n2025:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\n")
+
+	// Exercise
+	keys, err := fieldcrypt.ParseKeyFile(data)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []fieldcrypt.Key{testKey("2026", 2), testKey("2025", 1)}, keys)
+}
+
+func TestSaveContacts_EncryptionEnabled_ExpectedNoPlaintextAndIndexedSearch(t *testing.T) {
+	// Fixture
+	storage.ConfigureEncryption(testKeyring(t, testKey("2025", 1)))
+	defer storage.ConfigureEncryption(nil)
+	defer cleanupEncryptionTenant()
+
+	contacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "Fernanda.Lima@yahoo.com", Phone: "+55 11 98765-4321"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"},
+	}
+
+	// Exercise
+	err := storage.SaveContacts(context.Background(), encryptionTestTenant, contacts)
+	raw, readErr := os.ReadFile(filepath.Join("..", "data", "tenants", encryptionTestTenant, "contacts.journal"))
+	loaded, loadErr := storage.LoadContacts(context.Background(), encryptionTestTenant)
+	byEmail, emailErr := storage.FindContactsByEmail(context.Background(), encryptionTestTenant, " fernanda.lima@YAHOO.com")
+	byPhone, phoneErr := storage.FindContactsByPhone(context.Background(), encryptionTestTenant, "5511987654321")
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, readErr)
+	assert.NotContains(t, string(raw), "yahoo")
+	assert.NotContains(t, string(raw), "98765")
+	assert.Contains(t, string(raw), "Fernanda Lima")
+
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+	assert.NoError(t, emailErr)
+	assert.Equal(t, contacts[:1], byEmail)
+	assert.NoError(t, phoneErr)
+	assert.Equal(t, contacts[:1], byPhone)
+}
+
+func TestReencryptContacts_NewActiveKey_ExpectedOldKeyNoLongerNeeded(t *testing.T) {
+	// Fixture
+	defer storage.ConfigureEncryption(nil)
+	defer cleanupEncryptionTenant()
+
+	contacts := []models.Contact{{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "551198765432"}}
+	storage.ConfigureEncryption(testKeyring(t, testKey("2025", 1)))
+	require.NoError(t, storage.SaveContacts(context.Background(), encryptionTestTenant, contacts))
+
+	storage.ConfigureEncryption(testKeyring(t, testKey("2026", 2), testKey("2025", 1)))
+
+	// Exercise
+	count, err := storage.ReencryptContacts(context.Background(), encryptionTestTenant)
+	again, againErr := storage.ReencryptContacts(context.Background(), encryptionTestTenant)
+
+	storage.ConfigureEncryption(testKeyring(t, testKey("2026", 2)))
+	loaded, loadErr := storage.LoadContacts(context.Background(), encryptionTestTenant)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.NoError(t, againErr)
+	assert.Equal(t, 0, again)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+}
+
+func TestLoadContacts_EncryptedEmailMovedToOtherContact_ExpectedDecryptError(t *testing.T) {
+	// Fixture
+	keyring := testKeyring(t, testKey("2025", 1))
+	storage.ConfigureEncryption(keyring)
+	defer storage.ConfigureEncryption(nil)
+	defer cleanupEncryptionTenant()
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync, SnapshotEvery: 1})
+	defer storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+
+	contacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+	}
+	require.NoError(t, storage.SaveContacts(context.Background(), encryptionTestTenant, contacts[:1]))
+	require.NoError(t, storage.SaveContacts(context.Background(), encryptionTestTenant, contacts))
+	path := filepath.Join("..", "data", "tenants", encryptionTestTenant, "contacts.json")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var stored []map[string]any
+	require.NoError(t, json.Unmarshal(data, &stored))
+	stored[1]["email"] = stored[0]["email"]
+	data, err = json.Marshal(stored)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	// Exercise
+	storage.ConfigureEncryption(keyring)
+	_, err = storage.LoadContacts(context.Background(), encryptionTestTenant)
+
+	// Assert
+	assert.ErrorContains(t, err, "message authentication failed")
+}
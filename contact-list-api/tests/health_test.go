@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/server"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthProbes_StorageAvailable_ExpectedOKAndNoProbeFileLeft(t *testing.T) {
+	// Fixture
+	router := newAuthRouter()
+	probe := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// Exercise
+	health := probe("/healthz")
+	ready := probe("/readyz")
+	leftovers, _ := filepath.Glob(filepath.Join("..", "data", ".readyz-*"))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, health)
+	assert.Equal(t, http.StatusOK, ready)
+	assert.Empty(t, leftovers)
+}
+
+func TestReadyz_StorageUnavailable_ExpectedServiceUnavailable(t *testing.T) {
+	// Fixture
+	patch := monkey.Patch(storage.CheckHealth, func(ctx context.Context) error {
+		return errors.New("data store not writable: read-only file system")
+	})
+	defer patch.Unpatch()
+
+	router := newAuthRouter()
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "read-only file system")
+}
+
+func TestServe_ShutdownDuringRequest_ExpectedRequestDrainedAndListenerClosed(t *testing.T) {
+	// Fixture
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := "http://" + listener.Addr().String()
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("gravado"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdownCalled := make(chan struct{})
+	config := server.DefaultConfig()
+	config.ShutdownTimeout = 5 * time.Second
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx, listener, handler, config, func() { close(shutdownCalled) })
+	}()
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(addr)
+		if err == nil {
+			responses <- resp
+		}
+		close(responses)
+	}()
+
+	// Exercise
+	<-started
+	cancel()
+	<-shutdownCalled
+	serveErr := <-served
+	resp := <-responses
+	_, afterErr := http.Get(addr)
+
+	// Assert
+	assert.NoError(t, serveErr)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Error(t, afterErr)
+}
+
+func TestServerConfigFromEnv_Durations_ExpectedParsedOrError(t *testing.T) {
+	// Fixture
+	t.Setenv("SERVER_WRITE_TIMEOUT", "2m")
+	t.Setenv("SHUTDOWN_TIMEOUT", "5s")
+
+	// Exercise
+	config, err := server.ConfigFromEnv()
+	t.Setenv("SERVER_IDLE_TIMEOUT", "um minuto")
+	_, invalidErr := server.ConfigFromEnv()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, config.WriteTimeout)
+	assert.Equal(t, 5*time.Second, config.ShutdownTimeout)
+	assert.Equal(t, 15*time.Second, config.ReadTimeout)
+	assert.ErrorContains(t, invalidErr, "SERVER_IDLE_TIMEOUT")
+}
+
+func TestServerConfigFromEnv_TrustedProxies_ExpectedParsedOrError(t *testing.T) {
+	// Fixture
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.10")
+
+	// Exercise
+	config, err := server.ConfigFromEnv()
+	t.Setenv("TRUSTED_PROXIES", "proxy.interno")
+	_, invalidErr := server.ConfigFromEnv()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.10"}, config.TrustedProxies)
+	assert.ErrorContains(t, invalidErr, "TRUSTED_PROXIES")
+}
+
+func TestFlush_DataDirectory_ExpectedNoError(t *testing.T) {
+	// Exercise
+	err := storage.Flush(context.Background())
+	_, statErr := os.Stat(filepath.Join("..", "data", "contacts.json"))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, statErr)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptContactsFile tem um erro de sintaxe na linha 3, coluna 43: o
+// e-mail do contato 2 ficou sem valor.
+const corruptContactsFile = `[
+  {"id": 1, "name": "Contato 1"},
+  {"id": 2, "name": "Contato 2", "email": , "phone": ""},
+  {"id": 3, "name": "Contato 3"}
+]`
+
+func TestCheckIntegrity_SyntaxError_ExpectedExactLocation(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	seedReloadTenant(t)
+	reopenRepositoryTenant(t)
+	require.NoError(t, os.WriteFile(repositoryTestFile(), []byte(corruptContactsFile), 0644))
+
+	// Exercise
+	report, err := storage.CheckIntegrity(ctx, repositoryTestTenant)
+	_, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, report.Healthy)
+	assert.Equal(t, "contacts.json", report.Files[0].File)
+	assert.Equal(t, storage.FileCorrupt, report.Files[0].Status)
+	assert.Equal(t, 3, report.Files[0].Line)
+	assert.Equal(t, 43, report.Files[0].Column)
+	assert.Equal(t, storage.FileOK, report.Files[1].Status)
+	assert.Equal(t, storage.FileOK, report.Files[2].Status)
+	assert.ErrorIs(t, loadErr, storage.ErrCorruptFile)
+	assert.Contains(t, loadErr.Error(), "contacts.json:3:43: ")
+}
+
+func TestRecoverContacts_BackupMode_ExpectedBackupRestoredAndJournalReplayed(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := seedReloadTenant(t)
+	reopenRepositoryTenant(t)
+	require.NoError(t, os.WriteFile(repositoryTestFile(), []byte(corruptContactsFile), 0644))
+
+	// Exercise
+	result, err := storage.RecoverContacts(ctx, repositoryTestTenant, storage.RecoveryBackup)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+	report, _ := storage.CheckIntegrity(ctx, repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(result.Source, "backups"+string(filepath.Separator)+"contacts-"))
+	assert.Equal(t, len(contacts), result.Recovered)
+	require.Len(t, result.Quarantined, 1)
+	quarantined, _ := os.ReadFile(repositoryTestPath(result.Quarantined[0]))
+	assert.Equal(t, corruptContactsFile, string(quarantined))
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+	assert.True(t, report.Healthy)
+}
+
+func TestRecoverContacts_SalvageMode_ExpectedParseableRecordsKept(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	seedReloadTenant(t)
+	reopenRepositoryTenant(t)
+	require.NoError(t, os.RemoveAll(repositoryTestPath("backups")))
+	require.NoError(t, os.Remove(repositoryTestJournal()))
+	require.NoError(t, os.WriteFile(repositoryTestFile(), []byte(corruptContactsFile), 0644))
+
+	// Exercise
+	result, err := storage.RecoverContacts(ctx, repositoryTestTenant, storage.RecoverySalvage)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "salvage", result.Source)
+	assert.Equal(t, 2, result.Recovered)
+	assert.Equal(t, 1, result.Dropped)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, []models.Contact{{ID: 1, Name: "Contato 1"}, {ID: 3, Name: "Contato 3"}}, loaded)
+}
+
+func TestRecoverContacts_BackupModeWithoutBackup_ExpectedNoGoodBackupAndFileKept(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	seedReloadTenant(t)
+	reopenRepositoryTenant(t)
+	require.NoError(t, os.RemoveAll(repositoryTestPath("backups")))
+	require.NoError(t, os.WriteFile(repositoryTestFile(), []byte(corruptContactsFile), 0644))
+
+	// Exercise
+	_, err := storage.RecoverContacts(ctx, repositoryTestTenant, storage.RecoveryBackup)
+	after, _ := os.ReadFile(repositoryTestFile())
+
+	// Assert
+	assert.ErrorIs(t, err, storage.ErrNoGoodBackup)
+	assert.Equal(t, corruptContactsFile, string(after))
+	assert.NoDirExists(t, repositoryTestPath("quarantine"))
+}
+
+func TestRecoverContacts_LoadedTenant_ExpectedRewrittenFromMemory(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := seedReloadTenant(t)
+	require.NoError(t, os.WriteFile(repositoryTestFile(), []byte(corruptContactsFile), 0644))
+
+	// Exercise
+	result, err := storage.RecoverContacts(ctx, repositoryTestTenant, storage.RecoverySalvage)
+	reopenRepositoryTenant(t)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "memory", result.Source)
+	assert.Equal(t, len(contacts), result.Recovered)
+	assert.Zero(t, result.Dropped)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+}
+
+func TestRecoverContacts_CorruptJournalRecord_ExpectedRecordDroppedAndRestReplayed(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	saveJournalVersions(t, 3)
+	reopenRepositoryTenant(t)
+	data, err := os.ReadFile(repositoryTestJournal())
+	require.NoError(t, err)
+	corrupt := strings.Replace(string(data), `"Contato 2"`, `"Contato X"`, 1)
+	require.NoError(t, os.WriteFile(repositoryTestJournal(), []byte(corrupt), 0644))
+
+	// Exercise
+	report, _ := storage.CheckIntegrity(ctx, repositoryTestTenant)
+	result, err := storage.RecoverContacts(ctx, repositoryTestTenant, storage.RecoveryBackup)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+	journal, _ := os.ReadFile(repositoryTestJournal())
+
+	// Assert
+	assert.Equal(t, storage.FileCorrupt, report.Files[2].Status)
+	assert.Equal(t, 2, report.Files[2].Line)
+	require.NoError(t, err)
+	assert.Empty(t, result.Source)
+	assert.Equal(t, 1, result.Dropped)
+	assert.Len(t, result.Quarantined, 1)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, []models.Contact{{ID: 1, Name: "Contato 1"}, {ID: 3, Name: "Contato 3"}}, loaded)
+	assert.Empty(t, journal)
+}
+
+func TestPurgeContactHistory_AfterDelete_ExpectedOldCopiesRewrittenWithoutContact(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := seedReloadTenant(t)
+	journal, _ := os.ReadFile(repositoryTestJournal())
+	require.NoError(t, os.WriteFile(repositoryTestFile(), []byte(corruptContactsFile), 0644))
+	_, err := storage.RecoverContacts(ctx, repositoryTestTenant, storage.RecoveryBackup)
+	require.NoError(t, err)
+	quarantinedJournal := repositoryTestPath("quarantine", "contacts.journal.20250101T120000.000000000Z")
+	require.NoError(t, os.WriteFile(quarantinedJournal, append(journal, `{"crc": 1, "rec`...), 0644))
+	backupsBefore, _ := os.ReadDir(repositoryTestPath("backups"))
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts[:2]))
+
+	// Exercise
+	err = storage.PurgeContactHistory(ctx, repositoryTestTenant, []int{3})
+	reopenRepositoryTenant(t)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	quarantined, _ := os.ReadDir(repositoryTestPath("quarantine"))
+	assert.Len(t, quarantined, 2)
+	backups, _ := os.ReadDir(repositoryTestPath("backups"))
+	assert.GreaterOrEqual(t, len(backups), len(backupsBefore))
+	journal, _ = os.ReadFile(repositoryTestJournal())
+	assert.Empty(t, journal)
+	err = filepath.WalkDir(filepath.Dir(repositoryTestFile()), func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		assert.NotContains(t, string(data), "Contato 3", path)
+		assert.NotContains(t, string(data), `{"crc": 1, "rec`, path)
+		return err
+	})
+	assert.NoError(t, err)
+	for _, entry := range backups {
+		data, _ := os.ReadFile(repositoryTestPath("backups", entry.Name()))
+		assert.True(t, json.Valid(data), entry.Name())
+	}
+	quarantinedContacts, _ := filepath.Glob(repositoryTestPath("quarantine", "contacts.json.*"))
+	require.Len(t, quarantinedContacts, 1)
+	data, _ := os.ReadFile(quarantinedContacts[0])
+	assert.Contains(t, string(data), "Contato 1")
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts[:2], loaded)
+}
+
+func TestRecoveryModeFromEnv_Values_ExpectedModeOrError(t *testing.T) {
+	// Fixture
+	cases := map[string]storage.RecoveryMode{"": storage.RecoveryBackup, "none": storage.RecoveryNone, "salvage": storage.RecoverySalvage}
+
+	for value, expected := range cases {
+		t.Setenv("STORAGE_RECOVERY", value)
+
+		// Exercise
+		mode, err := storage.RecoveryModeFromEnv()
+
+		// Assert
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, mode, value)
+	}
+
+	t.Setenv("STORAGE_RECOVERY", "restore")
+	_, err := storage.RecoveryModeFromEnv()
+	assert.ErrorIs(t, err, storage.ErrInvalidRecoveryMode)
+}
+
+func TestRecoverStorage_InvalidMode_ExpectedBadRequest(t *testing.T) {
+	// Fixture
+	router := newAuthRouter()
+	body, _ := json.Marshal(map[string]string{"mode": "none"})
+	req, _ := http.NewRequest(http.MethodPost, "/admin/storage/recover", bytes.NewReader(body))
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid recovery mode")
+}
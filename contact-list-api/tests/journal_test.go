@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveJournalVersions grava n versões da lista, uma por SaveContacts, e
+// devolve a última.
+func saveJournalVersions(t *testing.T, n int) []models.Contact {
+	var contacts []models.Contact
+	for i := 1; i <= n; i++ {
+		contacts = append(contacts, models.Contact{ID: i, Name: fmt.Sprintf("Contato %d", i)})
+		require.NoError(t, storage.SaveContacts(context.Background(), repositoryTestTenant, contacts))
+	}
+	return contacts
+}
+
+// reopenRepositoryTenant tira o tenant de teste da memória, como um
+// reinício, para que o próximo acesso leia snapshot e journal.
+func reopenRepositoryTenant(t *testing.T) {
+	require.NoError(t, storage.ReopenTenant(context.Background(), repositoryTestTenant))
+}
+
+func TestLoadContacts_AfterRestart_ExpectedJournalReplayedOverSnapshot(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := saveJournalVersions(t, 3)
+	contacts = contacts[1:]
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts))
+	expectedLog, _ := storage.LoadChangeLog(ctx, repositoryTestTenant)
+
+	// Exercise
+	reopenRepositoryTenant(t)
+	snapshot, _ := os.ReadFile(repositoryTestFile())
+	loaded, err := storage.LoadContacts(ctx, repositoryTestTenant)
+	changeLog, logErr := storage.LoadChangeLog(ctx, repositoryTestTenant)
+
+	// Assert
+	assert.NotContains(t, string(snapshot), "Contato")
+	assert.NoError(t, err)
+	assert.Equal(t, contacts, loaded)
+	assert.NoError(t, logErr)
+	assert.Equal(t, expectedLog, changeLog)
+}
+
+func TestLoadContacts_TornFinalRecord_ExpectedTruncatedAndEarlierRecordsKept(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	contacts := saveJournalVersions(t, 2)
+	intact, err := os.ReadFile(repositoryTestJournal())
+	require.NoError(t, err)
+	torn := append(append([]byte(nil), intact...), `{"crc":12345,"record":{"last_seq":3,"puts":[{"id":3,"na`...)
+	require.NoError(t, os.WriteFile(repositoryTestJournal(), torn, 0644))
+	out, restore := captureLogs(t, "info")
+	defer restore()
+
+	// Exercise
+	reopenRepositoryTenant(t)
+	loaded, loadErr := storage.LoadContacts(context.Background(), repositoryTestTenant)
+	after, _ := os.ReadFile(repositoryTestJournal())
+
+	// Assert
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+	assert.Equal(t, intact, after)
+	assert.Contains(t, out.String(), "journal torn record truncated")
+}
+
+func TestLoadContacts_CorruptMiddleRecord_ExpectedJournalCorruptError(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	saveJournalVersions(t, 2)
+	data, err := os.ReadFile(repositoryTestJournal())
+	require.NoError(t, err)
+	corrupt := strings.Replace(string(data), "Contato 1", "Contato X", 1)
+	require.NoError(t, os.WriteFile(repositoryTestJournal(), []byte(corrupt), 0644))
+
+	// Exercise
+	reopenRepositoryTenant(t)
+	_, loadErr := storage.LoadContacts(context.Background(), repositoryTestTenant)
+	after, _ := os.ReadFile(repositoryTestJournal())
+
+	// Assert
+	assert.ErrorIs(t, loadErr, storage.ErrJournalCorrupt)
+	assert.Contains(t, loadErr.Error(), "record 1")
+	assert.Equal(t, corrupt, string(after))
+}
+
+func TestSaveContacts_SnapshotEveryReached_ExpectedJournalCompacted(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync, SnapshotEvery: 2})
+	defer storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+
+	// Exercise
+	twoRecords := saveJournalVersions(t, 2)
+	beforeSnapshot, _ := os.ReadFile(repositoryTestJournal())
+	contacts := append(twoRecords, models.Contact{ID: 3, Name: "Contato 3"})
+	err := storage.SaveContacts(context.Background(), repositoryTestTenant, contacts)
+	journal, _ := os.ReadFile(repositoryTestJournal())
+	snapshot, _ := os.ReadFile(repositoryTestFile())
+	reopenRepositoryTenant(t)
+	loaded, loadErr := storage.LoadContacts(context.Background(), repositoryTestTenant)
+
+	// Assert
+	assert.Equal(t, 2, strings.Count(string(beforeSnapshot), "\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, journal)
+	assert.Contains(t, string(snapshot), "Contato 3")
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts, loaded)
+}
+
+func TestSaveContacts_JournalWriteFails_ExpectedJournalTruncatedToPreviousRecord(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := saveJournalVersions(t, 2)
+	before, _ := os.ReadFile(repositoryTestJournal())
+	var guard *monkey.PatchGuard
+	guard = monkey.PatchInstanceMethod(reflect.TypeOf(&os.File{}), "Write", func(f *os.File, b []byte) (int, error) {
+		guard.Unpatch()
+		defer guard.Restore()
+		if filepath.Base(f.Name()) != "contacts.journal" {
+			return f.Write(b)
+		}
+		n, _ := f.Write(b[:len(b)/2])
+		return n, errors.New("no space left on device")
+	})
+
+	// Exercise
+	err := storage.SaveContacts(ctx, repositoryTestTenant, append(contacts, models.Contact{ID: 3, Name: "Contato 3"}))
+	guard.Unpatch()
+	after, _ := os.ReadFile(repositoryTestJournal())
+	saveErr := storage.SaveContacts(ctx, repositoryTestTenant, append(contacts, models.Contact{ID: 3, Name: "Contato 3 de novo"}))
+	reopenRepositoryTenant(t)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, before, after)
+	assert.NoError(t, saveErr)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, append(contacts, models.Contact{ID: 3, Name: "Contato 3 de novo"}), loaded)
+}
+
+// seedErasureHistory deixa o e-mail do titular num backup, no journal e em
+// cópias de contacts.json e do journal na quarentena do tenant de teste.
+func seedErasureHistory(t *testing.T, email string) {
+	ctx := context.Background()
+	contacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: email},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+	}
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync, SnapshotEvery: 1})
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts[:1]))
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts))
+	storage.ConfigurePersistence(storage.PersistenceConfig{Durability: storage.DurabilitySync})
+	contacts[0].Name = "Fernanda Lima Souza"
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts))
+
+	stamp := ".20250101T120000.000000000Z"
+	require.NoError(t, os.MkdirAll(repositoryTestPath("quarantine"), 0700))
+	for _, name := range []string{"contacts.json", "contacts.journal"} {
+		data, err := os.ReadFile(repositoryTestPath(name))
+		require.NoError(t, err)
+		require.Contains(t, string(data), email, name)
+		require.NoError(t, os.WriteFile(repositoryTestPath("quarantine", name+stamp), data, 0644))
+	}
+	backups, _ := os.ReadDir(repositoryTestPath("backups"))
+	require.NotEmpty(t, backups)
+}
+
+// tenantFilesContaining lista os arquivos do tenant de teste com text.
+func tenantFilesContaining(t *testing.T, text string) []string {
+	var found []string
+	err := filepath.WalkDir(filepath.Dir(repositoryTestFile()), func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if strings.Contains(string(data), text) {
+			found = append(found, path)
+		}
+		return err
+	})
+	require.NoError(t, err)
+	return found
+}
+
+func TestConfirmErasure_SubjectInOldCopies_ExpectedErasedFromBackupsQuarantineAndJournal(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	seedErasureHistory(t, "fernanda.lima@yahoo.com")
+	erasure, err := services.RequestErasure(ctx, repositoryTestTenant, "fernanda.lima@yahoo.com", "")
+	require.NoError(t, err)
+
+	// Exercise
+	entry, err := services.ConfirmErasure(ctx, repositoryTestTenant, "bootstrap", erasure.ConfirmationToken, "fernanda.lima@yahoo.com", "")
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, entry.Verified)
+	assert.Empty(t, tenantFilesContaining(t, "fernanda.lima@yahoo.com"))
+}
+
+func TestConfirmErasure_OldCopiesNotPurged_ExpectedNotVerified(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	seedErasureHistory(t, "fernanda.lima@yahoo.com")
+	patch := monkey.Patch(storage.PurgeContactHistory, func(ctx context.Context, tenantID string, ids []int) error {
+		return nil
+	})
+	defer patch.Unpatch()
+	erasure, err := services.RequestErasure(ctx, repositoryTestTenant, "fernanda.lima@yahoo.com", "")
+	require.NoError(t, err)
+
+	// Exercise
+	entry, err := services.ConfirmErasure(ctx, repositoryTestTenant, "bootstrap", erasure.ConfirmationToken, "fernanda.lima@yahoo.com", "")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrErasureNotVerified)
+	assert.False(t, entry.Verified)
+	assert.NotEmpty(t, tenantFilesContaining(t, "fernanda.lima@yahoo.com"))
+}
+
+func TestConfirmErasure_SubjectInJournal_ExpectedJournalCompacted(t *testing.T) {
+	// Fixture
+	useRepositoryTenant(t)
+	ctx := context.Background()
+	contacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "+55 11 98765-4321"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+	}
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts[:1]))
+	require.NoError(t, storage.SaveContacts(ctx, repositoryTestTenant, contacts))
+	journal, _ := os.ReadFile(repositoryTestJournal())
+	require.Contains(t, string(journal), "Fernanda Lima")
+	erasure, err := services.RequestErasure(ctx, repositoryTestTenant, "fernanda.lima@yahoo.com", "")
+	require.NoError(t, err)
+
+	// Exercise
+	_, err = services.ConfirmErasure(ctx, repositoryTestTenant, "bootstrap", erasure.ConfirmationToken, "fernanda.lima@yahoo.com", "")
+	journal, _ = os.ReadFile(repositoryTestJournal())
+	reopenRepositoryTenant(t)
+	loaded, loadErr := storage.LoadContacts(ctx, repositoryTestTenant)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, journal)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, contacts[1:], loaded)
+}
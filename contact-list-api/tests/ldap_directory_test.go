@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/directory"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestLDAPServer(t *testing.T, config directory.Config) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server, err := directory.NewServer(config)
+	require.NoError(t, err)
+	go server.Run(addr)
+	t.Cleanup(func() { server.Stop() })
+
+	require.Eventually(t, server.Ready, 2*time.Second, 10*time.Millisecond)
+	return addr
+}
+
+func TestParseFilter_NestedFilter_ExpectedDirectoryQuery(t *testing.T) {
+	// Exercise
+	filter, err := directory.ParseFilter("(&(objectClass=inetOrgPerson)(|(cn=Fern*)(mail=*@gmail.com)))")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, services.FilterAnd, filter.Op)
+	assert.Len(t, filter.Children, 2)
+	assert.Equal(t, services.FilterOr, filter.Children[1].Op)
+	assert.Equal(t, services.DirectoryFilter{Op: services.FilterSubstring, Attribute: "cn", Initial: "Fern"}, filter.Children[1].Children[0])
+	assert.Equal(t, services.DirectoryFilter{Op: services.FilterSubstring, Attribute: "mail", Final: "@gmail.com"}, filter.Children[1].Children[1])
+}
+
+func TestParseFilter_Malformed_ExpectedError(t *testing.T) {
+	// Exercise
+	_, err := directory.ParseFilter("(&(cn=Fernanda)")
+
+	// Assert
+	assert.ErrorIs(t, err, directory.ErrInvalidFilter)
+}
+
+func TestSearchDirectory_TelephoneNumber_IgnoresSeparators(t *testing.T) {
+	// Fixture
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return []models.Contact{
+			{ID: 1, Name: "Fernanda Lima", Phone: "551198765432"},
+			{ID: 2, Name: "Carlos Eduardo", Phone: "551199998877"},
+		}, nil
+	})
+	defer patch.Unpatch()
+
+	filter, err := directory.ParseFilter("(telephoneNumber=55 11 9876-5432)")
+	assert.NoError(t, err)
+
+	// Exercise
+	results, err := services.SearchDirectory(context.Background(), models.DefaultTenantID, models.SharedOwnerID, filter)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Contact{{ID: 1, Name: "Fernanda Lima", Phone: "551198765432"}}, results)
+}
+
+func TestLDAPServer_BindAndSearch_ExpectedInetOrgPersonEntries(t *testing.T) {
+	// Fixture
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return []models.Contact{
+			{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "551198765432"},
+			{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"},
+		}, nil
+	})
+	defer patch.Unpatch()
+
+	addr := startTestLDAPServer(t, directory.Config{BindDN: "cn=printer,dc=contact-list,dc=local", BindPassword: "segredo"})
+
+	conn, err := ldap.DialURL("ldap://" + addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Exercise
+	bindErr := conn.Bind("cn=printer,dc=contact-list,dc=local", "segredo")
+	result, searchErr := conn.Search(ldap.NewSearchRequest(
+		directory.DefaultBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(&(objectClass=inetOrgPerson)(mail=*@gmail.com))",
+		[]string{"cn", "mail", "telephoneNumber"}, nil,
+	))
+
+	// Assert
+	assert.NoError(t, bindErr)
+	require.NoError(t, searchErr)
+	require.Len(t, result.Entries, 1)
+	assert.Equal(t, "uid=contact-2,"+directory.DefaultBaseDN, result.Entries[0].DN)
+	assert.Equal(t, "Carlos Eduardo", result.Entries[0].GetAttributeValue("cn"))
+	assert.Equal(t, "551199998877", result.Entries[0].GetAttributeValue("telephoneNumber"))
+}
+
+func TestLDAPServer_SearchWithoutBind_ExpectedInsufficientAccess(t *testing.T) {
+	// Fixture
+	addr := startTestLDAPServer(t, directory.Config{BindDN: "cn=printer,dc=contact-list,dc=local", BindPassword: "segredo"})
+
+	conn, err := ldap.DialURL("ldap://" + addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Exercise
+	_, err = conn.Search(ldap.NewSearchRequest(
+		directory.DefaultBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(cn=*)", nil, nil,
+	))
+
+	// Assert
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultInsufficientAccessRights))
+}
+
+func TestNewServer_NoBindDN_ExpectedBindRequired(t *testing.T) {
+	// Exercise
+	_, err := directory.NewServer(directory.Config{})
+
+	// Assert
+	assert.ErrorIs(t, err, directory.ErrBindRequired)
+}
+
+func TestLDAPServer_AnonymousBind_ExpectedRefusedUnlessAllowed(t *testing.T) {
+	// Fixture
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return []models.Contact{{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"}}, nil
+	})
+	defer patch.Unpatch()
+	_, unpatch := patchAuditStorage()
+	defer unpatch()
+
+	search := func(config directory.Config) (error, error) {
+		conn, err := ldap.DialURL("ldap://" + startTestLDAPServer(t, config))
+		require.NoError(t, err)
+		defer conn.Close()
+		bindErr := conn.UnauthenticatedBind("")
+		_, searchErr := conn.Search(ldap.NewSearchRequest(
+			directory.DefaultBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			"(cn=*)", []string{"cn"}, nil,
+		))
+		return bindErr, searchErr
+	}
+
+	// Exercise
+	refusedBind, refusedSearch := search(directory.Config{BindDN: "cn=printer,dc=contact-list,dc=local", BindPassword: "segredo"})
+	allowedBind, allowedSearch := search(directory.Config{AllowAnonymous: true})
+
+	// Assert
+	assert.True(t, ldap.IsErrorWithCode(refusedBind, ldap.LDAPResultInvalidCredentials))
+	assert.True(t, ldap.IsErrorWithCode(refusedSearch, ldap.LDAPResultInsufficientAccessRights))
+	assert.NoError(t, allowedBind)
+	assert.NoError(t, allowedSearch)
+}
+
+func TestLDAPServer_Search_ExpectedAuditEntryWithBindDN(t *testing.T) {
+	// Fixture
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return []models.Contact{
+			{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"},
+			{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+		}, nil
+	})
+	defer patch.Unpatch()
+	_, unpatch := patchAuditStorage()
+	defer unpatch()
+	recorded := make(chan models.AuditEntry, 1)
+	patchAppend := monkey.Patch(storage.AppendAuditEntry, func(ctx context.Context, tenantID string, entry models.AuditEntry) error {
+		recorded <- entry
+		return nil
+	})
+	defer patchAppend.Unpatch()
+
+	addr := startTestLDAPServer(t, directory.Config{BindDN: "cn=printer,dc=contact-list,dc=local", BindPassword: "segredo"})
+	conn, err := ldap.DialURL("ldap://" + addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Bind("cn=printer,dc=contact-list,dc=local", "segredo"))
+
+	// Exercise
+	_, err = conn.Search(ldap.NewSearchRequest(
+		directory.DefaultBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(mail=*@gmail.com)", []string{"cn"}, nil,
+	))
+
+	// Assert
+	require.NoError(t, err)
+	select {
+	case entry := <-recorded:
+		assert.Equal(t, "ldap:cn=printer,dc=contact-list,dc=local", entry.Actor)
+		assert.Equal(t, models.AuditSearch, entry.Action)
+		assert.Equal(t, []int{2}, entry.ContactIDs)
+	case <-time.After(2 * time.Second):
+		t.Fatal("audit entry was not recorded")
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/routes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs instala o logger JSON escrevendo em memória e restaura o
+// anterior no fim.
+func captureLogs(t *testing.T, level string) (*bytes.Buffer, func()) {
+	previous := slog.Default()
+	var out bytes.Buffer
+	require.NoError(t, logging.Setup(&out, level))
+	return &out, func() {
+		slog.SetDefault(previous)
+		logging.SetLevel("info")
+	}
+}
+
+func TestRequestID_IncomingAndInvalidHeaders_ExpectedAcceptedOrReplaced(t *testing.T) {
+	// Fixture
+	router := newAuthRouter()
+	request := func(id string) string {
+		req := httptest.NewRequest(http.MethodGet, "/public/contacts/token-invalido", nil)
+		if id != "" {
+			req.Header.Set(logging.RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header().Get(logging.RequestIDHeader)
+	}
+
+	// Exercise
+	accepted := request("pedido-42")
+	generated := request("")
+	replaced := request("com espaço\ne quebra")
+
+	// Assert
+	assert.Equal(t, "pedido-42", accepted)
+	assert.Len(t, generated, 32)
+	assert.Len(t, replaced, 32)
+	assert.NotEqual(t, generated, replaced)
+}
+
+func TestSetup_ContextWithRequestID_ExpectedJSONLineWithRequestIDAndLevelFilter(t *testing.T) {
+	// Fixture
+	out, restore := captureLogs(t, "info")
+	defer restore()
+	ctx := logging.WithRequestID(context.Background(), "pedido-7")
+
+	// Exercise
+	slog.DebugContext(ctx, "ignorado")
+	slog.InfoContext(ctx, "contacts loaded", "count", 3)
+	require.NoError(t, logging.SetLevel("debug"))
+	slog.DebugContext(ctx, "agora aparece")
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "contacts loaded", entry["msg"])
+	assert.Equal(t, "pedido-7", entry["request_id"])
+	assert.Equal(t, float64(3), entry["count"])
+	assert.Contains(t, lines[1], "agora aparece")
+	assert.Equal(t, "debug", logging.Level())
+}
+
+func TestRequests_SearchByEmail_ExpectedRedactedPathAndRequestID(t *testing.T) {
+	// Fixture
+	out, restore := captureLogs(t, "info")
+	defer restore()
+
+	router := gin.New()
+	router.Use(logging.RequestID(), logging.Requests())
+	routes.SetupRoutes(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/search?email=fernanda.lima%40yahoo.com", nil)
+	req.Header.Set(logging.RequestIDHeader, "pedido-9")
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+
+	// Assert
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "/contacts/search?email=[email]", entry["path"])
+	assert.Equal(t, "pedido-9", entry["request_id"])
+	assert.Equal(t, float64(http.StatusUnauthorized), entry["status"])
+}
+
+func TestRequests_PublicContactToken_ExpectedTokenRedacted(t *testing.T) {
+	// Fixture
+	out, restore := captureLogs(t, "info")
+	defer restore()
+
+	router := gin.New()
+	router.Use(logging.RequestID(), logging.Requests())
+	routes.SetupRoutes(router)
+
+	token := "ZGVmYXVsdC4xLjE3NjAwMDAwMDAubm9uY2U.c2lnbmF0dXJh"
+	req := httptest.NewRequest(http.MethodGet, "/public/contacts/"+token+"?format=vcard", nil)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+
+	// Assert
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "/public/contacts/[redacted]?format=vcard", entry["path"])
+	assert.Equal(t, "/public/contacts/:token", entry["route"])
+	assert.NotContains(t, out.String(), token)
+}
+
+func TestSetLogLevel_BootstrapKey_ExpectedLevelChangedAndInvalidRejected(t *testing.T) {
+	// Fixture
+	_, restore := captureLogs(t, "info")
+	defer restore()
+	router := newAuthRouter()
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(body))
+		req.Header.Set("X-API-Key", testBootstrapKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exercise
+	changed := put(`{"level":"warn"}`)
+	invalid := put(`{"level":"verbose"}`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.JSONEq(t, `{"level":"warn"}`, changed.Body.String())
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	assert.Equal(t, "warn", logging.Level())
+}
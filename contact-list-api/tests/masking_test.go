@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/masking"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func getContactsWithRole(t *testing.T, role, path string) *httptest.ResponseRecorder {
+	plaintext := "clk_0a1b2c3d_" + strings.Repeat("ab", 32)
+	defer patchAPIKeyStorage([]models.APIKey{
+		{ID: 1, Name: role, Prefix: "clk_0a1b2c3d", Hash: services.HashAPIKey(plaintext), Role: role},
+	})()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-API-Key", plaintext)
+	w := httptest.NewRecorder()
+	newAuthRouter().ServeHTTP(w, req)
+	return w
+}
+
+func TestMaskEmailAndPhone_Values_ExpectedPartialMasks(t *testing.T) {
+	// Exercise & Assert
+	assert.Equal(t, "j***@email.com", masking.MaskEmail("joao@email.com"))
+	assert.Equal(t, "", masking.MaskEmail(""))
+	assert.Equal(t, "119****8888", masking.MaskPhone("(11) 99999-8888"))
+	assert.Equal(t, "119****8888", masking.MaskPhone("119****8888"))
+	assert.Equal(t, "*****", masking.MaskPhone("12345"))
+}
+
+func TestMaskingResponses_InternAndReader_ExpectedMaskedOnlyForIntern(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: models.SharedOwnerID, Name: "João da Silva", Email: "joao@email.com", Phone: "11999998888"},
+	})()
+
+	// Exercise
+	intern := getContactsWithRole(t, models.RoleIntern, "/contacts/")
+	internCSV := getContactsWithRole(t, models.RoleIntern, "/contacts/export?format=csv")
+	reader := getContactsWithRole(t, models.RoleReader, "/contacts/")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, intern.Code)
+	assert.JSONEq(t, `[{"id":1,"name":"João da Silva","email":"j***@email.com","phone":"119****8888"}]`, intern.Body.String())
+	assert.Contains(t, internCSV.Body.String(), "1,João da Silva,j***@email.com,119****8888")
+	assert.JSONEq(t, `[{"id":1,"name":"João da Silva","email":"joao@email.com","phone":"11999998888"}]`, reader.Body.String())
+}
+
+func TestParsePolicy_CustomPolicy_ExpectedFieldsPerRole(t *testing.T) {
+	// Exercise
+	policy, err := masking.ParsePolicy("intern=email,phone; reader=phone")
+	_, invalidErr := masking.ParsePolicy("intern=cpf")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, models.Contact{Name: "João", Email: "joao@email.com", Phone: "119****8888"},
+		policy.Contact(models.RoleReader, models.Contact{Name: "João", Email: "joao@email.com", Phone: "11999998888"}))
+	assert.False(t, policy.Masks(models.RoleAdmin))
+	assert.ErrorIs(t, invalidErr, masking.ErrInvalidPolicy)
+}
+
+func TestRedactText_RequestPath_ExpectedEmailAndPhoneRedacted(t *testing.T) {
+	// Exercise
+	emailPath := masking.RedactText("/contacts/search?email=fernanda.lima%40yahoo.com")
+	phonePath := masking.RedactText("/contacts/search?phone=%2B55+11+98765-4321&name=Ana")
+	idPath := masking.RedactText("/contacts/42?last_event_id=1050")
+
+	// Assert
+	assert.Equal(t, "/contacts/search?email=[email]", emailPath)
+	assert.Equal(t, "/contacts/search?phone=[phone]&name=Ana", phonePath)
+	assert.Equal(t, "/contacts/42?last_event_id=1050", idPath)
+}
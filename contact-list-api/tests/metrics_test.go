@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/metrics"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrapeMetrics devolve o texto de /metrics.
+func scrapeMetrics(t *testing.T) string {
+	router := gin.New()
+	router.GET("/metrics", metrics.Handler())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+// metricValue lê o valor de uma série, como `contacts_total{tenant="x"}`, no
+// texto de /metrics. Séries ausentes valem zero, como um contador novo.
+func metricValue(t *testing.T, body, series string) float64 {
+	for _, line := range strings.Split(body, "\n") {
+		if value, ok := strings.CutPrefix(line, series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			require.NoError(t, err)
+			return v
+		}
+	}
+	return 0
+}
+
+func TestMetricsRequests_RouteTemplate_ExpectedSeriesByRouteNotPath(t *testing.T) {
+	// Fixture
+	router := gin.New()
+	router.Use(metrics.Requests())
+	router.GET("/public/contacts/:token", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for _, path := range []string{"/public/contacts/token-um", "/public/contacts/token-dois", "/caminho/qualquer"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Exercise
+	body := scrapeMetrics(t)
+
+	// Assert
+	assert.Equal(t, 2.0, metricValue(t, body, `http_requests_total{method="GET",route="/public/contacts/:token",status="404"}`))
+	assert.Equal(t, 1.0, metricValue(t, body, `http_requests_total{method="GET",route="unmatched",status="404"}`))
+	assert.Equal(t, 2.0, metricValue(t, body, `http_request_duration_seconds_count{method="GET",route="/public/contacts/:token",status="404"}`))
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/public/contacts/:token",status="404",le="+Inf"} 2`)
+	assert.NotContains(t, body, "token-um")
+	assert.NotContains(t, body, "/caminho/qualquer")
+	assert.Contains(t, body, "# TYPE http_request_duration_seconds histogram")
+}
+
+func TestStorageMetrics_SaveAndLoad_ExpectedDurationsSizesAndTotals(t *testing.T) {
+	// Fixture
+	storage.ConfigureEncryption(testKeyring(t, testKey("2025", 1)))
+	defer storage.ConfigureEncryption(nil)
+	defer cleanupEncryptionTenant()
+
+	before := scrapeMetrics(t)
+	contacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "+55 11 98765-4321"},
+		{ID: 2, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com", Phone: "551199998877"},
+	}
+
+	// Exercise
+	require.NoError(t, storage.SaveContacts(context.Background(), encryptionTestTenant, contacts))
+	_, err := storage.LoadContacts(context.Background(), encryptionTestTenant)
+	after := scrapeMetrics(t)
+
+	// Assert
+	require.NoError(t, err)
+	// SaveContacts lê o arquivo no primeiro acesso ao tenant e acrescenta a
+	// alteração ao journal; depois disso, LoadContacts vem da memória.
+	for operation, count := range map[string]float64{"append": 1, "load": 1} {
+		duration := `contacts_storage_duration_seconds_count{operation="` + operation + `"}`
+		size := `contacts_storage_size_bytes_count{operation="` + operation + `"}`
+		assert.Equal(t, metricValue(t, before, duration)+count, metricValue(t, after, duration), operation)
+		assert.Equal(t, metricValue(t, before, size)+count, metricValue(t, after, size), operation)
+	}
+	assert.Greater(t, metricValue(t, after, `contacts_storage_size_bytes_sum{operation="append"}`), metricValue(t, before, `contacts_storage_size_bytes_sum{operation="append"}`))
+	assert.Equal(t, 2.0, metricValue(t, after, `contacts_total{tenant="teste-cifra"}`))
+	assert.Equal(t, 2.0, metricValue(t, after, `contacts_search_index_entries{index="email",tenant="teste-cifra"}`))
+	assert.Equal(t, 2.0, metricValue(t, after, `contacts_search_index_entries{index="phone",tenant="teste-cifra"}`))
+}
+
+func TestStorageMetrics_TenantDeleted_ExpectedTenantSeriesRemoved(t *testing.T) {
+	// Fixture
+	defer cleanupEncryptionTenant()
+	require.NoError(t, storage.SaveContacts(context.Background(), encryptionTestTenant, []models.Contact{{ID: 1, Name: "Fernanda Lima"}}))
+	require.Contains(t, scrapeMetrics(t), `contacts_total{tenant="teste-cifra"} 1`)
+
+	// Exercise
+	err := storage.DeleteTenantData(context.Background(), encryptionTestTenant)
+	body := scrapeMetrics(t)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotContains(t, body, `tenant="teste-cifra"`)
+}
+
+func TestSearchMetrics_SearchByName_ExpectedCountAndResultSize(t *testing.T) {
+	// Fixture
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return []models.Contact{
+			{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "551198765432"},
+			{ID: 2, Name: "Fernando Souza", Email: "fernando@gmail.com", Phone: "551199998877"},
+			{ID: 3, Name: "Carlos Eduardo", Email: "carlos@gmail.com", Phone: "551191234567"},
+		}, nil
+	})
+	defer patch.Unpatch()
+
+	router := newAuthRouter()
+	before := scrapeMetrics(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/contacts/search?name=fern", nil)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+	after := scrapeMetrics(t)
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metricValue(t, before, `contacts_searches_total{field="name"}`)+1, metricValue(t, after, `contacts_searches_total{field="name"}`))
+	assert.Equal(t, metricValue(t, before, `contacts_search_results_sum{field="name"}`)+2, metricValue(t, after, `contacts_search_results_sum{field="name"}`))
+	assert.Equal(t, metricValue(t, before, `contacts_search_results_bucket{field="name",le="1"}`), metricValue(t, after, `contacts_search_results_bucket{field="name",le="1"}`))
+	assert.Equal(t, metricValue(t, before, `contacts_search_results_bucket{field="name",le="2"}`)+1, metricValue(t, after, `contacts_search_results_bucket{field="name",le="2"}`))
+}
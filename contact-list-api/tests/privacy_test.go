@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchPrivacyStorage soma a patchShareLinkStorage os consentimentos, as
+// buscas por e-mail e telefone, o log de alterações e a auditoria, e devolve
+// a auditoria gravada.
+// Como o barramento de eventos é global, os testes de exportação usam IDs
+// que os demais testes não publicam.
+func patchPrivacyStorage(t *testing.T, contacts []models.Contact) (*[]models.PrivacyRequest, func()) {
+	unpatchLinks := patchShareLinkStorage(t, contacts)
+	_, unpatchConsents := patchConsentStorage()
+
+	findBy := func(match func(models.Contact) bool) ([]models.Contact, error) {
+		all, err := storage.LoadContacts(context.Background(), models.DefaultTenantID)
+		if err != nil {
+			return nil, err
+		}
+		var found []models.Contact
+		for _, contact := range all {
+			if match(contact) {
+				found = append(found, contact)
+			}
+		}
+		return found, nil
+	}
+
+	var requests []models.PrivacyRequest
+	patches := []*monkey.PatchGuard{
+		monkey.Patch(storage.FindContactsByEmail, func(ctx context.Context, tenantID, email string) ([]models.Contact, error) {
+			return findBy(func(c models.Contact) bool { return storage.NormalizeEmail(c.Email) == storage.NormalizeEmail(email) })
+		}),
+		monkey.Patch(storage.FindContactsByPhone, func(ctx context.Context, tenantID, phone string) ([]models.Contact, error) {
+			return findBy(func(c models.Contact) bool { return storage.NormalizePhone(c.Phone) == storage.NormalizePhone(phone) })
+		}),
+		monkey.Patch(storage.LoadChangeLog, func(ctx context.Context, tenantID string) (storage.ChangeLog, error) {
+			return storage.ChangeLog{Entries: []storage.ChangeEntry{{ContactID: 901, Seq: 1}, {ContactID: 903, Seq: 2}}}, nil
+		}),
+		monkey.Patch(storage.LoadPrivacyRequests, func(ctx context.Context, tenantID string) ([]models.PrivacyRequest, error) {
+			return append([]models.PrivacyRequest(nil), requests...), nil
+		}),
+		monkey.Patch(storage.SavePrivacyRequests, func(ctx context.Context, tenantID string, r []models.PrivacyRequest) error {
+			requests = r
+			return nil
+		}),
+	}
+	return &requests, func() {
+		for _, p := range patches {
+			p.Unpatch()
+		}
+		unpatchConsents()
+		unpatchLinks()
+	}
+}
+
+func TestExportSubjectData_EmailInTwoBooks_ExpectedAllRecordsAndAnonymousAudit(t *testing.T) {
+	// Fixture
+	requests, unpatch := patchPrivacyStorage(t, []models.Contact{
+		{ID: 901, OwnerID: 1, Name: "Fernanda Lima", Email: "titular.exportacao@yahoo.com"},
+		{ID: 902, OwnerID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+		{ID: 903, OwnerID: 0, Name: "Fernanda L.", Email: "Titular.Exportacao@YAHOO.com"},
+	})
+	defer unpatch()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 901, "maria", models.PermissionRead)
+	require.NoError(t, err)
+	_, _, err = services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 901, 0, 0)
+	require.NoError(t, err)
+	services.Events.Publish(models.DefaultTenantID, services.EventContactUpdated, models.Contact{ID: 901, OwnerID: 1, Name: "Fernanda Lima", Email: "titular.exportacao@yahoo.com"})
+
+	// Exercise
+	data, err := services.ExportSubjectData(context.Background(), models.DefaultTenantID, "api-key:1", " titular.exportacao@yahoo.com", "")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, data.Contacts, 2)
+	assert.Len(t, data.Shares, 1)
+	require.Len(t, data.ShareLinks, 1)
+	assert.Empty(t, data.ShareLinks[0].NonceHash)
+	assert.Len(t, data.History, 2)
+	assert.Len(t, data.Events, 1)
+
+	require.Len(t, *requests, 1)
+	audit, _ := json.Marshal((*requests)[0])
+	assert.NotContains(t, string(audit), "titular")
+	assert.Equal(t, models.PrivacyRequestExport, (*requests)[0].Type)
+	assert.Equal(t, 2, (*requests)[0].Records["contacts"])
+}
+
+func TestConfirmErasure_ValidConfirmation_ExpectedSubjectErasedAndVerified(t *testing.T) {
+	// Fixture
+	requests, unpatch := patchPrivacyStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "titular.exclusao@yahoo.com", Phone: "+55 11 91234-0000"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"},
+	})
+	defer unpatch()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 1, "maria", models.PermissionRead)
+	require.NoError(t, err)
+	services.Events.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "titular.exclusao@yahoo.com"})
+
+	erasure, err := services.RequestErasure(context.Background(), models.DefaultTenantID, "", "5511912340000")
+	require.NoError(t, err)
+	remainingAfterRequest, _ := services.GetAllContacts(context.Background(), models.DefaultTenantID, 1)
+
+	// Exercise
+	_, wrongSubjectErr := services.ConfirmErasure(context.Background(), models.DefaultTenantID, "bootstrap", erasure.ConfirmationToken, "", "5511900000000")
+	entry, err := services.ConfirmErasure(context.Background(), models.DefaultTenantID, "bootstrap", erasure.ConfirmationToken, "", "5511912340000")
+	_, reusedErr := services.ConfirmErasure(context.Background(), models.DefaultTenantID, "bootstrap", erasure.ConfirmationToken, "", "5511912340000")
+
+	// Assert
+	assert.Len(t, remainingAfterRequest, 2)
+	assert.ErrorIs(t, wrongSubjectErr, services.ErrInvalidConfirmation)
+	require.NoError(t, err)
+	assert.ErrorIs(t, reusedErr, services.ErrInvalidConfirmation)
+
+	contacts, _ := services.GetAllContacts(context.Background(), models.DefaultTenantID, 1)
+	assert.Equal(t, []models.Contact{{ID: 2, OwnerID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"}}, contacts)
+	shares, _ := services.GetSharesByOwner(context.Background(), models.DefaultTenantID, 1)
+	assert.Empty(t, shares)
+	assert.Empty(t, services.Events.Find(models.DefaultTenantID, func(c models.Contact) bool {
+		return c.Email == "titular.exclusao@yahoo.com"
+	}))
+
+	assert.True(t, entry.Verified)
+	assert.Equal(t, 1, entry.Records["contacts"])
+	assert.Equal(t, 1, entry.Records["shares"])
+	assert.Equal(t, []models.PrivacyRequest{entry}, *requests)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mathzpereira/c214-seminario/contact-list-api/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func newTestLimiter(config ratelimit.Config) (*ratelimit.Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 10, 19, 23, 59, 0, 0, time.UTC)}
+	return ratelimit.NewLimiter(config, clock.Now), clock
+}
+
+func TestLimiterAllow_BurstExhausted_ExpectedRefusalUntilRefill(t *testing.T) {
+	// Fixture
+	limiter, clock := newTestLimiter(ratelimit.Config{Default: ratelimit.Limit{Rate: 2, Burst: 3}})
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.Allow("ip:203.0.113.7", "GET /contacts/").Allowed)
+	}
+
+	// Exercise
+	refused := limiter.Allow("ip:203.0.113.7", "GET /contacts/")
+	otherClient := limiter.Allow("ip:198.51.100.2", "GET /contacts/")
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	refilled := limiter.Allow("ip:203.0.113.7", "GET /contacts/")
+
+	// Assert
+	assert.False(t, refused.Allowed)
+	assert.Equal(t, 500*time.Millisecond, refused.RetryAfter)
+	assert.Equal(t, 3, refused.Limit)
+	assert.True(t, otherClient.Allowed)
+	assert.True(t, refilled.Allowed)
+	assert.Equal(t, 0, refilled.Remaining)
+}
+
+func TestLimiterAllow_RouteLimit_ExpectedSeparateBucketFromDefault(t *testing.T) {
+	// Fixture
+	limiter, _ := newTestLimiter(ratelimit.Config{
+		Default: ratelimit.Limit{Rate: 10, Burst: 20},
+		Routes:  map[string]ratelimit.Limit{"GET /contacts/search": {Rate: 1, Burst: 1}},
+	})
+
+	// Exercise
+	first := limiter.Allow("principal:default/api-key:1", "GET /contacts/search")
+	second := limiter.Allow("principal:default/api-key:1", "GET /contacts/search")
+	list := limiter.Allow("principal:default/api-key:1", "GET /contacts/")
+
+	// Assert
+	assert.True(t, first.Allowed)
+	assert.False(t, second.Allowed)
+	assert.True(t, list.Allowed)
+	assert.Equal(t, 19, list.Remaining)
+}
+
+func TestLimiterAllow_DailyQuota_ExpectedRefusedUntilNextDay(t *testing.T) {
+	// Fixture
+	limiter, clock := newTestLimiter(ratelimit.Config{Default: ratelimit.Limit{Rate: 100, Burst: 100}, DailyQuota: 2})
+	limiter.Allow("principal:default/maria", "GET /contacts/")
+	limiter.Allow("principal:default/maria", "GET /contacts/")
+
+	// Exercise
+	exceeded := limiter.Allow("principal:default/maria", "GET /contacts/")
+	clock.now = clock.now.Add(time.Minute)
+	nextDay := limiter.Allow("principal:default/maria", "GET /contacts/")
+
+	// Assert
+	assert.False(t, exceeded.Allowed)
+	assert.True(t, exceeded.QuotaExceeded)
+	assert.Equal(t, time.Minute, exceeded.RetryAfter)
+	assert.True(t, nextDay.Allowed)
+	assert.Equal(t, 1, nextDay.QuotaRemaining)
+}
+
+func TestRateLimitMiddleware_LimitExceeded_ExpectedTooManyRequestsWithHeaders(t *testing.T) {
+	// Fixture
+	limiter, _ := newTestLimiter(ratelimit.Config{Default: ratelimit.Limit{Rate: 0.5, Burst: 1}})
+	ratelimit.ConfigureIP(limiter)
+	defer ratelimit.ConfigureIP(nil)
+
+	router := newAuthRouter()
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public/contacts/token-invalido", nil)
+		req.RemoteAddr = "203.0.113.7:51000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exercise
+	first := request()
+	second := request()
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, first.Code)
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", first.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "2", second.Header().Get("Retry-After"))
+}
+
+func TestRateLimitMiddleware_InvalidCredentials_ExpectedCountedByIP(t *testing.T) {
+	// Fixture
+	limiter, _ := newTestLimiter(ratelimit.Config{Default: ratelimit.Limit{Rate: 0.5, Burst: 1}})
+	ratelimit.ConfigureIP(limiter)
+	defer ratelimit.ConfigureIP(nil)
+
+	router := newAuthRouter()
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/contacts/", nil)
+		req.RemoteAddr = "203.0.113.7:51000"
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exercise
+	first := request("cls_invalida_1")
+	second := request("cls_invalida_2")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, first.Code)
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+}
+
+func TestRateLimitMiddleware_SpoofedForwardedFor_ExpectedSameBucket(t *testing.T) {
+	// Fixture
+	limiter, _ := newTestLimiter(ratelimit.Config{Default: ratelimit.Limit{Rate: 0.5, Burst: 1}})
+	ratelimit.ConfigureIP(limiter)
+	defer ratelimit.ConfigureIP(nil)
+
+	router := newAuthRouter()
+	request := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"maria","password":"errada"}`))
+		req.RemoteAddr = "203.0.113.7:51000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exercise
+	first := request("198.51.100.1")
+	second := request("198.51.100.2")
+
+	// Assert
+	assert.NotEqual(t, http.StatusTooManyRequests, first.Code)
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+}
+
+func TestRateLimitMiddleware_ClientDailyQuota_ExpectedNotAppliedBeforeAuthentication(t *testing.T) {
+	// Fixture
+	limiter, _ := newTestLimiter(ratelimit.Config{Default: ratelimit.Limit{Rate: 100, Burst: 100}, DailyQuota: 1})
+	ratelimit.Configure(limiter)
+	defer ratelimit.Configure(nil)
+	ipLimiter, _ := newTestLimiter(ratelimit.DefaultIPConfig())
+	ratelimit.ConfigureIP(ipLimiter)
+	defer ratelimit.ConfigureIP(nil)
+
+	router := newAuthRouter()
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public/contacts/token-invalido", nil)
+		req.RemoteAddr = "203.0.113.7:51000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exercise
+	first := request()
+	second := request()
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, first.Code)
+	assert.Equal(t, http.StatusNotFound, second.Code)
+	assert.Empty(t, second.Header().Get("X-RateLimit-Daily-Limit"))
+}
+
+func TestIPConfigFromEnv_DailyQuotaSet_ExpectedOwnLimitsWithoutQuota(t *testing.T) {
+	// Fixture
+	t.Setenv("RATE_LIMIT_DEFAULT", "1,1")
+	t.Setenv("RATE_LIMIT_DAILY_QUOTA", "100")
+	t.Setenv("RATE_LIMIT_IP_ROUTES", "POST /auth/login=1,3")
+
+	// Exercise
+	config, err := ratelimit.IPConfigFromEnv()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, ratelimit.DefaultIPConfig().Default, config.Default)
+	assert.Equal(t, ratelimit.Limit{Rate: 1, Burst: 3}, config.Routes["POST /auth/login"])
+	assert.Zero(t, config.DailyQuota)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/handlers"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchShareLinkStorage guarda os links em memória, além do que
+// patchShareStorage já mantém.
+func patchShareLinkStorage(t *testing.T, contacts []models.Contact) func() {
+	unpatchShares := patchShareStorage(t, contacts)
+	services.ConfigureShareLinks([]byte("segredo-de-teste"))
+
+	var links []models.ShareLink
+	lastID := 0
+	patchLoad := monkey.Patch(storage.LoadShareLinks, func(ctx context.Context, tenantID string) ([]models.ShareLink, error) {
+		return append([]models.ShareLink(nil), links...), nil
+	})
+	patchSave := monkey.Patch(storage.SaveShareLinks, func(ctx context.Context, tenantID string, l []models.ShareLink) error {
+		links = l
+		return nil
+	})
+	patchLoadCounter := monkey.Patch(storage.LoadShareLinkCounter, func(ctx context.Context, tenantID string) (int, error) {
+		return lastID, nil
+	})
+	patchSaveCounter := monkey.Patch(storage.SaveShareLinkCounter, func(ctx context.Context, tenantID string, id int) error {
+		lastID = id
+		return nil
+	})
+	return func() {
+		patchLoad.Unpatch()
+		patchSave.Unpatch()
+		patchLoadCounter.Unpatch()
+		patchSaveCounter.Unpatch()
+		unpatchShares()
+	}
+}
+
+func getPublicContact(token, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/public/contacts/"+token, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	newAuthRouter().ServeHTTP(w, req)
+	return w
+}
+
+func TestResolveShareLink_MaxUsesReached_ExpectedGone(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "551198765432"},
+	})()
+
+	token, link, err := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 1, time.Hour, 2)
+	assert.NoError(t, err)
+
+	// Exercise
+	asJSON := getPublicContact(token, "")
+	asVCard := getPublicContact(token, "text/vcard")
+	exhausted := getPublicContact(token, "")
+
+	// Assert
+	assert.Equal(t, 1, link.ID)
+	assert.Equal(t, http.StatusOK, asJSON.Code)
+	var contact models.PublicContact
+	assert.NoError(t, json.Unmarshal(asJSON.Body.Bytes(), &contact))
+	assert.Equal(t, models.PublicContact{Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com", Phone: "551198765432"}, contact)
+	assert.NotContains(t, asJSON.Body.String(), "owner_id")
+
+	assert.Equal(t, http.StatusOK, asVCard.Code)
+	assert.Equal(t, "text/vcard; charset=utf-8", asVCard.Header().Get("Content-Type"))
+	assert.Contains(t, asVCard.Body.String(), "FN:Fernanda Lima\r\n")
+
+	assert.Equal(t, http.StatusGone, exhausted.Code)
+}
+
+func TestResolveShareLink_TamperedOrExpired_ExpectedRejected(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+		{ID: 2, OwnerID: 2, Name: "Juliana Souza"},
+	})()
+
+	token, _, err := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 1, time.Hour, 0)
+	assert.NoError(t, err)
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	forged := strings.Replace(encoded, encoded[len(encoded)-2:], "xx", 1) + "." + signature
+
+	expiredToken, err := services.ShareLinkToken(models.DefaultTenantID, models.ShareLink{ID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, "nonce")
+	assert.NoError(t, err)
+
+	// Exercise
+	_, forgedErr := services.ResolveShareLink(context.Background(), forged)
+	_, expiredErr := services.ResolveShareLink(context.Background(), expiredToken)
+	_, garbageErr := services.ResolveShareLink(context.Background(), "nao-e-um-token")
+
+	// Assert
+	assert.ErrorIs(t, forgedErr, services.ErrInvalidShareLink)
+	assert.ErrorIs(t, expiredErr, services.ErrShareLinkExpired)
+	assert.ErrorIs(t, garbageErr, services.ErrInvalidShareLink)
+}
+
+func TestCreateShareLink_SharedContact_ExpectedPermissionDenied(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+	})()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 1, "maria", models.PermissionEdit)
+	assert.NoError(t, err)
+
+	// Exercise
+	_, _, sharedErr := services.CreateShareLink(context.Background(), models.DefaultTenantID, 2, 1, 0, 0)
+	_, _, ttlErr := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 1, services.MaxShareLinkTTL+time.Hour, 0)
+
+	// Assert
+	assert.ErrorIs(t, sharedErr, services.ErrPermissionDenied)
+	assert.ErrorIs(t, ttlErr, services.ErrInvalidShareLinkTTL)
+}
+
+func TestRevokeShareLink_ExistingLink_ExpectedLinkInvalid(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+	})()
+
+	token, link, err := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 1, 0, 0)
+	assert.NoError(t, err)
+
+	// Exercise
+	err = services.RevokeShareLink(context.Background(), models.DefaultTenantID, 1, 1, link.ID)
+	links, listErr := services.GetShareLinks(context.Background(), models.DefaultTenantID, 1, 1)
+	w := getPublicContact(token, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, listErr)
+	assert.Empty(t, links)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRevokeShareLink_NewLinkCreated_ExpectedRevokedTokenStillInvalid(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+		{ID: 2, OwnerID: 1, Name: "Juliana Souza"},
+	})()
+
+	revokedToken, revoked, err := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 1, time.Hour, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, services.RevokeShareLink(context.Background(), models.DefaultTenantID, 1, 1, revoked.ID))
+
+	// Exercise
+	_, next, createErr := services.CreateShareLink(context.Background(), models.DefaultTenantID, 1, 2, time.Hour, 0)
+	forgedToken, err := services.ShareLinkToken(models.DefaultTenantID, models.ShareLink{ID: next.ID, ExpiresAt: next.ExpiresAt}, "outro-nonce")
+	assert.NoError(t, err)
+	revokedResponse := getPublicContact(revokedToken, "")
+	forgedResponse := getPublicContact(forgedToken, "")
+
+	// Assert
+	assert.NoError(t, createErr)
+	assert.Equal(t, revoked.ID+1, next.ID)
+	assert.Empty(t, next.NonceHash)
+	assert.Equal(t, http.StatusNotFound, revokedResponse.Code)
+	assert.Equal(t, http.StatusNotFound, forgedResponse.Code)
+}
+
+func TestCreateShareLink_ForwardedProto_ExpectedHonoredOnlyFromTrustedProxy(t *testing.T) {
+	// Fixture
+	defer patchShareLinkStorage(t, []models.Contact{
+		{ID: 1, OwnerID: models.SharedOwnerID, Name: "Fernanda Lima"},
+	})()
+	require.NoError(t, handlers.ConfigureTrustedProxies([]string{"10.0.0.0/8"}))
+	defer handlers.ConfigureTrustedProxies(nil)
+
+	createLink := func(remoteAddr string) string {
+		req := httptest.NewRequest(http.MethodPost, "/contacts/1/share-links", nil)
+		req.Header.Set("X-API-Key", testBootstrapKey)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		newAuthRouter().ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var response handlers.ShareLinkResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.URL
+	}
+
+	// Exercise
+	fromClient := createLink("203.0.113.7:51000")
+	fromProxy := createLink("10.1.2.3:51000")
+
+	// Assert
+	assert.True(t, strings.HasPrefix(fromClient, "http://example.com/public/contacts/"), fromClient)
+	assert.True(t, strings.HasPrefix(fromProxy, "https://example.com/public/contacts/"), fromProxy)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// patchShareStorage grava contacts num tenant temporário e guarda os
+// compartilhamentos em memória, com os usuários joao (1) e maria (2).
+func patchShareStorage(t *testing.T, contacts []models.Contact) func() {
+	seedContacts(t, models.DefaultTenantID, contacts)
+	users := []models.User{{ID: 1, Username: "joao"}, {ID: 2, Username: "maria"}}
+	var shares []models.Share
+
+	patches := []*monkey.PatchGuard{
+		monkey.Patch(storage.LoadUsers, func(ctx context.Context, tenantID string) ([]models.User, error) {
+			return users, nil
+		}),
+		monkey.Patch(storage.LoadShares, func(ctx context.Context, tenantID string) ([]models.Share, error) {
+			return append([]models.Share(nil), shares...), nil
+		}),
+		monkey.Patch(storage.SaveShares, func(ctx context.Context, tenantID string, s []models.Share) error {
+			shares = s
+			return nil
+		}),
+	}
+	return func() {
+		for _, p := range patches {
+			p.Unpatch()
+		}
+	}
+}
+
+func TestShareContact_ReadPermission_ExpectedReadOnlyAccess(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo"},
+	})()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 1, "maria", models.PermissionRead)
+	assert.NoError(t, err)
+
+	// Exercise
+	shared, getErr := services.GetContactByID(context.Background(), models.DefaultTenantID, 2, 1)
+	notShared, notSharedErr := services.GetContactByID(context.Background(), models.DefaultTenantID, 2, 2)
+	_, notSharedUpdateErr := services.UpdateContactById(context.Background(), models.DefaultTenantID, 2, 2, models.Contact{Name: "Carlos"})
+	_, updateErr := services.UpdateContactById(context.Background(), models.DefaultTenantID, 2, 1, models.Contact{Name: "Fernanda"})
+	deleteErr := services.DeleteContactById(context.Background(), models.DefaultTenantID, 2, 1)
+
+	// Assert
+	assert.NoError(t, getErr)
+	assert.Equal(t, "Fernanda Lima", shared.Name)
+	assert.Equal(t, models.Contact{}, notShared)
+	assert.ErrorIs(t, notSharedErr, services.ErrContactNotFound)
+	assert.ErrorIs(t, notSharedUpdateErr, services.ErrContactNotFound)
+	assert.ErrorIs(t, updateErr, services.ErrPermissionDenied)
+	assert.ErrorIs(t, deleteErr, services.ErrPermissionDenied)
+}
+
+func TestShareContact_EditWholeBook_ExpectedUpdateKeepsOwner(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+	})()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 0, "maria", models.PermissionEdit)
+	assert.NoError(t, err)
+
+	// Exercise
+	result, err := services.UpdateContactById(context.Background(), models.DefaultTenantID, 2, 1, models.Contact{Name: "Fernanda Lima Souza", OwnerID: 2})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, models.Contact{ID: 1, OwnerID: 1, Name: "Fernanda Lima Souza"}, result)
+}
+
+func TestRevokeShare_ExistingShare_ExpectedAccessRemoved(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+	})()
+
+	share, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 1, "maria", models.PermissionRead)
+	assert.NoError(t, err)
+
+	// Exercise
+	otherOwnerErr := services.RevokeShare(context.Background(), models.DefaultTenantID, 2, share.ID)
+	err = services.RevokeShare(context.Background(), models.DefaultTenantID, 1, share.ID)
+	contact, getErr := services.GetContactByID(context.Background(), models.DefaultTenantID, 2, 1)
+
+	// Assert
+	assert.ErrorIs(t, otherOwnerErr, services.ErrShareNotFound)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, getErr, services.ErrContactNotFound)
+	assert.Equal(t, models.Contact{}, contact)
+}
+
+func TestGetSharedWithMe_ContactAndBookShares_ExpectedHighestPermission(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Fernanda Lima"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo"},
+		{ID: 3, OwnerID: 2, Name: "Juliana Souza"},
+	})()
+
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 0, "maria", models.PermissionRead)
+	assert.NoError(t, err)
+	_, err = services.ShareContact(context.Background(), models.DefaultTenantID, 1, 2, "maria", models.PermissionEdit)
+	assert.NoError(t, err)
+
+	// Exercise
+	result, err := services.GetSharedWithMe(context.Background(), models.DefaultTenantID, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []services.SharedContact{
+		{Contact: models.Contact{ID: 1, OwnerID: 1, Name: "Fernanda Lima"}, Owner: "joao", Permission: models.PermissionRead},
+		{Contact: models.Contact{ID: 2, OwnerID: 1, Name: "Carlos Eduardo"}, Owner: "joao", Permission: models.PermissionEdit},
+	}, result)
+}
+
+func TestShareContact_ContactFromOtherBook_ExpectedContactNotFound(t *testing.T) {
+	// Fixture
+	defer patchShareStorage(t, []models.Contact{
+		{ID: 3, OwnerID: 2, Name: "Juliana Souza"},
+	})()
+
+	// Exercise
+	_, err := services.ShareContact(context.Background(), models.DefaultTenantID, 1, 3, "maria", models.PermissionRead)
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrContactNotFound)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func patchSyncStorage(contacts []models.Contact, changeLog storage.ChangeLog) func() {
+	patchLoad := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return contacts, nil
+	})
+	patchChanges := monkey.Patch(storage.LoadChangeLog, func(ctx context.Context, tenantID string) (storage.ChangeLog, error) {
+		return changeLog, nil
+	})
+	return func() {
+		patchLoad.Unpatch()
+		patchChanges.Unpatch()
+	}
+}
+
+func TestSyncContacts_WithoutToken_ExpectedFullList(t *testing.T) {
+	// Fixture
+	mockContacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima"},
+		{ID: 3, Name: "Carlos Eduardo"},
+	}
+	defer patchSyncStorage(mockContacts, storage.ChangeLog{LastSeq: 5})()
+
+	// Exercise
+	result, err := services.SyncContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, result.Full)
+	assert.Equal(t, mockContacts, result.Changed)
+	assert.NotEmpty(t, result.NextToken)
+}
+
+func TestSyncContacts_WithToken_ExpectedOnlyChangesAndTombstones(t *testing.T) {
+	// Fixture
+	deletedAt := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	mockContacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima"},
+		{ID: 3, Name: "Carlos Eduardo"},
+		{ID: 4, Name: "Marcos Vinícius"},
+	}
+
+	unpatch := patchSyncStorage(mockContacts, storage.ChangeLog{LastSeq: 3})
+	previousSync, err := services.SyncContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "")
+	unpatch()
+	assert.NoError(t, err)
+
+	changeLog := storage.ChangeLog{
+		LastSeq: 6,
+		Entries: []storage.ChangeEntry{
+			{ContactID: 1, Seq: 2},
+			{ContactID: 3, Seq: 4},
+			{ContactID: 2, Seq: 5, Deleted: true, ChangedAt: deletedAt},
+			{ContactID: 4, Seq: 6},
+		},
+	}
+	defer patchSyncStorage(mockContacts, changeLog)()
+
+	// Exercise
+	result, err := services.SyncContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID, previousSync.NextToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, result.Full)
+	assert.Equal(t, []models.Contact{{ID: 3, Name: "Carlos Eduardo"}, {ID: 4, Name: "Marcos Vinícius"}}, result.Changed)
+	assert.Equal(t, []services.ContactTombstone{{ID: 2, DeletedAt: deletedAt}}, result.Deleted)
+	assert.NotEqual(t, previousSync.NextToken, result.NextToken)
+}
+
+func TestSyncContacts_InvalidToken_ExpectedError(t *testing.T) {
+	// Fixture
+	defer patchSyncStorage(nil, storage.ChangeLog{})()
+
+	// Exercise
+	_, err := services.SyncContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "token-invalido")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidSyncToken)
+}
+
+func TestSyncContacts_TokenOlderThanPrunedTombstones_ExpectedExpired(t *testing.T) {
+	// Fixture
+	unpatch := patchSyncStorage(nil, storage.ChangeLog{LastSeq: 2})
+	oldSync, err := services.SyncContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID, "")
+	unpatch()
+	assert.NoError(t, err)
+
+	defer patchSyncStorage(nil, storage.ChangeLog{LastSeq: 50, PrunedSeq: 10})()
+
+	// Exercise
+	_, err = services.SyncContacts(context.Background(), models.DefaultTenantID, models.SharedOwnerID, oldSync.NextToken)
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrSyncTokenExpired)
+}
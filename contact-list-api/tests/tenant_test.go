@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tenancy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patchTenantRegistry(tenants []models.Tenant) func() {
+	patch := monkey.Patch(storage.LoadTenants, func(ctx context.Context) ([]models.Tenant, error) {
+		return tenants, nil
+	})
+	return patch.Unpatch
+}
+
+func TestAddContact_TenantQuotaReached_ExpectedQuotaExceeded(t *testing.T) {
+	// Fixture
+	defer patchTenantRegistry([]models.Tenant{{ID: "acme", MaxContacts: 2}})()
+
+	seedContacts(t, "acme", []models.Contact{{ID: 1, Name: "Fernanda Lima"}, {ID: 2, OwnerID: 1, Name: "Carlos Eduardo"}})
+
+	// Exercise
+	_, err := services.AddContact(context.Background(), "acme", models.SharedOwnerID, models.Contact{Name: "Juliana Souza"})
+	saved, loadErr := storage.LoadContacts(context.Background(), "acme")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrContactQuotaExceeded)
+	require.NoError(t, loadErr)
+	assert.Len(t, saved, 2)
+}
+
+func TestGetContactsSummary_TwoTenants_ExpectedOnlyTenantContacts(t *testing.T) {
+	// Fixture
+	byTenant := map[string][]models.Contact{
+		"acme":   {{ID: 1, Name: "Fernanda Lima", Email: "fernanda.lima@yahoo.com"}},
+		"globex": {{ID: 1, Name: "Carlos Eduardo", Email: "carlos.eduardo@gmail.com"}, {ID: 2, Name: "Juliana Souza", Email: "juliana@gmail.com"}},
+	}
+
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return byTenant[tenantID], nil
+	})
+	defer patch.Unpatch()
+
+	// Exercise
+	summary, err := services.GetContactsSummary(context.Background(), "acme", models.SharedOwnerID)
+	providers, providersErr := services.GetEmailProviders(context.Background(), "acme", models.SharedOwnerID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, providersErr)
+	assert.Equal(t, 1, summary.Total)
+	assert.Equal(t, map[string]int{"yahoo.com": 1}, providers)
+}
+
+func TestCreateTenant_InvalidID_ExpectedInvalidTenantID(t *testing.T) {
+	// Fixture
+	defer patchTenantRegistry(nil)()
+
+	// Exercise
+	_, err := services.CreateTenant(context.Background(), models.Tenant{ID: "../acme"})
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidTenantID)
+}
+
+func TestResolve_SubdomainAndHeader_ExpectedTenant(t *testing.T) {
+	// Fixture
+	defer patchTenantRegistry([]models.Tenant{{ID: "acme"}, {ID: "globex"}})()
+	tenancy.Configure("api.example.com")
+	defer tenancy.Configure("")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/tenant", tenancy.Resolve(), func(c *gin.Context) {
+		c.String(http.StatusOK, tenancy.TenantID(c))
+	})
+
+	fromHost := httptest.NewRequest(http.MethodGet, "http://acme.api.example.com:8080/tenant", nil)
+	fromHeader := httptest.NewRequest(http.MethodGet, "http://acme.api.example.com/tenant", nil)
+	fromHeader.Header.Set(tenancy.HeaderName, "globex")
+	unknown := httptest.NewRequest(http.MethodGet, "http://initech.api.example.com/tenant", nil)
+
+	hostRecorder, headerRecorder, unknownRecorder := httptest.NewRecorder(), httptest.NewRecorder(), httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(hostRecorder, fromHost)
+	router.ServeHTTP(headerRecorder, fromHeader)
+	router.ServeHTTP(unknownRecorder, unknown)
+
+	// Assert
+	assert.Equal(t, "acme", hostRecorder.Body.String())
+	assert.Equal(t, "globex", headerRecorder.Body.String())
+	assert.Equal(t, http.StatusNotFound, unknownRecorder.Code)
+}
+
+func TestAuthenticate_APIKeyFromOtherTenant_ExpectedUnauthorized(t *testing.T) {
+	// Fixture
+	defer patchTenantRegistry([]models.Tenant{{ID: "acme"}})()
+
+	plaintext := "clk_0a1b2c3d_" + strings.Repeat("ab", 32)
+	patch := monkey.Patch(storage.LoadAPIKeys, func(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+		if tenantID != models.DefaultTenantID {
+			return nil, nil
+		}
+		return []models.APIKey{
+			{ID: 1, Prefix: "clk_0a1b2c3d", Hash: services.HashAPIKey(plaintext), Role: models.RoleAdmin},
+		}, nil
+	})
+	defer patch.Unpatch()
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("X-API-Key", plaintext)
+	req.Header.Set(tenancy.HeaderName, "acme")
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
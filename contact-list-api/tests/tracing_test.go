@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/gin-gonic/gin"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/logging"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/routes"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStub faz o papel de um coletor OTLP/HTTP e guarda os spans
+// recebidos em /v1/traces.
+type collectorStub struct {
+	mu    sync.Mutex
+	spans []*tracev1.Span
+}
+
+func (s *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request collectortrace.ExportTraceServiceRequest
+	if r.URL.Path != "/v1/traces" || proto.Unmarshal(body, &request) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, resource := range request.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			s.spans = append(s.spans, scope.Spans...)
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+func (s *collectorStub) byName() map[string]*tracev1.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	spans := map[string]*tracev1.Span{}
+	for _, span := range s.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func resetTracing() {
+	tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+}
+
+func TestTracing_OTLPCollectorAndTraceparent_ExpectedRequestServiceAndStorageSpansInOneTrace(t *testing.T) {
+	// Fixture
+	collector := &collectorStub{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	defer resetTracing()
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterOTLP, Endpoint: server.URL})
+	require.NoError(t, err)
+
+	newAuthRouter()
+	router := gin.New()
+	router.Use(logging.RequestID(), tracing.Requests())
+	routes.SetupRoutes(router)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/contacts/", nil)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	w := httptest.NewRecorder()
+
+	// Exercise
+	router.ServeHTTP(w, req)
+	require.NoError(t, shutdown(context.Background()))
+	spans := collector.byName()
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	request, service, load := spans["GET /contacts/"], spans["services.GetAllContacts"], spans["storage.LoadContacts"]
+	require.NotNil(t, request)
+	require.NotNil(t, service)
+	require.NotNil(t, load)
+	require.NotNil(t, spans["storage.readContactsFile"])
+	require.NotNil(t, spans["storage.decodeContacts"])
+
+	for _, span := range spans {
+		assert.Equal(t, traceID, hex.EncodeToString(span.TraceId), span.Name)
+	}
+	assert.Equal(t, parentID, hex.EncodeToString(request.ParentSpanId))
+	assert.Equal(t, tracev1.Span_SPAN_KIND_SERVER, request.Kind)
+	assert.Equal(t, request.SpanId, service.ParentSpanId)
+	assert.Equal(t, service.SpanId, load.ParentSpanId)
+	assert.Equal(t, load.SpanId, spans["storage.readContactsFile"].ParentSpanId)
+}
+
+func TestTracing_StdoutExporter_ExpectedServiceSpanWithStorageError(t *testing.T) {
+	// Fixture
+	defer resetTracing()
+	var out bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterStdout, Writer: &out})
+	require.NoError(t, err)
+
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		_, span := tracing.Start(ctx, "storage.LoadContacts")
+		defer span.End()
+		return nil, tracing.Fail(span, storage.ErrFileNotFound)
+	})
+	defer patch.Unpatch()
+
+	// Exercise
+	_, serviceErr := services.GetContactsSummary(context.Background(), models.DefaultTenantID, models.SharedOwnerID)
+	require.NoError(t, shutdown(context.Background()))
+
+	// Assert
+	assert.ErrorIs(t, serviceErr, storage.ErrFileNotFound)
+	spans := map[string]map[string]any{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var span map[string]any
+		require.NoError(t, decoder.Decode(&span))
+		spans[span["Name"].(string)] = span
+	}
+	require.Contains(t, spans, "services.GetContactsSummary")
+	require.Contains(t, spans, "storage.LoadContacts")
+	assert.Equal(t, "Error", spans["storage.LoadContacts"]["Status"].(map[string]any)["Code"])
+}
+
+func TestLogging_ContextWithSpan_ExpectedTraceAndSpanIDs(t *testing.T) {
+	// Fixture
+	defer resetTracing()
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterStdout, Writer: io.Discard})
+	require.NoError(t, err)
+	out, restore := captureLogs(t, "info")
+	defer restore()
+
+	ctx, span := tracing.Start(context.Background(), "services.Teste")
+	defer span.End()
+
+	// Exercise
+	slog.InfoContext(ctx, "dentro do span")
+	slog.Info("fora do span")
+
+	// Assert
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var inside, outside map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &inside))
+	require.NoError(t, json.Unmarshal(lines[1], &outside))
+	assert.Equal(t, span.SpanContext().TraceID().String(), inside["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), inside["span_id"])
+	assert.NotContains(t, outside, "trace_id")
+}
+
+func TestTracingConfigFromEnv_UnknownExporter_ExpectedError(t *testing.T) {
+	// Fixture
+	t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+
+	// Exercise
+	_, err := tracing.ConfigFromEnv()
+
+	// Assert
+	assert.ErrorIs(t, err, tracing.ErrInvalidExporter)
+}
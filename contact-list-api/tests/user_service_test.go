@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchAccountStorage guarda usuários e sessões em memória.
+func patchAccountStorage() func() {
+	var users []models.User
+	var sessions []models.Session
+
+	patches := []*monkey.PatchGuard{
+		monkey.Patch(storage.LoadUsers, func(ctx context.Context, tenantID string) ([]models.User, error) {
+			return append([]models.User(nil), users...), nil
+		}),
+		monkey.Patch(storage.SaveUsers, func(ctx context.Context, tenantID string, u []models.User) error {
+			users = u
+			return nil
+		}),
+		monkey.Patch(storage.LoadSessions, func(ctx context.Context, tenantID string) ([]models.Session, error) {
+			return append([]models.Session(nil), sessions...), nil
+		}),
+		monkey.Patch(storage.SaveSessions, func(ctx context.Context, tenantID string, s []models.Session) error {
+			sessions = s
+			return nil
+		}),
+	}
+	return func() {
+		for _, p := range patches {
+			p.Unpatch()
+		}
+	}
+}
+
+func TestRegisterUser_DuplicateUsername_ExpectedUsernameTaken(t *testing.T) {
+	// Fixture
+	defer patchAccountStorage()()
+
+	_, err := services.RegisterUser(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+
+	// Exercise
+	_, err = services.RegisterUser(context.Background(), models.DefaultTenantID, " Fernanda ", "outra-senha-segura")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrUsernameTaken)
+}
+
+func TestRegisterUser_ShortPassword_ExpectedWeakPassword(t *testing.T) {
+	// Fixture
+	defer patchAccountStorage()()
+
+	// Exercise
+	_, err := services.RegisterUser(context.Background(), models.DefaultTenantID, "fernanda", "curta")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrWeakPassword)
+}
+
+func TestLogin_ValidCredentials_ExpectedSessionForUser(t *testing.T) {
+	// Fixture
+	defer patchAccountStorage()()
+
+	user, err := services.RegisterUser(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+
+	// Exercise
+	token, _, err := services.Login(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+	sessionUser, sessionErr := services.VerifySession(context.Background(), models.DefaultTenantID, token)
+	_, _, wrongErr := services.Login(context.Background(), models.DefaultTenantID, "fernanda", "senha-errada")
+
+	// Assert
+	assert.NoError(t, sessionErr)
+	assert.Equal(t, user.ID, sessionUser.ID)
+	assert.Empty(t, sessionUser.PasswordHash)
+	assert.ErrorIs(t, wrongErr, services.ErrInvalidCredentials)
+}
+
+func TestLogout_ValidSession_ExpectedSessionRevoked(t *testing.T) {
+	// Fixture
+	defer patchAccountStorage()()
+
+	_, err := services.RegisterUser(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+	token, _, err := services.Login(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+
+	// Exercise
+	err = services.Logout(context.Background(), models.DefaultTenantID, token)
+	_, sessionErr := services.VerifySession(context.Background(), models.DefaultTenantID, token)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sessionErr, services.ErrInvalidSession)
+}
+
+func TestGetAllContacts_TwoOwners_ExpectedOnlyOwnContacts(t *testing.T) {
+	// Fixture
+	mockContacts := []models.Contact{
+		{ID: 1, Name: "Fernanda Lima"},
+		{ID: 2, OwnerID: 1, Name: "Carlos Eduardo"},
+		{ID: 3, OwnerID: 2, Name: "Juliana Souza"},
+	}
+
+	patch := monkey.Patch(storage.LoadContacts, func(ctx context.Context, tenantID string) ([]models.Contact, error) {
+		return mockContacts, nil
+	})
+	defer patch.Unpatch()
+
+	// Exercise
+	result, err := services.GetAllContacts(context.Background(), models.DefaultTenantID, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Contact{{ID: 2, OwnerID: 1, Name: "Carlos Eduardo"}}, result)
+}
+
+func TestDeleteContactById_OtherOwner_ExpectedNotFoundAndNothingSaved(t *testing.T) {
+	// Fixture
+	mockContacts := []models.Contact{
+		{ID: 1, OwnerID: 1, Name: "Carlos Eduardo"},
+		{ID: 2, OwnerID: 2, Name: "Juliana Souza"},
+	}
+
+	seedContacts(t, models.DefaultTenantID, mockContacts)
+
+	patchShares := monkey.Patch(storage.LoadShares, func(ctx context.Context, tenantID string) ([]models.Share, error) {
+		return nil, nil
+	})
+	defer patchShares.Unpatch()
+
+	// Exercise
+	err := services.DeleteContactById(context.Background(), models.DefaultTenantID, 1, 2)
+	contact, getErr := services.GetContactByID(context.Background(), models.DefaultTenantID, 1, 2)
+	saved, loadErr := storage.LoadContacts(context.Background(), models.DefaultTenantID)
+
+	// Assert
+	assert.Error(t, err)
+	require.NoError(t, loadErr)
+	assert.Equal(t, mockContacts, saved)
+	assert.ErrorIs(t, getErr, services.ErrContactNotFound)
+	assert.Equal(t, models.Contact{}, contact)
+}
+
+func TestAddContact_UserOwner_ExpectedOwnerAndGlobalID(t *testing.T) {
+	// Fixture
+	seedContacts(t, models.DefaultTenantID, []models.Contact{{ID: 4, OwnerID: 2, Name: "Juliana Souza"}})
+
+	// Exercise
+	_, err := services.AddContact(context.Background(), models.DefaultTenantID, 1, models.Contact{Name: "Carlos Eduardo", OwnerID: 2})
+	saved, loadErr := storage.LoadContacts(context.Background(), models.DefaultTenantID)
+
+	// Assert
+	assert.NoError(t, err)
+	require.NoError(t, loadErr)
+	assert.Equal(t, models.Contact{ID: 5, OwnerID: 1, Name: "Carlos Eduardo"}, saved[1])
+}
+
+func TestAuthenticate_SessionToken_ExpectedUserPrincipal(t *testing.T) {
+	// Fixture
+	defer patchAccountStorage()()
+
+	_, err := services.RegisterUser(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+	token, _, err := services.Login(context.Background(), models.DefaultTenantID, "fernanda", "senha-segura")
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	// Exercise
+	newAuthRouter().ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"subject":"fernanda","role":"editor","method":"session","user_id":1}`, w.Body.String())
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bou.ke/monkey"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/models"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/services"
+	"github.com/mathzpereira/c214-seminario/contact-list-api/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useWebhookConfig troca a configuração das entregas durante o teste. Ao
+// final, espera as entregas em andamento antes de voltar ao padrão, para
+// que nenhuma delas continue enquanto o teste seguinte roda.
+func useWebhookConfig(t *testing.T, c services.WebhookConfig) {
+	services.ConfigureWebhooks(c)
+	t.Cleanup(func() {
+		services.WaitWebhookDeliveries()
+		services.ConfigureWebhooks(services.DefaultWebhookConfig())
+	})
+}
+
+func TestDispatchEvent_Success_DeliversSignedPayload(t *testing.T) {
+	// Fixture
+	received := make(chan bool, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		expected := services.SignWebhookPayload("segredo", timestamp, body)
+		received <- r.Header.Get("X-Webhook-Signature") == expected
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+	useWebhookConfig(t, services.WebhookConfig{AllowPrivateTargets: true})
+
+	webhooks := []models.Webhook{{ID: 1, URL: receiver.URL, Secret: "segredo"}}
+	patch := monkey.Patch(storage.LoadWebhooks, func(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+		return webhooks, nil
+	})
+	defer patch.Unpatch()
+
+	event := services.ContactEvent{ID: 1, Type: services.EventContactCreated, Contact: models.Contact{ID: 1}}
+
+	// Exercise
+	err := services.DispatchEvent(context.Background(), event)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case validSignature := <-received:
+		assert.True(t, validSignature)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestDispatchEvent_ReceiverFails_ExpectedDeadLetter(t *testing.T) {
+	// Fixture
+	var attempts int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	useWebhookConfig(t, services.WebhookConfig{AllowPrivateTargets: true, MaxAttempts: 3, RetryBaseDelay: time.Millisecond})
+
+	webhooks := []models.Webhook{{ID: 42, URL: receiver.URL, Secret: "segredo"}}
+	patch := monkey.Patch(storage.LoadWebhooks, func(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+		return webhooks, nil
+	})
+	defer patch.Unpatch()
+
+	event := services.ContactEvent{ID: 2, Type: services.EventContactDeleted, Contact: models.Contact{ID: 1}}
+
+	// Exercise
+	err := services.DispatchEvent(context.Background(), event)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		for _, delivery := range services.GetWebhookDeadLetters(models.DefaultTenantID) {
+			if delivery.WebhookID == 42 {
+				return delivery.Attempts == 3 && delivery.LastStatusCode == http.StatusInternalServerError
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestDispatchEvent_EventNotSubscribed_ExpectedNoDelivery(t *testing.T) {
+	// Fixture
+	webhooks := []models.Webhook{{ID: 7, URL: "http://127.0.0.1:1", Events: []string{services.EventContactCreated}}}
+	patch := monkey.Patch(storage.LoadWebhooks, func(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+		return webhooks, nil
+	})
+	defer patch.Unpatch()
+
+	// Exercise
+	err := services.DispatchEvent(context.Background(), services.ContactEvent{ID: 3, Type: services.EventContactUpdated})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, services.GetWebhookDeliveries(models.DefaultTenantID, 7))
+}
+
+func TestCreateWebhook_InvalidURL_ExpectedError(t *testing.T) {
+	// Exercise
+	_, err := services.CreateWebhook(context.Background(), models.DefaultTenantID, models.Webhook{URL: "ftp://crm.example.com"})
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidWebhookURL)
+}
+
+func TestDispatchEvent_LoopbackTarget_ExpectedRefusedAtDial(t *testing.T) {
+	// Fixture
+	var attempts int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+	}))
+	defer receiver.Close()
+
+	webhooks := []models.Webhook{{ID: 43, URL: receiver.URL, Secret: "segredo"}}
+	patch := monkey.Patch(storage.LoadWebhooks, func(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+		return webhooks, nil
+	})
+	defer patch.Unpatch()
+
+	useWebhookConfig(t, services.WebhookConfig{MaxAttempts: 1, RetryBaseDelay: time.Millisecond})
+
+	// Exercise
+	err := services.DispatchEvent(context.Background(), services.ContactEvent{ID: 4, Type: services.EventContactCreated, Contact: models.Contact{ID: 1}})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		for _, delivery := range services.GetWebhookDeadLetters(models.DefaultTenantID) {
+			if delivery.WebhookID == 43 {
+				return assert.Contains(t, delivery.LastError, "webhook target address is not allowed: 127.0.0.1")
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(&attempts))
+}
+
+func TestCreateWebhook_Concurrent_ExpectedNoWebhookLost(t *testing.T) {
+	// Fixture
+	var mu sync.Mutex
+	var saved []models.Webhook
+	patchLoad := monkey.Patch(storage.LoadWebhooks, func(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]models.Webhook(nil), saved...), nil
+	})
+	defer patchLoad.Unpatch()
+	patchSave := monkey.Patch(storage.SaveWebhooks, func(ctx context.Context, tenantID string, webhooks []models.Webhook) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		saved = webhooks
+		return nil
+	})
+	defer patchSave.Unpatch()
+	const writers = 20
+
+	// Exercise
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := services.CreateWebhook(context.Background(), models.DefaultTenantID, models.Webhook{URL: "https://crm.example.com/hooks"})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	ids := map[int]bool{}
+	for _, webhook := range saved {
+		ids[webhook.ID] = true
+	}
+	assert.Len(t, saved, writers)
+	assert.Len(t, ids, writers)
+}
+
+func TestStartWebhookDispatcher_SavedPendingDelivery_ExpectedResumedAndNewEventsDelivered(t *testing.T) {
+	// Fixture
+	queueFile := filepath.Join("..", "data", "webhook_deliveries.json")
+	defer os.Remove(queueFile)
+	received := make(chan string, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Event")
+	}))
+	defer receiver.Close()
+	useWebhookConfig(t, services.WebhookConfig{AllowPrivateTargets: true})
+
+	webhooks := []models.Webhook{{ID: 44, URL: receiver.URL, Secret: "segredo"}}
+	patch := monkey.Patch(storage.LoadWebhooks, func(ctx context.Context, tenantID string) ([]models.Webhook, error) {
+		return webhooks, nil
+	})
+	defer patch.Unpatch()
+
+	now := time.Now().UTC()
+	require.NoError(t, storage.SaveWebhookQueue(context.Background(), storage.WebhookQueue{
+		NextID: 1000,
+		Deliveries: []models.WebhookDelivery{{ID: 999, TenantID: models.DefaultTenantID, WebhookID: 44, EventID: 1,
+			EventType: services.EventContactDeleted, Status: services.DeliveryPending, Attempts: 1, CreatedAt: now, UpdatedAt: now}},
+		Payloads: map[int]storage.WebhookPayload{999: {EventID: 1, TenantID: models.DefaultTenantID, Type: services.EventContactDeleted, Contact: models.Contact{ID: 1}}},
+	}))
+
+	// Exercise
+	stop, err := services.StartWebhookDispatcher()
+	require.NoError(t, err)
+	defer stop()
+	resumed := receiveWebhook(t, received)
+	services.Events.Publish(models.DefaultTenantID, services.EventContactCreated, models.Contact{ID: 2})
+	published := receiveWebhook(t, received)
+
+	// Assert
+	assert.Equal(t, services.EventContactDeleted, resumed)
+	assert.Equal(t, services.EventContactCreated, published)
+	assert.Eventually(t, func() bool {
+		queue, err := storage.LoadWebhookQueue(context.Background())
+		return err == nil && len(queue.Deliveries) == 2 && len(queue.Payloads) == 0 &&
+			queue.Deliveries[0].Status == services.DeliveryDelivered && queue.Deliveries[1].Status == services.DeliveryDelivered
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func receiveWebhook(t *testing.T, received <-chan string) string {
+	t.Helper()
+	select {
+	case eventType := <-received:
+		return eventType
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+		return ""
+	}
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var ErrInvalidExporter = errors.New("invalid trace exporter, expected none, stdout or otlp")
+
+// Exportadores aceitos em Config.Exporter e em OTEL_TRACES_EXPORTER.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	tracerName         = "github.com/mathzpereira/c214-seminario/contact-list-api"
+	defaultServiceName = "contact-list-api"
+)
+
+// propagator lê e escreve os cabeçalhos traceparent, tracestate e baggage do
+// W3C. Fica fixo no pacote para que o middleware propague o trace mesmo sem
+// Setup, como nos testes.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Config escolhe para onde vão os spans. Com ExporterNone, o padrão, os spans
+// são criados e descartados, sem custo de exportação.
+type Config struct {
+	Exporter    string
+	ServiceName string
+	// Endpoint é a URL do coletor OTLP/HTTP, como http://localhost:4318.
+	// Vazio, o exportador segue OTEL_EXPORTER_OTLP_ENDPOINT e, sem ela,
+	// https://localhost:4318.
+	Endpoint string
+	// Writer recebe os spans do exportador stdout; nil usa a saída padrão.
+	Writer io.Writer
+}
+
+// ConfigFromEnv lê OTEL_TRACES_EXPORTER (none, stdout ou otlp) e
+// OTEL_SERVICE_NAME. Os demais OTEL_EXPORTER_OTLP_* são lidos pelo próprio
+// exportador OTLP.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		Exporter:    strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER"))),
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+	}
+	if c.Exporter == "" {
+		c.Exporter = ExporterNone
+	}
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+		return c, nil
+	}
+	return Config{}, fmt.Errorf("OTEL_TRACES_EXPORTER: %w: %q", ErrInvalidExporter, c.Exporter)
+}
+
+// Setup instala o provedor de traces global e o propagador W3C. A função
+// devolvida envia os spans pendentes e encerra o exportador; deve ser chamada
+// antes de o processo sair.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case ExporterNone, "":
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		w := config.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExporter, config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start abre um span filho do span em ctx. Quem chama encerra o span com
+// span.End(), normalmente com defer.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Fail marca o span como falho quando err não é nil e devolve err, para ser
+// usado no return.
+func Fail(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}